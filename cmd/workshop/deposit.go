@@ -0,0 +1,130 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/admin"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+	"github.com/spf13/cobra"
+)
+
+func depositAddressCommand() *cobra.Command {
+	var payoutAddress string
+	cmd := &cobra.Command{
+		Use:   "deposit-address [CUSTOMER ID]",
+		Short: "Hand out a unique deposit address to a customer",
+		Long: "Show the deposit address for a customer, deriving a new one from the wallet if the customer doesn't have one yet, or list all customer deposit addresses if no customer ID is given. " +
+			"Deposits to the address are attributed to the customer, and in depositor payout mode rewards are sent to the customer's payout address. " +
+			"While the workshop service is running, this goes through its admin API (see ADMIN_LISTEN_ADDRESS), which starts watching new addresses right away. Otherwise, the database is opened directly, and new addresses are watched once the service is started. " +
+			"Deposit addresses have their own payment keys, so use the sweep command to move deposited funds to the wallet.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.Load(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if len(args) == 0 {
+				depositAddrs, err := listDepositAddresses()
+				if err != nil {
+					return err
+				}
+				slices.SortFunc(
+					depositAddrs,
+					func(a, b storage.DepositAddress) int {
+						return cmp.Compare(a.Index, b.Index)
+					},
+				)
+				for _, depositAddr := range depositAddrs {
+					fmt.Printf(
+						"%s  %s  %s\n",
+						depositAddr.CustomerId,
+						depositAddr.Address,
+						depositAddr.PayoutAddress,
+					)
+				}
+				return nil
+			}
+			depositAddr, _, err := assignDepositAddress(
+				args[0],
+				payoutAddress,
+			)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Customer ID: %s\n", depositAddr.CustomerId)
+			fmt.Printf("Deposit address: %s\n", depositAddr.Address)
+			fmt.Printf("Address index: %d\n", depositAddr.Index)
+			if depositAddr.PayoutAddress != "" {
+				fmt.Printf("Payout address: %s\n", depositAddr.PayoutAddress)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(
+		&payoutAddress,
+		"payout-address",
+		"",
+		"address to send rewards for the customer's deposits to, when creating a new deposit address",
+	)
+	return cmd
+}
+
+// listDepositAddresses returns the deposit addresses from the running
+// service, or from the database if the service isn't running
+func listDepositAddresses() ([]storage.DepositAddress, error) {
+	cfg := config.GetConfig()
+	client, err := admin.NewClient(cfg.Admin.ListenAddress, cfg.Admin.Token)
+	if err == nil {
+		depositAddrs, err := client.ListDepositAddresses()
+		if !errors.Is(err, admin.ErrNotRunning) {
+			return depositAddrs, err
+		}
+	}
+	if err := storage.GetStorage().Load(); err != nil {
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+	defer storage.GetStorage().Close()
+	return storage.GetStorage().ListDepositAddresses()
+}
+
+// assignDepositAddress assigns a deposit address to the customer through the
+// running service, or in the database if the service isn't running
+func assignDepositAddress(
+	customerId string,
+	payoutAddress string,
+) (*storage.DepositAddress, bool, error) {
+	cfg := config.GetConfig()
+	client, err := admin.NewClient(cfg.Admin.ListenAddress, cfg.Admin.Token)
+	if err == nil {
+		depositAddr, created, err := client.AssignDepositAddress(
+			customerId,
+			payoutAddress,
+		)
+		if !errors.Is(err, admin.ErrNotRunning) {
+			return depositAddr, created, err
+		}
+	}
+	if err := storage.GetStorage().Load(); err != nil {
+		return nil, false, fmt.Errorf("failed to load storage: %w", err)
+	}
+	defer storage.GetStorage().Close()
+	return wallet.AssignDepositAddress(customerId, payoutAddress)
+}
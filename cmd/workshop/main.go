@@ -15,12 +15,20 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"os"
 
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/admin"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/backend"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/indexer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/rules"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/tracker"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txbuilder"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
 	"github.com/spf13/cobra"
 )
@@ -36,6 +44,14 @@ func main() {
 		Args: cobra.ExactArgs(0),
 		Run:  workshopRun,
 	}
+	cmd.AddCommand(
+		depositAddressCommand(),
+		mintPolicyCommand(),
+		signerCommand(),
+		sweepCommand(),
+		treasuryCommand(),
+		txStatusCommand(),
+	)
 
 	if err := cmd.Execute(); err != nil {
 		// NOTE: we purposely don't display the error, since cobra will have already displayed it
@@ -55,8 +71,40 @@ func workshopRun(cmd *cobra.Command, args []string) {
 		)
 		os.Exit(1)
 	}
-	// Setup wallet
-	w, err := wallet.Setup()
+	// Load reward rules
+	if err := rules.GetEngine().Load(); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to load reward rules: %s", err),
+		)
+		os.Exit(1)
+	}
+	// Load storage
+	if err := storage.GetStorage().Load(); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to load storage: %s", err),
+		)
+		os.Exit(1)
+	}
+	// Resume tracking reward TXs submitted by a previous run
+	if err := tracker.GetTracker().Load(); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to load transaction tracker: %s", err),
+		)
+		os.Exit(1)
+	}
+	// Setup the signer holding the payment key
+	s, err := signer.Setup()
+	if err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to configure signer: %s", err),
+		)
+		os.Exit(1)
+	}
+	slog.Info(
+		"signing with payment key: " + hex.EncodeToString(signer.KeyHash(s)),
+	)
+	// Setup wallet, which only loads the mnemonic when signing with it
+	w, err := signer.SetupWallet(s)
 	if err != nil {
 		slog.Error(
 			fmt.Sprintf("failed to configure wallet: %s", err),
@@ -64,8 +112,65 @@ func workshopRun(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 	slog.Info(
-		"loaded mnemonic for address: " + w.PaymentAddress,
+		"using wallet address: " + w.PaymentAddress,
 	)
+	if err := txbuilder.CheckSigner(w, s); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	// Pay rewards from the multi-signature treasury, if configured
+	treasury, err := txbuilder.GetTreasury()
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	if treasury != nil {
+		slog.Info(
+			fmt.Sprintf(
+				"paying rewards from %d-of-%d treasury address: %s",
+				treasury.Required,
+				len(treasury.KeyHashes),
+				treasury.Address,
+			),
+		)
+		// Clean up exported TXs once they're confirmed or expire
+		tracker.GetTracker().AddListener(txbuilder.HandleTreasuryTxStatus)
+	}
+	// Load the deposit addresses handed out to customers
+	if err := wallet.LoadDepositAddresses(); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to load deposit addresses: %s", err),
+		)
+		os.Exit(1)
+	}
+	// Setup chain backend
+	if _, err := backend.Setup(); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to configure chain backend: %s", err),
+		)
+		os.Exit(1)
+	}
+	// Make sure we can get the protocol parameters needed to build TXs
+	if _, err := txbuilder.GetProtocolParams().Get(); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to get protocol parameters: %s", err),
+		)
+		os.Exit(1)
+	}
+	// Resume retries scheduled by a previous run
+	if err := txbuilder.GetRetrier().Start(); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to start reward retrier: %s", err),
+		)
+		os.Exit(1)
+	}
+	// Pay out any rewards left pending from a previous run
+	if err := txbuilder.ResumePendingRewards(); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to resume pending rewards: %s", err),
+		)
+		os.Exit(1)
+	}
 	// Start indexer
 	slog.Info(
 		"starting indexer on network " + cfg.Network,
@@ -76,6 +181,13 @@ func workshopRun(cmd *cobra.Command, args []string) {
 		)
 		os.Exit(1)
 	}
+	// Serve the admin API used by the CLI while we're running
+	if cfg.Admin.ListenAddress != "" {
+		if err := admin.Start(cfg.Admin.ListenAddress, cfg.Admin.Token); err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+	}
 	// Wait forever
 	select {}
 }
@@ -0,0 +1,58 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txbuilder"
+	"github.com/spf13/cobra"
+)
+
+func mintPolicyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "mint-policy",
+		Short: "Show the signer minting policy used for reward tokens",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.Load(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if _, err := loadSigner(""); err != nil {
+				return err
+			}
+			policy, err := txbuilder.GetMintPolicy()
+			if err != nil {
+				return err
+			}
+			policyId, err := policy.PolicyId()
+			if err != nil {
+				return err
+			}
+			scriptCbor, err := policy.Cbor()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Policy ID: %s\n", policyId)
+			fmt.Printf("Script CBOR: %s\n", hex.EncodeToString(scriptCbor))
+			if policy.LockSlot > 0 {
+				fmt.Printf("Locked after slot: %d\n", policy.LockSlot)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/spf13/cobra"
+)
+
+func signerCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "signer",
+		Short: "Run a remote signer holding the payment signing key",
+	}
+	cmd.AddCommand(
+		signerServeCommand(),
+	)
+	return cmd
+}
+
+func signerServeCommand() *cobra.Command {
+	var listenAddr string
+	var keyFile string
+	var allowedAddresses []string
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve signatures from the signing key to the workshop service",
+		Long: "Serve signatures from the configured wallet, or from a cardano-cli payment signing key file, so that the key doesn't need to be held by the workshop service. " +
+			"Point SIGNER_URL at the listen address to use it. " +
+			"The signer logs the outputs of each TX it signs, and with --allowed-address or SIGNER_ALLOWED_ADDRESSES it only signs TXs paying to those addresses or back to its own key, with a fee up to SIGNER_MAX_FEE, and without minting, certificates or withdrawals unless SIGNER_ALLOW_MINT, SIGNER_ALLOW_CERTIFICATES or SIGNER_ALLOW_WITHDRAWALS is set. " +
+			"Anyone who can connect can sign with the key, so prefer a Unix socket, which is only accessible by the current user. " +
+			"Listening on a non-loopback address requires SIGNER_TOKEN to be set, which the workshop service then needs to provide.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.Load(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			cfg := config.GetConfig()
+			if keyFile == "" && signer.Type() == signer.SignerRemote {
+				return errors.New(
+					"the remote signer needs a local key, see SIGNER_KEY_FILE or --signing-key-file",
+				)
+			}
+			s, err := loadSigner(keyFile)
+			if err != nil {
+				return err
+			}
+			fmt.Printf(
+				"Serving signatures for key %s\n",
+				hex.EncodeToString(signer.KeyHash(s)),
+			)
+			return signer.Serve(
+				s,
+				listenAddr,
+				cfg.Signer.Token,
+				signer.Policy{
+					AllowedAddresses: append(
+						cfg.Signer.AllowedAddresses,
+						allowedAddresses...,
+					),
+					MaxFee:            cfg.Signer.MaxFee,
+					AllowMint:         cfg.Signer.AllowMint,
+					AllowCertificates: cfg.Signer.AllowCertificates,
+					AllowWithdrawals:  cfg.Signer.AllowWithdrawals,
+				},
+			)
+		},
+	}
+	cmd.Flags().StringVar(
+		&listenAddr,
+		"listen",
+		"unix://signer.sock",
+		"address to listen on, as unix://<socket path> or <host>:<port>",
+	)
+	cmd.Flags().StringSliceVar(
+		&allowedAddresses,
+		"allowed-address",
+		nil,
+		"only sign TXs paying to this address or back to the signer key (can be repeated)",
+	)
+	addSigningKeyFileFlag(cmd, &keyFile)
+	return cmd
+}
+
+// addSigningKeyFileFlag adds the flag for signing with a key file instead of
+// the configured signer
+func addSigningKeyFileFlag(cmd *cobra.Command, keyFile *string) {
+	cmd.Flags().StringVar(
+		keyFile,
+		"signing-key-file",
+		"",
+		"cardano-cli payment signing key file to sign with, instead of the configured signer",
+	)
+}
+
+// loadSigner returns a signer for the key file, if provided, or the
+// configured signer otherwise. The config needs to be loaded first
+func loadSigner(keyFile string) (signer.Signer, error) {
+	if keyFile != "" {
+		return signer.NewFileSigner(keyFile)
+	}
+	s, err := signer.Setup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure signer: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/admin"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func txStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tx-status [TX ID]",
+		Short: "Show the status of submitted reward transactions",
+		Long: "Show the status of a submitted reward transaction, or of all submitted reward transactions if no TX ID is given. " +
+			"The TX ID can also be the hash of a TX that triggered a reward. " +
+			"While the workshop service is running, this goes through its admin API. Otherwise, the database is opened directly.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.Load(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if len(args) == 0 {
+				txs, err := listTransactions()
+				if err != nil {
+					return err
+				}
+				slices.SortFunc(txs, func(a, b storage.Transaction) int {
+					return a.SubmittedAt.Compare(b.SubmittedAt)
+				})
+				for _, tx := range txs {
+					fmt.Printf(
+						"%s  %-10s  %s\n",
+						tx.TxId,
+						tx.Status,
+						tx.SubmittedAt.Format(time.RFC3339),
+					)
+				}
+				return nil
+			}
+			tx, reward, err := lookupTransaction(args[0])
+			if err != nil {
+				return err
+			}
+			if reward != nil {
+				fmt.Printf("Reward status: %s\n", reward.Status)
+				if reward.CustomerId != "" {
+					fmt.Printf("Customer ID: %s\n", reward.CustomerId)
+				}
+				if reward.Error != "" {
+					fmt.Printf("Reward error: %s\n", reward.Error)
+				}
+				if reward.Attempts > 0 {
+					fmt.Printf("Failed attempts: %d\n", reward.Attempts)
+				}
+				if !reward.NextAttempt.IsZero() {
+					fmt.Printf(
+						"Next attempt at: %s\n",
+						reward.NextAttempt.Format(time.RFC3339),
+					)
+				}
+				if tx == nil {
+					if reward.RewardTxId != "" {
+						fmt.Printf("Reward TX ID: %s\n", reward.RewardTxId)
+					}
+					return nil
+				}
+			}
+			fmt.Printf("TX ID: %s\n", tx.TxId)
+			fmt.Printf("Status: %s\n", tx.Status)
+			fmt.Printf("Submitted at: %s\n", tx.SubmittedAt.Format(time.RFC3339))
+			if tx.Ttl > 0 {
+				fmt.Printf("TTL slot: %d\n", tx.Ttl)
+			}
+			if tx.BlockNumber > 0 {
+				fmt.Printf(
+					"Block: %d (slot %d, hash %s)\n",
+					tx.BlockNumber,
+					tx.Slot,
+					tx.BlockHash,
+				)
+			}
+			if len(tx.RewardTxHashes) > 0 {
+				fmt.Printf(
+					"Rewards for TXs: %s\n",
+					strings.Join(tx.RewardTxHashes, ", "),
+				)
+			}
+			return nil
+		},
+	}
+}
+
+// listTransactions returns the submitted reward TXs from the running service,
+// or from the database if the service isn't running
+func listTransactions() ([]storage.Transaction, error) {
+	cfg := config.GetConfig()
+	client, err := admin.NewClient(cfg.Admin.ListenAddress, cfg.Admin.Token)
+	if err == nil {
+		txs, err := client.ListTransactions()
+		if !errors.Is(err, admin.ErrNotRunning) {
+			return txs, err
+		}
+	}
+	if err := storage.GetStorage().Load(); err != nil {
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+	defer storage.GetStorage().Close()
+	return storage.GetStorage().ListTransactions()
+}
+
+// lookupTransaction returns the submitted TX with the specified ID, or the
+// reward triggered by the TX with that hash along with its reward TX, from
+// the running service, or from the database if the service isn't running
+func lookupTransaction(
+	txId string,
+) (*storage.Transaction, *storage.Reward, error) {
+	cfg := config.GetConfig()
+	client, err := admin.NewClient(cfg.Admin.ListenAddress, cfg.Admin.Token)
+	if err == nil {
+		tx, reward, err := client.LookupTransaction(txId)
+		if !errors.Is(err, admin.ErrNotRunning) {
+			return tx, reward, err
+		}
+	}
+	if err := storage.GetStorage().Load(); err != nil {
+		return nil, nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+	defer storage.GetStorage().Close()
+	tx, reward, err := storage.GetStorage().LookupTransaction(txId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tx == nil && reward == nil {
+		return nil, nil, fmt.Errorf("unknown transaction: %s", txId)
+	}
+	return tx, reward, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/admin"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/backend"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txbuilder"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+	"github.com/spf13/cobra"
+)
+
+func sweepCommand() *cobra.Command {
+	var destAddr string
+	cmd := &cobra.Command{
+		Use:   "sweep",
+		Short: "Move deposited funds from receive and customer deposit addresses to the wallet",
+		Long: "Move the funds at the receive addresses and customer deposit addresses to the address rewards are paid from, or to --to. " +
+			"These addresses have their own payment keys derived from the wallet mnemonic, so reward TXs never spend their funds. " +
+			"One TX is submitted for each address with UTxOs, signed with the address's payment key, so the mnemonic needs to be available. " +
+			"While the workshop service is running, this goes through its admin API. Otherwise, the database is opened directly.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			var txIds []string
+			client, err := admin.NewClient(
+				cfg.Admin.ListenAddress,
+				cfg.Admin.Token,
+			)
+			if err == nil {
+				txIds, err = client.Sweep(destAddr)
+			}
+			if errors.Is(err, admin.ErrNotRunning) {
+				txIds, err = sweepLocal(destAddr)
+			}
+			for _, txId := range txIds {
+				fmt.Printf("Submitted TX %s\n", txId)
+			}
+			if err != nil {
+				return err
+			}
+			if len(txIds) == 0 {
+				fmt.Println("Nothing to sweep")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(
+		&destAddr,
+		"to",
+		"",
+		"address to send the funds to, instead of the address rewards are paid from",
+	)
+	return cmd
+}
+
+// sweepLocal sweeps the receive and deposit addresses without the workshop
+// service running. The config needs to be loaded first
+func sweepLocal(destAddr string) ([]string, error) {
+	if err := storage.GetStorage().Load(); err != nil {
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+	defer storage.GetStorage().Close()
+	if err := wallet.LoadDepositAddresses(); err != nil {
+		return nil, fmt.Errorf("failed to load deposit addresses: %w", err)
+	}
+	s, err := loadSigner("")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := signer.SetupWallet(s); err != nil {
+		return nil, fmt.Errorf("failed to configure wallet: %w", err)
+	}
+	if _, err := backend.Setup(); err != nil {
+		return nil, fmt.Errorf("failed to configure chain backend: %w", err)
+	}
+	return txbuilder.Sweep(destAddr)
+}
@@ -0,0 +1,342 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txbuilder"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txsubmit"
+	"github.com/spf13/cobra"
+)
+
+func treasuryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "treasury",
+		Short: "Manage the multi-signature treasury that rewards are paid from",
+		Long: "Manage the M-of-N native script treasury configured with TREASURY_KEYS and TREASURY_REQUIRED. " +
+			"Reward TXs that need more signatures are exported to TREASURY_PENDING_DIR, where cosigners can sign them offline with the sign command or with \"cardano-cli transaction witness\". " +
+			"The signatures are then merged and the TX submitted once it has enough of them.",
+	}
+	cmd.AddCommand(
+		treasuryInfoCommand(),
+		treasuryKeyHashCommand(),
+		treasuryInspectCommand(),
+		treasurySignCommand(),
+		treasuryMergeCommand(),
+		treasurySubmitCommand(),
+	)
+	return cmd
+}
+
+func treasuryInfoCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "info",
+		Short: "Show the treasury address and script",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			treasury, err := loadTreasury()
+			if err != nil {
+				return err
+			}
+			scriptCbor, err := treasury.Cbor()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Address: %s\n", treasury.Address)
+			fmt.Printf("Script CBOR: %s\n", hex.EncodeToString(scriptCbor))
+			fmt.Printf(
+				"Required signatures: %d of %d\n",
+				treasury.Required,
+				len(treasury.KeyHashes),
+			)
+			for _, keyHash := range treasury.KeyHashes {
+				fmt.Printf("Cosigner: %s\n", hex.EncodeToString(keyHash))
+			}
+			return nil
+		},
+	}
+}
+
+func treasuryKeyHashCommand() *cobra.Command {
+	var keyFile string
+	cmd := &cobra.Command{
+		Use:   "key-hash",
+		Short: "Show the signer payment key hash, for use in TREASURY_KEYS",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.Load(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			s, err := loadSigner(keyFile)
+			if err != nil {
+				return err
+			}
+			fmt.Println(hex.EncodeToString(signer.KeyHash(s)))
+			return nil
+		},
+	}
+	addSigningKeyFileFlag(cmd, &keyFile)
+	return cmd
+}
+
+func treasuryInspectCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "inspect TX_FILE",
+		Short: "Show the signatures collected for a treasury TX",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			treasury, err := loadTreasury()
+			if err != nil {
+				return err
+			}
+			tx, err := readPartialTx(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("TX ID: %s\n", tx.Id())
+			fmt.Printf(
+				"Signatures: %d of %d required\n",
+				len(treasury.Signers(tx)),
+				treasury.Required,
+			)
+			signed := make(map[string]bool)
+			for _, keyHash := range tx.SignerKeyHashes() {
+				signed[hex.EncodeToString(keyHash)] = true
+			}
+			for _, keyHash := range treasury.KeyHashes {
+				status := "missing"
+				if signed[hex.EncodeToString(keyHash)] {
+					status = "signed"
+				}
+				fmt.Printf(
+					"Cosigner %s: %s\n",
+					hex.EncodeToString(keyHash),
+					status,
+				)
+			}
+			return nil
+		},
+	}
+}
+
+func treasurySignCommand() *cobra.Command {
+	var outFile string
+	var keyFile string
+	cmd := &cobra.Command{
+		Use:   "sign TX_FILE",
+		Short: "Sign a treasury TX with the signer payment key",
+		Long: "Sign a treasury TX with the payment key of the configured signer, or with a cardano-cli payment signing key file. " +
+			"This doesn't need a connection to the network, so it can be run on an offline machine.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.Load(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			s, err := loadSigner(keyFile)
+			if err != nil {
+				return err
+			}
+			tx, err := readPartialTx(args[0])
+			if err != nil {
+				return err
+			}
+			// The treasury config is optional here, since cosigners may only
+			// have their own key configured
+			treasury, err := txbuilder.GetTreasury()
+			if err != nil {
+				return err
+			}
+			if treasury != nil {
+				keyHash := signer.KeyHash(s)
+				if !treasury.IsCosigner(keyHash) {
+					return fmt.Errorf(
+						"signer key %s is not a treasury cosigner",
+						hex.EncodeToString(keyHash),
+					)
+				}
+			}
+			added, err := tx.Sign(s)
+			if err != nil {
+				return err
+			}
+			if !added {
+				fmt.Printf("TX %s was already signed by the key\n", tx.Id())
+				return nil
+			}
+			return writePartialTx(tx, args[0], outFile)
+		},
+	}
+	cmd.Flags().StringVar(
+		&outFile,
+		"out",
+		"",
+		"file to write the signed TX to, instead of updating the TX file",
+	)
+	addSigningKeyFileFlag(cmd, &keyFile)
+	return cmd
+}
+
+func treasuryMergeCommand() *cobra.Command {
+	var outFile string
+	cmd := &cobra.Command{
+		Use:   "merge TX_FILE SIGNED_FILE...",
+		Short: "Add the signatures from other copies of a treasury TX",
+		Long: "Add the signatures from other copies of a treasury TX, or from witness files created with \"cardano-cli transaction witness\", to the TX file. " +
+			"Only signatures from the treasury cosigners are accepted, and they're checked against the TX before they're added.",
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			treasury, err := loadTreasury()
+			if err != nil {
+				return err
+			}
+			tx, err := readPartialTx(args[0])
+			if err != nil {
+				return err
+			}
+			var added int
+			for _, path := range args[1:] {
+				txId, witnesses, err := txbuilder.ReadSignatures(path)
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", path, err)
+				}
+				if txId != "" && txId != tx.Id() {
+					return fmt.Errorf(
+						"%s contains TX %s instead of TX %s",
+						path,
+						txId,
+						tx.Id(),
+					)
+				}
+				for _, witness := range witnesses {
+					ok, err := tx.AddCosignerWitness(
+						treasury,
+						witness.Vkey,
+						witness.Signature,
+					)
+					if err != nil {
+						return fmt.Errorf("%s: %w", path, err)
+					}
+					if ok {
+						added++
+					}
+				}
+			}
+			fmt.Printf("Added %d signatures to TX %s\n", added, tx.Id())
+			if added == 0 {
+				return nil
+			}
+			return writePartialTx(tx, args[0], outFile)
+		},
+	}
+	cmd.Flags().StringVar(
+		&outFile,
+		"out",
+		"",
+		"file to write the merged TX to, instead of updating the TX file",
+	)
+	return cmd
+}
+
+func treasurySubmitCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "submit TX_FILE",
+		Short: "Submit a treasury TX that has enough signatures",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			treasury, err := loadTreasury()
+			if err != nil {
+				return err
+			}
+			tx, err := readPartialTx(args[0])
+			if err != nil {
+				return err
+			}
+			if signers := len(treasury.Signers(tx)); signers < treasury.Required {
+				return fmt.Errorf(
+					"TX %s has %d of %d required signatures",
+					tx.Id(),
+					signers,
+					treasury.Required,
+				)
+			}
+			// The TX fee only pays for the required signatures
+			if dropped := treasury.TrimSignatures(tx); dropped > 0 {
+				fmt.Printf(
+					"Dropped %d signatures beyond the %d required\n",
+					dropped,
+					treasury.Required,
+				)
+			}
+			txBytes, err := tx.Bytes()
+			if err != nil {
+				return err
+			}
+			if err := txsubmit.SubmitTx(txBytes); err != nil {
+				return fmt.Errorf("failed to submit TX %s: %w", tx.Id(), err)
+			}
+			fmt.Printf("Submitted TX %s\n", tx.Id())
+			return nil
+		},
+	}
+}
+
+// loadTreasury loads the config and returns the configured treasury
+func loadTreasury() (*txbuilder.Treasury, error) {
+	if _, err := config.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	treasury, err := txbuilder.GetTreasury()
+	if err != nil {
+		return nil, err
+	}
+	if treasury == nil {
+		return nil, errors.New("no treasury configured, see TREASURY_KEYS")
+	}
+	return treasury, nil
+}
+
+func readPartialTx(path string) (*txbuilder.PartialTx, error) {
+	txBytes, err := txbuilder.ReadTxFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return txbuilder.DecodePartialTx(txBytes)
+}
+
+// writePartialTx writes the TX to the output file, or back to the file it was
+// read from if none was specified
+func writePartialTx(
+	tx *txbuilder.PartialTx,
+	inFile string,
+	outFile string,
+) error {
+	if outFile == "" {
+		outFile = inFile
+	}
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := txbuilder.WriteTxFile(outFile, txBytes); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote TX %s to %s\n", tx.Id(), outFile)
+	return nil
+}
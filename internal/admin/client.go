@@ -0,0 +1,216 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+)
+
+const (
+	// Maximum time to wait for the running service
+	clientTimeout = 2 * time.Minute
+	// Maximum size of an admin API response body
+	maxResponseBodySize = 16 * 1024 * 1024
+)
+
+// ErrNotRunning is returned when the service isn't running, so that the CLI
+// can open the database directly instead
+var ErrNotRunning = errors.New("workshop service is not running")
+
+// Client talks to the admin API of the running service
+type Client struct {
+	baseUrl string
+	token   string
+	client  *http.Client
+}
+
+// NewClient returns a client for the admin API at the provided listen
+// address and with the token, as configured for the service
+func NewClient(listenAddr string, token string) (*Client, error) {
+	if listenAddr == "" {
+		return nil, ErrNotRunning
+	}
+	ret := &Client{
+		token:  token,
+		client: &http.Client{Timeout: clientTimeout},
+	}
+	u, err := url.Parse(listenAddr)
+	if err == nil && u.Scheme == unixScheme {
+		socketPath := unixSocketPath(u)
+		ret.baseUrl = "http://" + unixHost
+		ret.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		}
+	} else {
+		ret.baseUrl = "http://" + listenAddr
+	}
+	return ret, nil
+}
+
+// ListDepositAddresses returns all deposit addresses handed out to customers
+func (c *Client) ListDepositAddresses() ([]storage.DepositAddress, error) {
+	var ret []storage.DepositAddress
+	if err := c.request(
+		http.MethodGet,
+		pathDepositAddresses,
+		nil,
+		&ret,
+	); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// AssignDepositAddress returns the deposit address for the customer, which
+// the service derives and starts watching if the customer doesn't have one
+// yet. The second return value is whether a new address was created
+func (c *Client) AssignDepositAddress(
+	customerId string,
+	payoutAddress string,
+) (*storage.DepositAddress, bool, error) {
+	var resp assignDepositAddressResponse
+	if err := c.request(
+		http.MethodPost,
+		pathDepositAddresses,
+		assignDepositAddressRequest{
+			CustomerId:    customerId,
+			PayoutAddress: payoutAddress,
+		},
+		&resp,
+	); err != nil {
+		return nil, false, err
+	}
+	return &resp.DepositAddress, resp.Created, nil
+}
+
+// Sweep has the service move the funds at the receive addresses and customer
+// deposit addresses to the destination address, or to the address rewards are
+// paid from if none is provided, and returns the IDs of the submitted TXs
+func (c *Client) Sweep(destAddr string) ([]string, error) {
+	var resp sweepResponse
+	if err := c.request(
+		http.MethodPost,
+		pathSweep,
+		sweepRequest{Destination: destAddr},
+		&resp,
+	); err != nil {
+		return nil, err
+	}
+	return resp.TxIds, nil
+}
+
+// ListTransactions returns all submitted reward TXs
+func (c *Client) ListTransactions() ([]storage.Transaction, error) {
+	var ret []storage.Transaction
+	if err := c.request(
+		http.MethodGet,
+		pathTransactions,
+		nil,
+		&ret,
+	); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// LookupTransaction returns the submitted TX with the specified ID, or the
+// reward triggered by the TX with that hash along with its reward TX, like
+// storage.LookupTransaction. An error is returned if neither is found
+func (c *Client) LookupTransaction(
+	txId string,
+) (*storage.Transaction, *storage.Reward, error) {
+	var resp transactionResponse
+	if err := c.request(
+		http.MethodGet,
+		pathTransactions+"/"+url.PathEscape(txId),
+		nil,
+		&resp,
+	); err != nil {
+		return nil, nil, err
+	}
+	return resp.Transaction, resp.Reward, nil
+}
+
+// request sends a request to the admin API and decodes the JSON response
+func (c *Client) request(
+	method string,
+	path string,
+	reqBody any,
+	respBody any,
+) error {
+	var body io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		method,
+		c.baseUrl+path,
+		body,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req) // #nosec G704
+	if err != nil {
+		// Nothing is listening, so the service isn't running
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return ErrNotRunning
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp errorResponse
+		if err := json.Unmarshal(data, &errResp); err == nil &&
+			errResp.Error != "" {
+			return errors.New(errResp.Error)
+		}
+		return fmt.Errorf("%d: %s", resp.StatusCode, data)
+	}
+	if err := json.Unmarshal(data, respBody); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txbuilder"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+)
+
+// The admin API lets the CLI make changes through the running service, which
+// holds the database lock. It's JSON over HTTP, either on a Unix socket or a
+// loopback TCP port. Over TCP, which other local users can connect to,
+// requests need "Authorization: Bearer <token>":
+//
+//	GET /deposit-addresses -> [<deposit address>, ...]
+//	POST /deposit-addresses {"customerId": "<ID>", "payoutAddress": "<address>"}
+//	  -> {"depositAddress": <deposit address>, "created": <bool>}
+//	POST /sweep {"destination": "<address>"} -> {"txIds": ["<TX ID>", ...]}
+//	GET /transactions -> [<transaction>, ...]
+//	GET /transactions/<TX ID> -> {"transaction": <transaction>, "reward": <reward>}
+//
+// Errors are returned with a non-200 status and {"error": "<message>"}
+const (
+	pathDepositAddresses = "/deposit-addresses"
+	pathSweep            = "/sweep"
+	pathTransactions     = "/transactions"
+
+	// Scheme for Unix socket URLs
+	unixScheme = "unix"
+	// Placeholder host for requests over a Unix socket
+	unixHost = "workshop"
+
+	// Maximum size of an admin API request body
+	maxRequestBodySize = 4096
+)
+
+type assignDepositAddressRequest struct {
+	CustomerId    string `json:"customerId"`
+	PayoutAddress string `json:"payoutAddress,omitempty"`
+}
+
+type assignDepositAddressResponse struct {
+	DepositAddress storage.DepositAddress `json:"depositAddress"`
+	Created        bool                   `json:"created"`
+}
+
+type sweepRequest struct {
+	Destination string `json:"destination,omitempty"`
+}
+
+type sweepResponse struct {
+	TxIds []string `json:"txIds"`
+}
+
+type transactionResponse struct {
+	Transaction *storage.Transaction `json:"transaction,omitempty"`
+	Reward      *storage.Reward      `json:"reward,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler returns an HTTP handler serving the admin API. When a token is
+// provided, requests without it are rejected
+func NewHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET "+pathDepositAddresses,
+		func(w http.ResponseWriter, r *http.Request) {
+			depositAddrs, err := storage.GetStorage().ListDepositAddresses()
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			if depositAddrs == nil {
+				depositAddrs = []storage.DepositAddress{}
+			}
+			writeJson(w, http.StatusOK, depositAddrs)
+		},
+	)
+	mux.HandleFunc(
+		"POST "+pathDepositAddresses,
+		func(w http.ResponseWriter, r *http.Request) {
+			var req assignDepositAddressRequest
+			body := http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+			if req.CustomerId == "" {
+				writeError(w, http.StatusBadRequest, "no customer ID provided")
+				return
+			}
+			depositAddr, created, err := wallet.AssignDepositAddress(
+				req.CustomerId,
+				req.PayoutAddress,
+			)
+			if errors.Is(err, wallet.ErrInvalidDepositRequest) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			if created {
+				slog.Info(
+					fmt.Sprintf(
+						"assigned deposit address %s to customer %s",
+						depositAddr.Address,
+						depositAddr.CustomerId,
+					),
+				)
+			}
+			writeJson(
+				w,
+				http.StatusOK,
+				assignDepositAddressResponse{
+					DepositAddress: *depositAddr,
+					Created:        created,
+				},
+			)
+		},
+	)
+	mux.HandleFunc(
+		"POST "+pathSweep,
+		func(w http.ResponseWriter, r *http.Request) {
+			var req sweepRequest
+			body := http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+			txIds, err := txbuilder.Sweep(req.Destination)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			if txIds == nil {
+				txIds = []string{}
+			}
+			writeJson(w, http.StatusOK, sweepResponse{TxIds: txIds})
+		},
+	)
+	mux.HandleFunc(
+		"GET "+pathTransactions,
+		func(w http.ResponseWriter, r *http.Request) {
+			txs, err := storage.GetStorage().ListTransactions()
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			if txs == nil {
+				txs = []storage.Transaction{}
+			}
+			writeJson(w, http.StatusOK, txs)
+		},
+	)
+	mux.HandleFunc(
+		"GET "+pathTransactions+"/{txId}",
+		func(w http.ResponseWriter, r *http.Request) {
+			txId := r.PathValue("txId")
+			tx, reward, err := storage.GetStorage().LookupTransaction(txId)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			if tx == nil && reward == nil {
+				writeError(
+					w,
+					http.StatusNotFound,
+					"unknown transaction: "+txId,
+				)
+				return
+			}
+			writeJson(
+				w,
+				http.StatusOK,
+				transactionResponse{Transaction: tx, Reward: reward},
+			)
+		},
+	)
+	if token == "" {
+		return mux
+	}
+	return requireToken(mux, token)
+}
+
+// requireToken rejects requests without the bearer token
+func requireToken(next http.Handler, token string) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(provided, expected) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Start serves the admin API in the background on a Unix socket, as
+// unix://<socket path>, or a loopback TCP address, as <host>:<port>. The
+// socket is only accessible by the current user, and a token is required to
+// listen on TCP
+func Start(listenAddr string, token string) error {
+	listener, err := listen(listenAddr, token)
+	if err != nil {
+		return fmt.Errorf("failed to start admin API: %w", err)
+	}
+	slog.Info("admin API listening on " + listenAddr)
+	server := &http.Server{
+		Handler:           NewHandler(token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil &&
+			!errors.Is(err, http.ErrServerClosed) {
+			slog.Error(fmt.Sprintf("admin API failed: %s", err))
+		}
+	}()
+	return nil
+}
+
+func listen(listenAddr string, token string) (net.Listener, error) {
+	u, err := url.Parse(listenAddr)
+	if err == nil && u.Scheme == unixScheme {
+		socketPath := unixSocketPath(u)
+		// Remove a socket left behind by a previous run
+		if info, err := os.Stat(socketPath); err == nil &&
+			info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(socketPath); err != nil {
+				return nil, err
+			}
+		}
+		listener, err := net.Listen("unix", socketPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(socketPath, 0o600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+	// Any local user can connect over TCP, and the admin API can sweep the
+	// wallet, so it must not be reachable from other hosts either
+	if token == "" {
+		return nil, fmt.Errorf(
+			"refusing to listen on TCP address %s without a token",
+			listenAddr,
+		)
+	}
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok &&
+		!addr.IP.IsLoopback() {
+		listener.Close()
+		return nil, fmt.Errorf(
+			"refusing to listen on non-loopback address %s",
+			listenAddr,
+		)
+	}
+	return listener, nil
+}
+
+// unixSocketPath returns the socket path from a unix:// URL, which may be
+// relative, as in unix://admin.sock
+func unixSocketPath(u *url.URL) string {
+	return u.Host + u.Path
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to write admin API response: %s", err),
+		)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, errorResponse{Error: message})
+}
+
+func writeServerError(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,392 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+)
+
+// Token required by the admin API in tests
+const testToken = "test-token"
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art"
+
+// newTestClient opens an empty database and returns a client for an admin API
+// server using it, which requires the test token
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	return newTestClientWithToken(t, testToken)
+}
+
+// newTestClientWithToken is like newTestClient, with the client sending the
+// provided token
+func newTestClientWithToken(t *testing.T, token string) *Client {
+	t.Helper()
+	cfg := config.GetConfig()
+	cfg.Network = "preview"
+	cfg.Wallet.Mnemonic = testMnemonic
+	cfg.Storage.Directory = t.TempDir()
+	if err := storage.GetStorage().Load(); err != nil {
+		t.Fatalf("failed to load storage: %s", err)
+	}
+	t.Cleanup(func() {
+		storage.GetStorage().Close()
+	})
+	server := httptest.NewServer(NewHandler(testToken))
+	t.Cleanup(server.Close)
+	client, err := NewClient(strings.TrimPrefix(server.URL, "http://"), token)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	return client
+}
+
+func TestAssignDepositAddress(t *testing.T) {
+	client := newTestClient(t)
+	var notified []string
+	wallet.AddDepositAddressListener(
+		func(depositAddr storage.DepositAddress) {
+			notified = append(notified, depositAddr.Address)
+		},
+	)
+	first, created, err := client.AssignDepositAddress("alice", "")
+	if err != nil {
+		t.Fatalf("failed to assign deposit address: %s", err)
+	}
+	if !created {
+		t.Fatalf("expected a new deposit address")
+	}
+	// Asking again returns the same address without creating a new one
+	again, created, err := client.AssignDepositAddress("alice", "")
+	if err != nil {
+		t.Fatalf("failed to assign deposit address: %s", err)
+	}
+	if created || again.Address != first.Address {
+		t.Fatalf("got new address %s, expected %s", again.Address, first.Address)
+	}
+	second, _, err := client.AssignDepositAddress("bob", "")
+	if err != nil {
+		t.Fatalf("failed to assign deposit address: %s", err)
+	}
+	if second.Address == first.Address || second.Index <= first.Index {
+		t.Fatalf("expected a new address at a later index for another customer")
+	}
+	// The service is notified of new addresses only, so it can watch them
+	if len(notified) != 2 || notified[0] != first.Address ||
+		notified[1] != second.Address {
+		t.Fatalf("got notifications for %v", notified)
+	}
+	if _, ok := wallet.LookupDepositAddress(second.Address); !ok {
+		t.Fatalf("deposit address not known to the wallet")
+	}
+	depositAddrs, err := client.ListDepositAddresses()
+	if err != nil {
+		t.Fatalf("failed to list deposit addresses: %s", err)
+	}
+	if len(depositAddrs) != 2 {
+		t.Fatalf("got %d deposit addresses, expected 2", len(depositAddrs))
+	}
+}
+
+func TestAssignDepositAddressErrors(t *testing.T) {
+	client := newTestClient(t)
+	payoutAddr := "addr_test1vqqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgasfzjt"
+	if _, _, err := client.AssignDepositAddress("dave", payoutAddr); err != nil {
+		t.Fatalf("failed to assign deposit address: %s", err)
+	}
+	// Asking again with the same or no payout address is fine
+	for _, addr := range []string{payoutAddr, ""} {
+		depositAddr, _, err := client.AssignDepositAddress("dave", addr)
+		if err != nil {
+			t.Fatalf("failed to assign deposit address: %s", err)
+		}
+		if depositAddr.PayoutAddress != payoutAddr {
+			t.Fatalf("got payout address %q", depositAddr.PayoutAddress)
+		}
+	}
+	testDefs := []struct {
+		name          string
+		customerId    string
+		payoutAddress string
+		expectedError string
+	}{
+		{
+			name:          "missing customer ID",
+			expectedError: "no customer ID provided",
+		},
+		{
+			name:          "invalid payout address",
+			customerId:    "carol",
+			payoutAddress: "addr_test1invalid",
+			expectedError: "invalid payout address",
+		},
+		{
+			name:          "different payout address",
+			customerId:    "dave",
+			payoutAddress: "addr_test1vqpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqsw74k48",
+			expectedError: "customer already has a different payout address",
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			_, _, err := client.AssignDepositAddress(
+				testDef.customerId,
+				testDef.payoutAddress,
+			)
+			if err == nil ||
+				!strings.Contains(err.Error(), testDef.expectedError) {
+				t.Fatalf(
+					"got error %v, expected %q",
+					err,
+					testDef.expectedError,
+				)
+			}
+			// Invalid requests are the client's fault
+			reqBody, err := json.Marshal(
+				assignDepositAddressRequest{
+					CustomerId:    testDef.customerId,
+					PayoutAddress: testDef.payoutAddress,
+				},
+			)
+			if err != nil {
+				t.Fatalf("failed to encode request: %s", err)
+			}
+			rec := httptest.NewRecorder()
+			NewHandler("").ServeHTTP(
+				rec,
+				httptest.NewRequest(
+					http.MethodPost,
+					pathDepositAddresses,
+					bytes.NewReader(reqBody),
+				),
+			)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf(
+					"got status %d, expected %d",
+					rec.Code,
+					http.StatusBadRequest,
+				)
+			}
+		})
+	}
+}
+
+func TestClientNotRunning(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "admin.sock")
+	client, err := NewClient("unix://"+socketPath, "")
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	if _, err := client.ListDepositAddresses(); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("got error %v, expected %v", err, ErrNotRunning)
+	}
+	if _, err := NewClient("", ""); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("got error %v, expected %v", err, ErrNotRunning)
+	}
+}
+
+func TestToken(t *testing.T) {
+	testDefs := []struct {
+		name          string
+		token         string
+		expectedError string
+	}{
+		{
+			name:  "valid token",
+			token: testToken,
+		},
+		{
+			name:          "missing token",
+			expectedError: "invalid token",
+		},
+		{
+			name:          "wrong token",
+			token:         "wrong-token",
+			expectedError: "invalid token",
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			client := newTestClientWithToken(t, testDef.token)
+			_, err := client.ListDepositAddresses()
+			switch {
+			case testDef.expectedError != "":
+				if err == nil ||
+					!strings.Contains(err.Error(), testDef.expectedError) {
+					t.Fatalf(
+						"got error %v, expected %q",
+						err,
+						testDef.expectedError,
+					)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestStartRefusesPublicAddress(t *testing.T) {
+	if err := Start("0.0.0.0:0", testToken); err == nil {
+		t.Fatalf("did not get expected error")
+	}
+}
+
+func TestStartRequiresTokenForTcp(t *testing.T) {
+	err := Start("127.0.0.1:0", "")
+	if err == nil || !strings.Contains(err.Error(), "without a token") {
+		t.Fatalf("expected a missing token error, got: %v", err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	client := newTestClient(t)
+	if _, err := wallet.Setup(); err != nil {
+		t.Fatalf("failed to set up wallet: %s", err)
+	}
+	// Forget the deposit addresses assigned by other tests
+	if err := wallet.LoadDepositAddresses(); err != nil {
+		t.Fatalf("failed to load deposit addresses: %s", err)
+	}
+	// Errors from the TX builder are passed on to the client, including for
+	// addresses on another network
+	for _, destAddr := range []string{
+		"not-an-address",
+		"addr1vyqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqkdl5mw",
+	} {
+		if _, err := client.Sweep(destAddr); err == nil ||
+			!strings.Contains(err.Error(), "invalid destination address") {
+			t.Fatalf("expected an invalid destination error, got: %v", err)
+		}
+	}
+	// Without receive or deposit addresses, there's nothing to sweep
+	txIds, err := client.Sweep("")
+	if err != nil {
+		t.Fatalf("failed to sweep: %s", err)
+	}
+	if len(txIds) != 0 {
+		t.Fatalf("expected no TXs, got: %v", txIds)
+	}
+}
+
+func TestLookupTransaction(t *testing.T) {
+	client := newTestClient(t)
+	txs, err := client.ListTransactions()
+	if err != nil {
+		t.Fatalf("failed to list transactions: %s", err)
+	}
+	if len(txs) != 0 {
+		t.Fatalf("expected no transactions, got: %v", txs)
+	}
+	if err := storage.GetStorage().UpdateTransaction(
+		&storage.Transaction{
+			TxId:           "reward-tx",
+			Status:         storage.TxStatusSubmitted,
+			RewardTxHashes: []string{"deposit-tx"},
+		},
+	); err != nil {
+		t.Fatalf("failed to store transaction: %s", err)
+	}
+	for _, reward := range []storage.Reward{
+		{
+			TxHash:     "deposit-tx",
+			Status:     storage.RewardStatusSubmitted,
+			RewardTxId: "reward-tx",
+		},
+		{
+			TxHash: "pending-tx",
+			Status: storage.RewardStatusPending,
+		},
+	} {
+		if err := storage.GetStorage().UpdateReward(&reward); err != nil {
+			t.Fatalf("failed to store reward: %s", err)
+		}
+	}
+	txs, err = client.ListTransactions()
+	if err != nil {
+		t.Fatalf("failed to list transactions: %s", err)
+	}
+	if len(txs) != 1 || txs[0].TxId != "reward-tx" {
+		t.Fatalf("expected the reward TX, got: %v", txs)
+	}
+	testDefs := []struct {
+		txId          string
+		expectedTx    string
+		expectedState storage.RewardStatus
+		expectedErr   string
+	}{
+		{txId: "reward-tx", expectedTx: "reward-tx"},
+		// Looking up the triggering TX returns its reward and reward TX
+		{
+			txId:          "deposit-tx",
+			expectedTx:    "reward-tx",
+			expectedState: storage.RewardStatusSubmitted,
+		},
+		{txId: "pending-tx", expectedState: storage.RewardStatusPending},
+		{txId: "other-tx", expectedErr: "unknown transaction: other-tx"},
+	}
+	for _, testDef := range testDefs {
+		tx, reward, err := client.LookupTransaction(testDef.txId)
+		if testDef.expectedErr != "" {
+			if err == nil || err.Error() != testDef.expectedErr {
+				t.Fatalf(
+					"%s: expected error %q, got: %v",
+					testDef.txId,
+					testDef.expectedErr,
+					err,
+				)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: failed to look up transaction: %s", testDef.txId, err)
+		}
+		var txId string
+		if tx != nil {
+			txId = tx.TxId
+		}
+		if txId != testDef.expectedTx {
+			t.Fatalf(
+				"%s: got TX %q, expected %q",
+				testDef.txId,
+				txId,
+				testDef.expectedTx,
+			)
+		}
+		var state storage.RewardStatus
+		if reward != nil {
+			state = reward.Status
+		}
+		if state != testDef.expectedState {
+			t.Fatalf(
+				"%s: got reward status %q, expected %q",
+				testDef.txId,
+				state,
+				testDef.expectedState,
+			)
+		}
+	}
+}
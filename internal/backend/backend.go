@@ -0,0 +1,122 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/Salvionied/apollo/txBuilding/Backend/Base"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+)
+
+const (
+	BackendBlockfrost = "blockfrost"
+	BackendKupo       = "kupo"
+	BackendLocal      = "local"
+	BackendMemory     = "memory"
+	BackendOgmios     = "ogmios"
+)
+
+var ErrNotSupported = errors.New("not supported by chain backend")
+
+// ChainBackend provides the chain data needed to build transactions
+type ChainBackend interface {
+	// UtxosByAddress returns the unspent UTxOs for the specified address
+	UtxosByAddress(addr string) ([]UTxO.UTxO, error)
+	// UtxoByRef returns the UTxO for the specified ref, or nil if it isn't known
+	UtxoByRef(txId string, idx int) (*UTxO.UTxO, error)
+	// ProtocolParams returns the current protocol parameters
+	ProtocolParams() (Base.ProtocolParameters, error)
+	// Tip returns the most recent chain point known to the backend
+	Tip() (Tip, error)
+	// NetworkMagic returns the network magic of the chain the backend follows
+	NetworkMagic() (uint32, error)
+}
+
+// Tip is a chain point
+type Tip struct {
+	Slot uint64
+	Hash string
+}
+
+var globalBackend ChainBackend
+
+// Setup creates the chain backend specified in the config
+func Setup() (ChainBackend, error) {
+	// Return existing backend instance if available
+	if globalBackend != nil {
+		return globalBackend, nil
+	}
+	cfg := config.GetConfig()
+	backendName := cfg.TxBuilder.Backend
+	// Pick a backend based on the other config values if none was specified
+	if backendName == "" {
+		switch {
+		case cfg.TxBuilder.LocalUtxoSet:
+			backendName = BackendLocal
+		case cfg.TxBuilder.BlockfrostApiKey != "", cfg.TxBuilder.BlockfrostUrl != "":
+			backendName = BackendBlockfrost
+		case cfg.TxBuilder.KupoUrl != "":
+			backendName = BackendKupo
+		case cfg.TxBuilder.OgmiosUrl != "":
+			backendName = BackendOgmios
+		default:
+			return nil, errors.New(
+				"no valid Blockfrost, Kupo/Ogmios or local UTxO set config found",
+			)
+		}
+	}
+	var ret ChainBackend
+	var err error
+	switch backendName {
+	case BackendBlockfrost:
+		ret, err = NewBlockfrost(
+			cfg.Network,
+			cfg.TxBuilder.BlockfrostApiKey,
+			cfg.TxBuilder.BlockfrostUrl,
+		)
+	case BackendKupo:
+		ret, err = NewKupo(cfg.TxBuilder.KupoUrl)
+	case BackendOgmios:
+		ret, err = NewOgmios(cfg.TxBuilder.OgmiosUrl)
+	case BackendLocal:
+		ret = NewLocal()
+	case BackendMemory:
+		ret = NewMemory()
+	default:
+		return nil, fmt.Errorf("unknown chain backend: %s", backendName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNetwork(ret); err != nil {
+		return nil, err
+	}
+	globalBackend = ret
+	return globalBackend, nil
+}
+
+// GetBackend returns the global chain backend instance
+func GetBackend() ChainBackend {
+	return globalBackend
+}
+
+// SetBackend replaces the global chain backend instance, such as with an
+// in-memory backend for testing
+func SetBackend(backend ChainBackend) {
+	globalBackend = backend
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Salvionied/apollo/constants"
+	serAddress "github.com/Salvionied/apollo/serialization/Address"
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/Salvionied/apollo/txBuilding/Backend/Base"
+	"github.com/Salvionied/apollo/txBuilding/Backend/BlockFrostChainContext"
+)
+
+const (
+	// Maximum time to wait for a response from the Blockfrost API
+	blockfrostTimeout = 30 * time.Second
+)
+
+// Blockfrost is a chain backend using the Blockfrost API
+type Blockfrost struct {
+	context *BlockFrostChainContext.BlockFrostChainContext
+	baseUrl string
+	apiKey  string
+}
+
+// NewBlockfrost returns a Blockfrost chain backend for the specified network.
+// The public Blockfrost API for the network is used unless a base URL for a
+// self-hosted Blockfrost-compatible API is provided
+func NewBlockfrost(
+	network string,
+	apiKey string,
+	baseUrl string,
+) (*Blockfrost, error) {
+	var bfNetwork constants.Network
+	var defaultUrl string
+	switch network {
+	case "mainnet":
+		bfNetwork = constants.MAINNET
+		defaultUrl = constants.BLOCKFROST_BASE_URL_MAINNET
+	case "preprod":
+		bfNetwork = constants.PREPROD
+		defaultUrl = constants.BLOCKFROST_BASE_URL_PREPROD
+	case "preview":
+		bfNetwork = constants.PREVIEW
+		defaultUrl = constants.BLOCKFROST_BASE_URL_PREVIEW
+	default:
+		// Other testnets are only available via a custom base URL
+		bfNetwork = constants.TESTNET
+	}
+	if baseUrl == "" {
+		if defaultUrl == "" {
+			return nil, fmt.Errorf(
+				"no public Blockfrost API for network %s, a base URL must be provided",
+				network,
+			)
+		}
+		baseUrl = defaultUrl
+	}
+	bfc, err := BlockFrostChainContext.NewBlockfrostChainContext(
+		baseUrl,
+		int(bfNetwork),
+		apiKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Apollo adds the API version for the public Blockfrost API
+	if strings.Contains(baseUrl, "blockfrost.io") {
+		baseUrl += "/v0"
+	}
+	return &Blockfrost{
+		context: &bfc,
+		baseUrl: baseUrl,
+		apiKey:  apiKey,
+	}, nil
+}
+
+func (b *Blockfrost) UtxosByAddress(addr string) ([]UTxO.UTxO, error) {
+	serAddr, err := serAddress.DecodeAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	return b.context.Utxos(serAddr)
+}
+
+func (b *Blockfrost) UtxoByRef(txId string, idx int) (*UTxO.UTxO, error) {
+	return b.context.GetUtxoFromRef(txId, idx)
+}
+
+func (b *Blockfrost) ProtocolParams() (Base.ProtocolParameters, error) {
+	return b.context.GetProtocolParams()
+}
+
+func (b *Blockfrost) Tip() (Tip, error) {
+	block, err := b.context.LatestBlock()
+	if err != nil {
+		return Tip{}, err
+	}
+	return Tip{
+		Slot: uint64(block.Slot), // #nosec G115
+		Hash: block.Hash,
+	}, nil
+}
+
+func (b *Blockfrost) NetworkMagic() (uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), blockfrostTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		b.baseUrl+"/genesis",
+		nil,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if b.apiKey != "" {
+		req.Header.Set("project_id", b.apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req) // #nosec G704
+	if err != nil {
+		return 0, fmt.Errorf("failed to query genesis: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf(
+			"failed to query genesis: unexpected status %d",
+			resp.StatusCode,
+		)
+	}
+	var genesis Base.GenesisParameters
+	if err := json.NewDecoder(resp.Body).Decode(&genesis); err != nil {
+		return 0, fmt.Errorf("failed to decode genesis: %w", err)
+	}
+	return uint32(genesis.NetworkMagic), nil // #nosec G115
+}
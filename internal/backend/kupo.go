@@ -0,0 +1,163 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	serAddress "github.com/Salvionied/apollo/serialization/Address"
+	"github.com/Salvionied/apollo/serialization/Asset"
+	"github.com/Salvionied/apollo/serialization/AssetName"
+	"github.com/Salvionied/apollo/serialization/MultiAsset"
+	"github.com/Salvionied/apollo/serialization/Policy"
+	"github.com/Salvionied/apollo/serialization/TransactionInput"
+	"github.com/Salvionied/apollo/serialization/TransactionOutput"
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/Salvionied/apollo/serialization/Value"
+	"github.com/Salvionied/apollo/txBuilding/Backend/Base"
+	"github.com/SundaeSwap-finance/kugo"
+)
+
+// Kupo is a chain backend using a Kupo instance. Kupo doesn't provide
+// protocol parameters
+type Kupo struct {
+	client *kugo.Client
+}
+
+// NewKupo returns a Kupo chain backend for the specified URL
+func NewKupo(kupoUrl string) (*Kupo, error) {
+	if kupoUrl == "" {
+		return nil, errors.New("no kupo url provided")
+	}
+	k := kugo.New(
+		kugo.WithEndpoint(kupoUrl),
+	)
+	if k == nil {
+		return nil, fmt.Errorf("failed kupo client: %s", kupoUrl)
+	}
+	return &Kupo{
+		client: k,
+	}, nil
+}
+
+func (k *Kupo) UtxosByAddress(addr string) ([]UTxO.UTxO, error) {
+	matches, err := k.client.Matches(
+		context.Background(),
+		kugo.OnlyUnspent(),
+		kugo.Pattern(addr),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var ret []UTxO.UTxO
+	for _, match := range matches {
+		tmpUtxo := kupoMatchToApolloUtxo(match)
+		ret = append(ret, tmpUtxo)
+	}
+	return ret, nil
+}
+
+func (k *Kupo) UtxoByRef(txId string, idx int) (*UTxO.UTxO, error) {
+	matches, err := k.client.Matches(
+		context.Background(),
+		kugo.Pattern(
+			fmt.Sprintf("%d@%s", idx, txId),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		slog.Warn(
+			fmt.Sprintf(
+				"could not lookup TX input ref %d@%s in kupo (wrong network?)",
+				idx,
+				txId,
+			),
+		)
+		return nil, nil
+	}
+	ret := kupoMatchToApolloUtxo(matches[0])
+	return &ret, nil
+}
+
+func (k *Kupo) ProtocolParams() (Base.ProtocolParameters, error) {
+	return Base.ProtocolParameters{}, ErrNotSupported
+}
+
+func (k *Kupo) Tip() (Tip, error) {
+	points, err := k.client.Checkpoints(context.Background())
+	if err != nil {
+		return Tip{}, err
+	}
+	if len(points) == 0 {
+		return Tip{}, errors.New("kupo has no checkpoints")
+	}
+	// Checkpoints are returned newest first
+	return Tip{
+		Slot: uint64(points[0].SlotNo), // #nosec G115
+		Hash: points[0].HeaderHash,
+	}, nil
+}
+
+func (k *Kupo) NetworkMagic() (uint32, error) {
+	// Kupo doesn't expose the network it follows
+	return 0, ErrNotSupported
+}
+
+func kupoMatchToApolloUtxo(match kugo.Match) UTxO.UTxO {
+	serAddr, _ := serAddress.DecodeAddress(match.Address)
+	txIdBytes, _ := hex.DecodeString(match.TransactionID)
+	multiAssets := make(MultiAsset.MultiAsset[int64])
+	totalLovelace := uint64(0)
+	for policyId, assets := range match.Value {
+		for assetId, assetAmount := range assets {
+			if policyId == "ada" && assetId == "lovelace" {
+				totalLovelace = assetAmount.Uint64()
+				continue
+			}
+			tmpPolicyId := Policy.PolicyId{Value: policyId}
+			// Kupo returns hex-encoded asset names
+			tmpAssetName := AssetName.NewAssetNameFromHexString(assetId)
+			if tmpAssetName == nil {
+				continue
+			}
+			if _, ok := multiAssets[tmpPolicyId]; !ok {
+				multiAssets[tmpPolicyId] = Asset.Asset[int64]{}
+			}
+			multiAssets[tmpPolicyId][*tmpAssetName] = assetAmount.Int64()
+		}
+	}
+	val := Value.SimpleValue(
+		// all the lovelace wouldn't overflow this
+		int64(totalLovelace), // #nosec G115
+		multiAssets,
+	)
+	ret := UTxO.UTxO{
+		Input: TransactionInput.TransactionInput{
+			TransactionId: txIdBytes,
+			Index:         match.OutputIndex,
+		},
+		Output: TransactionOutput.SimpleTransactionOutput(
+			serAddr,
+			val,
+		),
+	}
+	return ret
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/Salvionied/apollo/serialization/TransactionInput"
+	"github.com/Salvionied/apollo/serialization/TransactionOutput"
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/Salvionied/apollo/txBuilding/Backend/Base"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+)
+
+// Local is a chain backend using the local UTxO set maintained by the
+// indexer. It only knows about UTxOs for watched addresses
+type Local struct{}
+
+// NewLocal returns a chain backend for the local UTxO set
+func NewLocal() *Local {
+	return &Local{}
+}
+
+func (l *Local) UtxosByAddress(addr string) ([]UTxO.UTxO, error) {
+	localUtxos, err := storage.GetStorage().GetUtxosByAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]UTxO.UTxO, 0, len(localUtxos))
+	for _, localUtxo := range localUtxos {
+		tmpUtxo, err := localUtxoToApolloUtxo(localUtxo)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, tmpUtxo)
+	}
+	return ret, nil
+}
+
+func (l *Local) UtxoByRef(txId string, idx int) (*UTxO.UTxO, error) {
+	localUtxo, err := storage.GetStorage().GetUtxo(
+		txId,
+		uint32(idx), // #nosec G115
+	)
+	if err != nil {
+		return nil, err
+	}
+	if localUtxo == nil {
+		return nil, nil
+	}
+	ret, err := localUtxoToApolloUtxo(*localUtxo)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (l *Local) ProtocolParams() (Base.ProtocolParameters, error) {
+	return Base.ProtocolParameters{}, ErrNotSupported
+}
+
+func (l *Local) Tip() (Tip, error) {
+	cursor, err := storage.GetStorage().GetCursor()
+	if err != nil {
+		return Tip{}, err
+	}
+	if len(cursor) == 0 {
+		return Tip{}, errors.New("indexer has not processed any blocks yet")
+	}
+	return Tip{
+		Slot: cursor[0].Slot,
+		Hash: cursor[0].Hash,
+	}, nil
+}
+
+func (l *Local) NetworkMagic() (uint32, error) {
+	// The UTxO set comes from the indexer, and the network magic is already
+	// checked during the handshake with the node
+	return 0, ErrNotSupported
+}
+
+func localUtxoToApolloUtxo(localUtxo storage.Utxo) (UTxO.UTxO, error) {
+	txIdBytes, err := hex.DecodeString(localUtxo.TxHash)
+	if err != nil {
+		return UTxO.UTxO{}, err
+	}
+	var output TransactionOutput.TransactionOutput
+	if err := output.UnmarshalCBOR(localUtxo.Output); err != nil {
+		return UTxO.UTxO{}, fmt.Errorf(
+			"failed to decode UTxO %s#%d: %w",
+			localUtxo.TxHash,
+			localUtxo.Index,
+			err,
+		)
+	}
+	ret := UTxO.UTxO{
+		Input: TransactionInput.TransactionInput{
+			TransactionId: txIdBytes,
+			Index:         int(localUtxo.Index),
+		},
+		Output: output,
+	}
+	return ret, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"encoding/hex"
+	"slices"
+	"sync"
+
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/Salvionied/apollo/txBuilding/Backend/Base"
+	"github.com/Salvionied/apollo/txBuilding/Backend/FixedChainContext"
+)
+
+// Memory is an in-memory chain backend, mostly useful for testing. It
+// starts out with no UTxOs and placeholder protocol parameters
+type Memory struct {
+	mutex          sync.Mutex
+	utxos          []UTxO.UTxO
+	protocolParams Base.ProtocolParameters
+	tip            Tip
+}
+
+// NewMemory returns an empty in-memory chain backend
+func NewMemory() *Memory {
+	// The fixed chain context never returns an error
+	pp, _ := FixedChainContext.InitFixedChainContext().GetProtocolParams()
+	return &Memory{
+		protocolParams: pp,
+	}
+}
+
+// AddUtxos adds the provided UTxOs
+func (m *Memory) AddUtxos(utxos ...UTxO.UTxO) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.utxos = append(m.utxos, utxos...)
+}
+
+// RemoveUtxo removes the UTxO with the specified ref
+func (m *Memory) RemoveUtxo(txId string, idx int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.utxos = slices.DeleteFunc(
+		m.utxos,
+		func(utxo UTxO.UTxO) bool {
+			return hex.EncodeToString(utxo.Input.TransactionId) == txId &&
+				utxo.Input.Index == idx
+		},
+	)
+}
+
+// SetProtocolParams sets the protocol parameters
+func (m *Memory) SetProtocolParams(pp Base.ProtocolParameters) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.protocolParams = pp
+}
+
+// SetTip sets the chain tip
+func (m *Memory) SetTip(tip Tip) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tip = tip
+}
+
+func (m *Memory) UtxosByAddress(addr string) ([]UTxO.UTxO, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var ret []UTxO.UTxO
+	for _, utxo := range m.utxos {
+		if utxo.Output.GetAddress().String() == addr {
+			ret = append(ret, utxo)
+		}
+	}
+	return ret, nil
+}
+
+func (m *Memory) UtxoByRef(txId string, idx int) (*UTxO.UTxO, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, utxo := range m.utxos {
+		if hex.EncodeToString(utxo.Input.TransactionId) == txId &&
+			utxo.Input.Index == idx {
+			ret := utxo
+			return &ret, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Memory) ProtocolParams() (Base.ProtocolParameters, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.protocolParams, nil
+}
+
+func (m *Memory) Tip() (Tip, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.tip, nil
+}
+
+func (m *Memory) NetworkMagic() (uint32, error) {
+	return 0, ErrNotSupported
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// checkNetwork makes sure that the chain backend and the wallet and reward
+// addresses belong to the configured network
+func checkNetwork(backend ChainBackend) error {
+	cfg := config.GetConfig()
+	network, ok := ouroboros.NetworkByName(cfg.Network)
+	if !ok {
+		return fmt.Errorf("unknown network: %s", cfg.Network)
+	}
+	magic, err := backend.NetworkMagic()
+	if err != nil {
+		if !errors.Is(err, ErrNotSupported) {
+			return fmt.Errorf("failed to get chain backend network: %w", err)
+		}
+		slog.Debug(
+			"chain backend doesn't provide its network, skipping network check",
+		)
+	} else if magic != network.NetworkMagic {
+		return fmt.Errorf(
+			"chain backend network magic %d does not match network %s (%d)",
+			magic,
+			cfg.Network,
+			network.NetworkMagic,
+		)
+	}
+	addresses := map[string]string{
+		"reward address": cfg.Reward.RewardAddress,
+		"source address": cfg.Reward.SourceAddress,
+	}
+	if w := wallet.GetWallet(); w != nil {
+		addresses["wallet address"] = w.PaymentAddress
+	}
+	for name, addr := range addresses {
+		if addr == "" {
+			continue
+		}
+		if err := checkAddressNetwork(network, addr); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkAddressNetwork makes sure that an address belongs to the network
+func checkAddressNetwork(network ouroboros.Network, addr string) error {
+	tmpAddr, err := lcommon.NewAddress(addr)
+	if err != nil {
+		return fmt.Errorf("failed to decode address %s: %w", addr, err)
+	}
+	if tmpAddr.NetworkId() != uint(network.Id) {
+		return fmt.Errorf(
+			"address %s does not belong to network %s",
+			addr,
+			network.Name,
+		)
+	}
+	return nil
+}
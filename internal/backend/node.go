@@ -0,0 +1,100 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Salvionied/apollo/txBuilding/Backend/Base"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/chain"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger/babbage"
+	"github.com/blinklabs-io/gouroboros/ledger/conway"
+)
+
+// NodeProtocolParams queries the current protocol parameters from the local
+// node at the specified socket path
+func NodeProtocolParams(
+	socketPath string,
+	network string,
+) (Base.ProtocolParameters, error) {
+	pparams, err := chain.QueryProtocolParams(socketPath, network)
+	if err != nil {
+		return Base.ProtocolParameters{}, err
+	}
+	switch p := pparams.(type) {
+	case *conway.ConwayProtocolParameters:
+		return conwayProtocolParams(p), nil
+	case *babbage.BabbageProtocolParameters:
+		// The Babbage parameters are a subset of the Conway ones
+		upgraded := conway.UpgradePParams(*p)
+		return conwayProtocolParams(&upgraded), nil
+	default:
+		return Base.ProtocolParameters{}, fmt.Errorf(
+			"unsupported protocol parameters type: %T",
+			pparams,
+		)
+	}
+}
+
+// conwayProtocolParams converts Conway protocol parameters from the node to
+// the format used by apollo
+func conwayProtocolParams(
+	p *conway.ConwayProtocolParameters,
+) Base.ProtocolParameters {
+	return Base.ProtocolParameters{
+		MinFeeConstant:       int64(p.MinFeeB),          // #nosec G115
+		MinFeeCoefficient:    int64(p.MinFeeA),          // #nosec G115
+		MaxBlockSize:         int(p.MaxBlockBodySize),   // #nosec G115
+		MaxTxSize:            int(p.MaxTxSize),          // #nosec G115
+		MaxBlockHeaderSize:   int(p.MaxBlockHeaderSize), // #nosec G115
+		KeyDeposits:          strconv.FormatUint(uint64(p.KeyDeposit), 10),
+		PoolDeposits:         strconv.FormatUint(uint64(p.PoolDeposit), 10),
+		ProtocolMajorVersion: int(p.ProtocolVersion.Major), // #nosec G115
+		ProtocolMinorVersion: int(p.ProtocolVersion.Minor), // #nosec G115
+		MinPoolCost:          strconv.FormatUint(p.MinPoolCost, 10),
+		PriceMem:             ratToFloat(p.ExecutionCosts.MemPrice),
+		PriceStep:            ratToFloat(p.ExecutionCosts.StepPrice),
+		MaxTxExMem:           strconv.FormatInt(p.MaxTxExUnits.Memory, 10),
+		MaxTxExSteps:         strconv.FormatInt(p.MaxTxExUnits.Steps, 10),
+		MaxBlockExMem:        strconv.FormatInt(p.MaxBlockExUnits.Memory, 10),
+		MaxBlockExSteps:      strconv.FormatInt(p.MaxBlockExUnits.Steps, 10),
+		MaxValSize:           strconv.FormatUint(uint64(p.MaxValueSize), 10),
+		CollateralPercent:    int(p.CollateralPercentage), // #nosec G115
+		MaxCollateralInuts:   int(p.MaxCollateralInputs),  // #nosec G115
+		CoinsPerUtxoByte:     strconv.FormatUint(p.AdaPerUtxoByte, 10),
+		CoinsPerUtxoWord:     strconv.FormatUint(p.AdaPerUtxoByte, 10),
+		CostModels:           nodeCostModels(p.CostModels),
+	}
+}
+
+// nodeCostModels converts cost models keyed by Plutus language to the format
+// used by Blockfrost
+func nodeCostModels(costModels map[uint][]int64) map[string][]int64 {
+	ret := make(map[string][]int64, len(costModels))
+	for language, costModel := range costModels {
+		ret[fmt.Sprintf("PlutusV%d", language+1)] = costModel
+	}
+	return ret
+}
+
+func ratToFloat(r *cbor.Rat) float32 {
+	if r == nil {
+		return 0
+	}
+	ret, _ := r.ToBigRat().Float32()
+	return ret
+}
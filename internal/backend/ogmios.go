@@ -0,0 +1,276 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/Salvionied/apollo/txBuilding/Backend/Base"
+	"github.com/Salvionied/apollo/txBuilding/Backend/OgmiosChainContext"
+	"github.com/SundaeSwap-finance/ogmigo/v6"
+	"github.com/SundaeSwap-finance/ogmigo/v6/ouroboros/chainsync"
+	"github.com/SundaeSwap-finance/ogmigo/v6/ouroboros/shared"
+)
+
+const (
+	// Maximum time to wait for a response to an Ogmios state query
+	ogmiosQueryTimeout = 30 * time.Second
+)
+
+// Ogmios is a chain backend using the Ogmios state query API
+type Ogmios struct {
+	client *ogmigo.Client
+}
+
+// NewOgmios returns an Ogmios chain backend for the specified WebSocket URL
+func NewOgmios(ogmiosUrl string) (*Ogmios, error) {
+	if ogmiosUrl == "" {
+		return nil, errors.New("no ogmios url provided")
+	}
+	client := ogmigo.New(
+		ogmigo.WithEndpoint(ogmiosUrl),
+	)
+	return &Ogmios{
+		client: client,
+	}, nil
+}
+
+func (o *Ogmios) UtxosByAddress(addr string) ([]UTxO.UTxO, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ogmiosQueryTimeout)
+	defer cancel()
+	utxos, err := o.client.UtxosByAddress(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]UTxO.UTxO, 0, len(utxos))
+	for _, utxo := range utxos {
+		tmpUtxo, err := OgmiosChainContext.Utxo_OgmigoToApollo(utxo)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, tmpUtxo)
+	}
+	return ret, nil
+}
+
+// UtxoByRef returns the UTxO for the specified ref. Ogmios only knows about
+// unspent outputs, so this returns nil for spent ones. Deposit TX inputs are
+// resolved from the outputs provided by adder, and only looked up here when
+// adder doesn't provide them
+func (o *Ogmios) UtxoByRef(txId string, idx int) (*UTxO.UTxO, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ogmiosQueryTimeout)
+	defer cancel()
+	utxos, err := o.client.UtxosByTxIn(
+		ctx,
+		chainsync.TxInQuery{
+			Transaction: shared.UtxoTxID{ID: txId},
+			Index:       uint32(idx), // #nosec G115
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(utxos) == 0 {
+		return nil, nil
+	}
+	ret, err := OgmiosChainContext.Utxo_OgmigoToApollo(utxos[0])
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (o *Ogmios) ProtocolParams() (Base.ProtocolParameters, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ogmiosQueryTimeout)
+	defer cancel()
+	raw, err := o.client.CurrentProtocolParameters(ctx)
+	if err != nil {
+		return Base.ProtocolParameters{}, err
+	}
+	var params ogmiosProtocolParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return Base.ProtocolParameters{}, fmt.Errorf(
+			"failed to parse ogmios protocol parameters: %w",
+			err,
+		)
+	}
+	return params.toApollo()
+}
+
+func (o *Ogmios) Tip() (Tip, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ogmiosQueryTimeout)
+	defer cancel()
+	point, err := o.client.ChainTip(ctx)
+	if err != nil {
+		return Tip{}, err
+	}
+	pointStruct, ok := point.PointStruct()
+	if !ok {
+		return Tip{}, fmt.Errorf("ogmios returned unexpected tip: %s", point)
+	}
+	return Tip{
+		Slot: pointStruct.Slot,
+		Hash: pointStruct.ID,
+	}, nil
+}
+
+func (o *Ogmios) NetworkMagic() (uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ogmiosQueryTimeout)
+	defer cancel()
+	raw, err := o.client.GenesisConfig(ctx, "shelley")
+	if err != nil {
+		return 0, err
+	}
+	var genesis struct {
+		NetworkMagic uint32 `json:"networkMagic"`
+	}
+	if err := json.Unmarshal(raw, &genesis); err != nil {
+		return 0, fmt.Errorf("failed to parse ogmios genesis config: %w", err)
+	}
+	return genesis.NetworkMagic, nil
+}
+
+// ogmiosLovelace is an Ogmios v6 lovelace amount
+type ogmiosLovelace struct {
+	Ada struct {
+		Lovelace uint64 `json:"lovelace"`
+	} `json:"ada"`
+}
+
+// ogmiosBytes is an Ogmios v6 size
+type ogmiosBytes struct {
+	Bytes uint64 `json:"bytes"`
+}
+
+type ogmiosExUnits struct {
+	Memory uint64 `json:"memory"`
+	Cpu    uint64 `json:"cpu"`
+}
+
+// ogmiosProtocolParams contains the protocol parameters returned by the
+// Ogmios v6 queryLedgerState/protocolParameters query that are needed to
+// build transactions
+type ogmiosProtocolParams struct {
+	MinFeeCoefficient               uint64         `json:"minFeeCoefficient"`
+	MinFeeConstant                  ogmiosLovelace `json:"minFeeConstant"`
+	MaxBlockBodySize                ogmiosBytes    `json:"maxBlockBodySize"`
+	MaxBlockHeaderSize              ogmiosBytes    `json:"maxBlockHeaderSize"`
+	MaxTransactionSize              ogmiosBytes    `json:"maxTransactionSize"`
+	MaxValueSize                    ogmiosBytes    `json:"maxValueSize"`
+	StakeCredentialDeposit          ogmiosLovelace `json:"stakeCredentialDeposit"`
+	StakePoolDeposit                ogmiosLovelace `json:"stakePoolDeposit"`
+	MinStakePoolCost                ogmiosLovelace `json:"minStakePoolCost"`
+	MinUtxoDepositCoefficient       uint64         `json:"minUtxoDepositCoefficient"`
+	MinUtxoDepositConstant          ogmiosLovelace `json:"minUtxoDepositConstant"`
+	CollateralPercentage            uint64         `json:"collateralPercentage"`
+	MaxCollateralInputs             uint64         `json:"maxCollateralInputs"`
+	MaxExecutionUnitsPerTransaction ogmiosExUnits  `json:"maxExecutionUnitsPerTransaction"`
+	MaxExecutionUnitsPerBlock       ogmiosExUnits  `json:"maxExecutionUnitsPerBlock"`
+	ScriptExecutionPrices           struct {
+		Memory string `json:"memory"`
+		Cpu    string `json:"cpu"`
+	} `json:"scriptExecutionPrices"`
+	MinFeeReferenceScripts struct {
+		Range      float64 `json:"range"`
+		Base       float64 `json:"base"`
+		Multiplier float64 `json:"multiplier"`
+	} `json:"minFeeReferenceScripts"`
+	MaxReferenceScriptsSize ogmiosBytes `json:"maxReferenceScriptsSize"`
+	Version                 struct {
+		Major int `json:"major"`
+		Minor int `json:"minor"`
+	} `json:"version"`
+	PlutusCostModels map[string][]int64 `json:"plutusCostModels"`
+}
+
+func (p ogmiosProtocolParams) toApollo() (Base.ProtocolParameters, error) {
+	if p.MinFeeCoefficient == 0 || p.MaxTransactionSize.Bytes == 0 {
+		return Base.ProtocolParameters{}, errors.New(
+			"ogmios protocol parameters are missing fee or size values",
+		)
+	}
+	priceMem, err := parseRatio(p.ScriptExecutionPrices.Memory)
+	if err != nil {
+		return Base.ProtocolParameters{}, err
+	}
+	priceStep, err := parseRatio(p.ScriptExecutionPrices.Cpu)
+	if err != nil {
+		return Base.ProtocolParameters{}, err
+	}
+	// Apollo and Blockfrost use the Plutus version as the cost model key
+	costModels := make(map[string][]int64, len(p.PlutusCostModels))
+	for version, costModel := range p.PlutusCostModels {
+		key := strings.ToUpper(
+			strings.TrimPrefix(version, "plutus:"),
+		)
+		costModels["Plutus"+key] = costModel
+	}
+	return Base.ProtocolParameters{
+		MinFeeConstant:                   int64(p.MinFeeConstant.Ada.Lovelace), // #nosec G115
+		MinFeeCoefficient:                int64(p.MinFeeCoefficient),           // #nosec G115
+		MaxBlockSize:                     int(p.MaxBlockBodySize.Bytes),        // #nosec G115
+		MaxTxSize:                        int(p.MaxTransactionSize.Bytes),      // #nosec G115
+		MaxBlockHeaderSize:               int(p.MaxBlockHeaderSize.Bytes),      // #nosec G115
+		KeyDeposits:                      strconv.FormatUint(p.StakeCredentialDeposit.Ada.Lovelace, 10),
+		PoolDeposits:                     strconv.FormatUint(p.StakePoolDeposit.Ada.Lovelace, 10),
+		ProtocolMajorVersion:             p.Version.Major,
+		ProtocolMinorVersion:             p.Version.Minor,
+		MinUtxo:                          strconv.FormatUint(p.MinUtxoDepositConstant.Ada.Lovelace, 10),
+		MinPoolCost:                      strconv.FormatUint(p.MinStakePoolCost.Ada.Lovelace, 10),
+		PriceMem:                         priceMem,
+		PriceStep:                        priceStep,
+		MaxTxExMem:                       strconv.FormatUint(p.MaxExecutionUnitsPerTransaction.Memory, 10),
+		MaxTxExSteps:                     strconv.FormatUint(p.MaxExecutionUnitsPerTransaction.Cpu, 10),
+		MaxBlockExMem:                    strconv.FormatUint(p.MaxExecutionUnitsPerBlock.Memory, 10),
+		MaxBlockExSteps:                  strconv.FormatUint(p.MaxExecutionUnitsPerBlock.Cpu, 10),
+		MaxValSize:                       strconv.FormatUint(p.MaxValueSize.Bytes, 10),
+		CollateralPercent:                int(p.CollateralPercentage), // #nosec G115
+		MaxCollateralInuts:               int(p.MaxCollateralInputs),  // #nosec G115
+		CoinsPerUtxoByte:                 strconv.FormatUint(p.MinUtxoDepositCoefficient, 10),
+		CoinsPerUtxoWord:                 strconv.FormatUint(p.MinUtxoDepositCoefficient, 10),
+		CostModels:                       costModels,
+		MaximumReferenceScriptsSize:      int(p.MaxReferenceScriptsSize.Bytes), // #nosec G115
+		MinFeeReferenceScriptsRange:      int(p.MinFeeReferenceScripts.Range),
+		MinFeeReferenceScriptsBase:       int(p.MinFeeReferenceScripts.Base),
+		MinFeeReferenceScriptsMultiplier: int(p.MinFeeReferenceScripts.Multiplier),
+	}, nil
+}
+
+// parseRatio parses a ratio in the format "<numerator>/<denominator>"
+func parseRatio(ratio string) (float32, error) {
+	if ratio == "" {
+		return 0, nil
+	}
+	num, den, ok := strings.Cut(ratio, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid ratio: %s", ratio)
+	}
+	numVal, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ratio: %s: %w", ratio, err)
+	}
+	denVal, err := strconv.ParseFloat(den, 64)
+	if err != nil || denVal == 0 {
+		return 0, fmt.Errorf("invalid ratio: %s", ratio)
+	}
+	return float32(numVal / denVal), nil
+}
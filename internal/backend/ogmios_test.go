@@ -0,0 +1,269 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	testOgmiosAddress = "addr_test1vqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqd9tg5t"
+	testOgmiosTxId    = "3a0e8bd3d4b5f7c2a1e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7"
+)
+
+type ogmiosTestRequest struct {
+	Method string `json:"method"`
+	Params struct {
+		Addresses        []string `json:"addresses"`
+		OutputReferences []struct {
+			Transaction struct {
+				Id string `json:"id"`
+			} `json:"transaction"`
+			Index uint32 `json:"index"`
+		} `json:"outputReferences"`
+	} `json:"params"`
+}
+
+type ogmiosTestUtxo struct {
+	Transaction struct {
+		Id string `json:"id"`
+	} `json:"transaction"`
+	Index uint32 `json:"index"`
+}
+
+// newOgmiosTestServer returns a WebSocket server that replays the Ogmios
+// responses recorded in testdata/ogmios, named after the request method.
+// UTxO queries by output reference only return the matching recorded UTxOs
+func newOgmiosTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var upgrader websocket.Upgrader
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("failed to upgrade connection: %s", err)
+				return
+			}
+			defer conn.Close()
+			var req ogmiosTestRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				t.Errorf("failed to read request: %s", err)
+				return
+			}
+			_, name, _ := strings.Cut(req.Method, "/")
+			data, err := os.ReadFile(
+				filepath.Join("testdata", "ogmios", name+".json"),
+			)
+			if err != nil {
+				t.Errorf("no recorded response for %s: %s", req.Method, err)
+				return
+			}
+			if len(req.Params.OutputReferences) > 0 {
+				data, err = filterOgmiosUtxos(data, req)
+				if err != nil {
+					t.Errorf("failed to filter UTxOs: %s", err)
+					return
+				}
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				t.Errorf("failed to write response: %s", err)
+			}
+		}),
+	)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// filterOgmiosUtxos returns the recorded UTxO response with only the UTxOs
+// for the requested output references
+func filterOgmiosUtxos(data []byte, req ogmiosTestRequest) ([]byte, error) {
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	var utxos []json.RawMessage
+	if err := json.Unmarshal(resp["result"], &utxos); err != nil {
+		return nil, err
+	}
+	result := []json.RawMessage{}
+	for _, utxo := range utxos {
+		var ref ogmiosTestUtxo
+		if err := json.Unmarshal(utxo, &ref); err != nil {
+			return nil, err
+		}
+		for _, outRef := range req.Params.OutputReferences {
+			if outRef.Transaction.Id == ref.Transaction.Id &&
+				outRef.Index == ref.Index {
+				result = append(result, utxo)
+			}
+		}
+	}
+	var err error
+	resp["result"], err = json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
+
+func newTestOgmios(t *testing.T) *Ogmios {
+	t.Helper()
+	server := newOgmiosTestServer(t)
+	o, err := NewOgmios("ws" + strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatalf("failed to create Ogmios backend: %s", err)
+	}
+	return o
+}
+
+func TestOgmiosUtxosByAddress(t *testing.T) {
+	o := newTestOgmios(t)
+	utxos, err := o.UtxosByAddress(testOgmiosAddress)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(utxos) != 2 {
+		t.Fatalf("got %d UTxOs, expected 2", len(utxos))
+	}
+	if lovelace := utxos[0].Output.Lovelace(); lovelace != 5_000_000 {
+		t.Errorf("got %d lovelace, expected 5000000", lovelace)
+	}
+	if amount := utxos[1].Output.GetAmount().GetAssets(); len(amount) != 1 {
+		t.Errorf("got %d policies, expected 1", len(amount))
+	}
+}
+
+func TestOgmiosUtxoByRef(t *testing.T) {
+	o := newTestOgmios(t)
+	testDefs := []struct {
+		name             string
+		txId             string
+		idx              int
+		expectedLovelace int64
+		// Spent or unknown outputs aren't returned by Ogmios
+		expectNil bool
+	}{
+		{
+			name:             "unspent output",
+			txId:             testOgmiosTxId,
+			idx:              1,
+			expectedLovelace: 1_500_000,
+		},
+		{
+			name:      "unknown output index",
+			txId:      testOgmiosTxId,
+			idx:       2,
+			expectNil: true,
+		},
+		{
+			name:      "spent output",
+			txId:      strings.Repeat("0", 64),
+			idx:       0,
+			expectNil: true,
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			utxo, err := o.UtxoByRef(testDef.txId, testDef.idx)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if testDef.expectNil {
+				if utxo != nil {
+					t.Fatalf("got UTxO, expected nil")
+				}
+				return
+			}
+			if utxo == nil {
+				t.Fatalf("got nil, expected UTxO")
+			}
+			if utxo.Input.Index != testDef.idx {
+				t.Errorf("got index %d, expected %d", utxo.Input.Index, testDef.idx)
+			}
+			if lovelace := utxo.Output.Lovelace(); lovelace != testDef.expectedLovelace {
+				t.Errorf(
+					"got %d lovelace, expected %d",
+					lovelace,
+					testDef.expectedLovelace,
+				)
+			}
+			if addr := utxo.Output.GetAddress().String(); addr != testOgmiosAddress {
+				t.Errorf("got address %s, expected %s", addr, testOgmiosAddress)
+			}
+		})
+	}
+}
+
+func TestOgmiosProtocolParams(t *testing.T) {
+	o := newTestOgmios(t)
+	pp, err := o.ProtocolParams()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if pp.MinFeeCoefficient != 44 || pp.MinFeeConstant != 155381 {
+		t.Errorf(
+			"got fee params %d/%d, expected 44/155381",
+			pp.MinFeeCoefficient,
+			pp.MinFeeConstant,
+		)
+	}
+	if pp.MaxTxSize != 16384 {
+		t.Errorf("got max TX size %d, expected 16384", pp.MaxTxSize)
+	}
+	if pp.CoinsPerUtxoByte != "4310" {
+		t.Errorf("got coins per UTxO byte %s, expected 4310", pp.CoinsPerUtxoByte)
+	}
+	if pp.PriceMem != float32(577.0/10000.0) {
+		t.Errorf("got memory price %f, expected 0.0577", pp.PriceMem)
+	}
+	for _, key := range []string{"PlutusV1", "PlutusV2", "PlutusV3"} {
+		if _, ok := pp.CostModels[key]; !ok {
+			t.Errorf("missing cost model %s", key)
+		}
+	}
+}
+
+func TestOgmiosTip(t *testing.T) {
+	o := newTestOgmios(t)
+	tip, err := o.Tip()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tip.Slot != 68439421 {
+		t.Errorf("got tip slot %d, expected 68439421", tip.Slot)
+	}
+	if len(tip.Hash) != 64 {
+		t.Errorf("got unexpected tip hash %q", tip.Hash)
+	}
+}
+
+func TestOgmiosNetworkMagic(t *testing.T) {
+	o := newTestOgmios(t)
+	networkMagic, err := o.NetworkMagic()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if networkMagic != 2 {
+		t.Errorf("got network magic %d, expected 2", networkMagic)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chain
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Number of slots in a Byron epoch (10k) on all networks
+	byronEpochLength = 21600
+)
+
+// networkTiming holds the genesis timing parameters for a network
+type networkTiming struct {
+	systemStart      int64
+	byronSlotLength  int64
+	shelleyStartSlot uint64
+	epochLength      uint64
+}
+
+var networkTimings = map[string]networkTiming{
+	"mainnet": {
+		systemStart:      1506203091,
+		byronSlotLength:  20,
+		shelleyStartSlot: 4492800,
+		epochLength:      432000,
+	},
+	"preprod": {
+		systemStart:      1654041600,
+		byronSlotLength:  20,
+		shelleyStartSlot: 86400,
+		epochLength:      432000,
+	},
+	"preview": {
+		systemStart:     1666656000,
+		byronSlotLength: 20,
+		epochLength:     86400,
+	},
+	"sanchonet": {
+		systemStart:     1686789000,
+		byronSlotLength: 20,
+		epochLength:     86400,
+	},
+}
+
+func getNetworkTiming(network string) (networkTiming, error) {
+	timing, ok := networkTimings[network]
+	if !ok {
+		return networkTiming{}, fmt.Errorf("unsupported network: %s", network)
+	}
+	return timing, nil
+}
+
+// SlotToTime returns the wall-clock time for the specified slot
+func SlotToTime(network string, slot uint64) (time.Time, error) {
+	timing, err := getNetworkTiming(network)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// #nosec G115
+	if slot < timing.shelleyStartSlot {
+		return time.Unix(
+			timing.systemStart+int64(slot)*timing.byronSlotLength,
+			0,
+		), nil
+	}
+	// #nosec G115
+	shelleyStart := timing.systemStart + int64(
+		timing.shelleyStartSlot,
+	)*timing.byronSlotLength
+	// #nosec G115
+	return time.Unix(
+		shelleyStart+int64(slot-timing.shelleyStartSlot),
+		0,
+	), nil
+}
+
+// SlotToEpoch returns the epoch number for the specified slot
+func SlotToEpoch(network string, slot uint64) (uint64, error) {
+	timing, err := getNetworkTiming(network)
+	if err != nil {
+		return 0, err
+	}
+	if slot < timing.shelleyStartSlot {
+		return slot / byronEpochLength, nil
+	}
+	byronEpochs := timing.shelleyStartSlot / byronEpochLength
+	return byronEpochs + (slot-timing.shelleyStartSlot)/timing.epochLength, nil
+}
+
+// TimeToSlot returns the slot for the specified wall-clock time
+func TimeToSlot(network string, t time.Time) (uint64, error) {
+	timing, err := getNetworkTiming(network)
+	if err != nil {
+		return 0, err
+	}
+	unixTime := t.Unix()
+	if unixTime < timing.systemStart {
+		return 0, nil
+	}
+	// #nosec G115
+	shelleyStart := timing.systemStart + int64(
+		timing.shelleyStartSlot,
+	)*timing.byronSlotLength
+	if unixTime < shelleyStart {
+		// #nosec G115
+		return uint64(
+			(unixTime - timing.systemStart) / timing.byronSlotLength,
+		), nil
+	}
+	// #nosec G115
+	return timing.shelleyStartSlot + uint64(unixTime-shelleyStart), nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/ledger/shelley"
+	ocommon "github.com/blinklabs-io/gouroboros/protocol/common"
+	"github.com/blinklabs-io/gouroboros/protocol/localstatequery"
+)
+
+const (
+	// How long to wait for local state queries to complete
+	queryTimeout = 60 * time.Second
+)
+
+// withLocalStateQuery connects to the local node at the specified socket path
+// and calls the provided function with a LocalStateQuery client
+func withLocalStateQuery(
+	socketPath string,
+	network string,
+	queryFunc func(*localstatequery.Client) error,
+) error {
+	oNetwork, ok := ouroboros.NetworkByName(network)
+	if !ok {
+		return fmt.Errorf("cannot get network: %s", network)
+	}
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	errorChan := make(chan error, 1)
+	oConn, err := ouroboros.New(
+		ouroboros.WithConnection(conn),
+		ouroboros.WithNetwork(oNetwork),
+		ouroboros.WithErrorChan(errorChan),
+		ouroboros.WithNodeToNode(false),
+		ouroboros.WithLocalStateQueryConfig(
+			localstatequery.NewConfig(
+				localstatequery.WithQueryTimeout(queryTimeout),
+			),
+		),
+	)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer oConn.Close()
+	resultChan := make(chan error, 1)
+	go func() {
+		resultChan <- queryFunc(oConn.LocalStateQuery().Client)
+	}()
+	select {
+	case err := <-resultChan:
+		return err
+	case err, ok := <-errorChan:
+		if !ok {
+			return errors.New("connection closed unexpectedly")
+		}
+		return err
+	case <-time.After(queryTimeout):
+		return errors.New("timed out waiting for local state query")
+	}
+}
+
+// QueryUtxosByAddress returns the current UTxOs for the specified addresses
+// from the local node, along with the chain point they were queried at
+func QueryUtxosByAddress(
+	socketPath string,
+	network string,
+	addrs []string,
+) (*ocommon.Point, []lcommon.Utxo, error) {
+	ledgerAddrs := make([]ledger.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		ledgerAddr, err := ledger.NewAddress(addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid address %s: %w", addr, err)
+		}
+		ledgerAddrs = append(ledgerAddrs, ledgerAddr)
+	}
+	var point *ocommon.Point
+	var ret []lcommon.Utxo
+	err := withLocalStateQuery(
+		socketPath,
+		network,
+		func(client *localstatequery.Client) error {
+			// Acquire a single ledger state, so that the chain point matches
+			// the UTxOs
+			if err := client.Acquire(nil); err != nil {
+				return fmt.Errorf("failed to acquire ledger state: %w", err)
+			}
+			var err error
+			point, err = client.GetChainPoint()
+			if err != nil {
+				return fmt.Errorf("failed to query chain point: %w", err)
+			}
+			result, err := client.GetUTxOByAddress(ledgerAddrs)
+			if err != nil {
+				return fmt.Errorf("failed to query UTxOs: %w", err)
+			}
+			for utxoId, output := range result.Results {
+				ret = append(
+					ret,
+					lcommon.Utxo{
+						Id: shelley.NewShelleyTransactionInput(
+							utxoId.Hash.String(),
+							utxoId.Idx,
+						),
+						Output: &output,
+					},
+				)
+			}
+			return client.Release()
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return point, ret, nil
+}
+
+// QueryProtocolParams returns the current protocol parameters from the local
+// node
+func QueryProtocolParams(
+	socketPath string,
+	network string,
+) (lcommon.ProtocolParameters, error) {
+	var ret lcommon.ProtocolParameters
+	err := withLocalStateQuery(
+		socketPath,
+		network,
+		func(client *localstatequery.Client) error {
+			var err error
+			ret, err = client.GetCurrentProtocolParams()
+			if err != nil {
+				return fmt.Errorf("failed to query protocol parameters: %w", err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
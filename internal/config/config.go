@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -30,11 +31,91 @@ type Config struct {
 	Wallet    WalletConfig
 	Network   string `envconfig:"NETWORK"`
 	Reward    RewardConfig
+	Storage   StorageConfig
+	Mint      MintConfig
+	Batch     BatchConfig
+	Retry     RetryConfig
+	Treasury  TreasuryConfig
+	Signer    SignerConfig
+	Admin     AdminConfig
+}
+
+type AdminConfig struct {
+	// Address that the admin API of the running service listens on, as
+	// unix://<socket path> or a loopback <host>:<port> (empty to disable)
+	ListenAddress string `envconfig:"ADMIN_LISTEN_ADDRESS"`
+	// Bearer token for the admin API, which is required to listen on TCP
+	Token string `envconfig:"ADMIN_TOKEN"`
+}
+
+type SignerConfig struct {
+	// Where the payment signing key is held: "wallet", "file" or "remote".
+	// When unset, it's picked based on the other config values
+	Type string `envconfig:"SIGNER_TYPE"`
+	// Payment signing key file created by cardano-cli
+	KeyFile string `envconfig:"SIGNER_KEY_FILE"`
+	// Remote signer URL, as http://<host>:<port> or unix://<socket path>
+	Url string `envconfig:"SIGNER_URL"`
+	// Maximum time to wait for the remote signer
+	Timeout time.Duration `envconfig:"SIGNER_TIMEOUT"`
+	// Bearer token for the remote signer, which the signer requires when set
+	Token string `envconfig:"SIGNER_TOKEN"`
+	// Stake address combined with the signer payment key to build the wallet
+	// address, when not signing with the wallet. When unset, an enterprise
+	// address is used
+	StakeAddress string `envconfig:"SIGNER_STAKE_ADDRESS"`
+	// Addresses that the remote signer allows TX outputs to pay to, besides
+	// the signer's own addresses. When unset, any destination is allowed
+	AllowedAddresses []string `envconfig:"SIGNER_ALLOWED_ADDRESSES"`
+	// Maximum TX fee in lovelace that the remote signer signs with allowed
+	// addresses set, or 0 for no limit
+	MaxFee uint64 `envconfig:"SIGNER_MAX_FEE"`
+	// Whether the remote signer signs TXs that mint with allowed addresses
+	// set, which REWARD_MINT requires
+	AllowMint bool `envconfig:"SIGNER_ALLOW_MINT"`
+	// Whether the remote signer signs TXs with certificates or governance
+	// actions with allowed addresses set
+	AllowCertificates bool `envconfig:"SIGNER_ALLOW_CERTIFICATES"`
+	// Whether the remote signer signs TXs with withdrawals with allowed
+	// addresses set
+	AllowWithdrawals bool `envconfig:"SIGNER_ALLOW_WITHDRAWALS"`
+}
+
+type TreasuryConfig struct {
+	// Hex-encoded payment key hashes of the cosigners of an M-of-N native
+	// script treasury that rewards are paid from. The order determines the
+	// script, and therefore the treasury address
+	Keys []string `envconfig:"TREASURY_KEYS"`
+	// Number of cosigner signatures required to spend from the treasury
+	Required int `envconfig:"TREASURY_REQUIRED"`
+	// Number of slots after the current tip that treasury TXs stay valid, which
+	// is the time cosigners have to sign them (0 for no TTL)
+	TtlSlots uint64 `envconfig:"TREASURY_TTL_SLOTS"`
+	// Directory that partially signed treasury TXs are exported to
+	PendingDir string `envconfig:"TREASURY_PENDING_DIR"`
+}
+
+type BatchConfig struct {
+	// How long to collect rewards before paying them out in a single TX (0 to disable batching)
+	Window time.Duration `envconfig:"BATCH_WINDOW"`
+	// Maximum number of rewards to collect before paying them out early
+	MaxCount int `envconfig:"BATCH_MAX_COUNT"`
 }
 
 type IndexerConfig struct {
-	Address    string `envconfig:"INDEXER_TCP_ADDRESS"`
-	SocketPath string `envconfig:"INDEXER_SOCKET_PATH"`
+	Address           string `envconfig:"INDEXER_TCP_ADDRESS"`
+	SocketPath        string `envconfig:"INDEXER_SOCKET_PATH"`
+	IntersectTip      bool   `envconfig:"INDEXER_INTERSECT_TIP"`
+	ConfirmationDepth uint64 `envconfig:"INDEXER_CONFIRMATION_DEPTH"`
+}
+
+type RetryConfig struct {
+	// Maximum number of attempts to pay out a reward before moving it to the dead-letter state
+	MaxAttempts int `envconfig:"RETRY_MAX_ATTEMPTS"`
+	// Delay before the first retry, which doubles with each further attempt
+	InitialBackoff time.Duration `envconfig:"RETRY_INITIAL_BACKOFF"`
+	// Maximum delay between retries
+	MaxBackoff time.Duration `envconfig:"RETRY_MAX_BACKOFF"`
 }
 
 type RewardConfig struct {
@@ -42,29 +123,121 @@ type RewardConfig struct {
 	SourceAddress string `envconfig:"SOURCE_ADDRESS"`
 	MinLovelace   uint64 `envconfig:"MIN_LOVELACE"`
 	RewardAmount  uint64 `envconfig:"REWARD_AMOUNT"`
+	// Native assets to include in rewards, in the format <policy ID>.<hex asset name>:<quantity>
+	RewardAssets []string `envconfig:"REWARD_ASSETS"`
+	// Tokens to mint under the wallet policy as rewards, in the format <hex asset name>:<quantity>
+	RewardMint []string `envconfig:"REWARD_MINT"`
+	RulesFile  string   `envconfig:"REWARD_RULES_FILE"`
+	DryRun     bool     `envconfig:"REWARD_DRY_RUN"`
+	// Where to send rewards: "fixed" (the reward address) or "depositor"
+	PayoutMode string `envconfig:"REWARD_PAYOUT_MODE"`
+	// How to determine the depositor address: "first-input", "largest-input" or "metadata"
+	PayoutPolicy       string `envconfig:"REWARD_PAYOUT_POLICY"`
+	ReturnAddressLabel uint64 `envconfig:"REWARD_RETURN_ADDRESS_LABEL"`
+}
+
+type MintConfig struct {
+	// Slot after which the wallet minting policy can no longer be used (0 for no time lock)
+	LockSlot uint64 `envconfig:"MINT_LOCK_SLOT"`
+}
+
+type StorageConfig struct {
+	Directory string `envconfig:"STORAGE_DIR"`
 }
 
 type SubmitConfig struct {
-	Address    string `envconfig:"SUBMIT_TCP_ADDRESS"`
+	Address string `envconfig:"SUBMIT_TCP_ADDRESS"`
+	// Additional peers to broadcast TXs to via node-to-node, as <host>:<port>
+	Peers []string `envconfig:"SUBMIT_TCP_PEERS"`
+	// Number of node-to-node peers that must request a TX for its submission to succeed
+	Quorum     int    `envconfig:"SUBMIT_QUORUM"`
 	SocketPath string `envconfig:"SUBMIT_SOCKET_PATH"`
 	Url        string `envconfig:"SUBMIT_URL"`
+	// Ogmios WebSocket URL to submit TXs via submitTransaction
+	OgmiosUrl string `envconfig:"SUBMIT_OGMIOS_URL"`
 }
 
 type TxBuilderConfig struct {
+	// Chain backend to use: "blockfrost", "kupo", "ogmios", "local" or
+	// "memory". When unset, it's picked based on the other config values
+	Backend          string `envconfig:"CHAIN_BACKEND"`
 	BlockfrostApiKey string `envconfig:"BLOCKFROST_API_KEY"`
-	KupoUrl          string `envconfig:"KUPO_URL"`
+	// Base URL for a self-hosted Blockfrost-compatible API
+	BlockfrostUrl string `envconfig:"BLOCKFROST_URL"`
+	KupoUrl       string `envconfig:"KUPO_URL"`
+	OgmiosUrl     string `envconfig:"OGMIOS_URL"`
+	// Use the local UTxO set maintained by the indexer instead of Blockfrost or Kupo
+	LocalUtxoSet bool `envconfig:"LOCAL_UTXO_SET"`
+	// How long to keep wallet UTxOs reserved for an unconfirmed TX
+	ReservationTimeout time.Duration `envconfig:"UTXO_RESERVATION_TIMEOUT"`
+	// Number of slots after the current tip that reward TXs stay valid (0 for no TTL)
+	TtlSlots uint64 `envconfig:"TX_TTL_SLOTS"`
 }
 
 type WalletConfig struct {
 	Mnemonic string `envconfig:"MNEMONIC"`
+	// Encrypted keystore holding the mnemonic, used when no mnemonic is provided
+	KeystoreFile string `envconfig:"WALLET_KEYSTORE"`
+	// Passphrase for the keystore. When neither this nor the passphrase file is set, it's prompted for
+	Passphrase     string `envconfig:"WALLET_PASSPHRASE"`
+	PassphraseFile string `envconfig:"WALLET_PASSPHRASE_FILE"`
+	// Optional BIP39 passphrase (the "25th word") used with the mnemonic
+	Bip39Passphrase string `envconfig:"WALLET_BIP39_PASSPHRASE"`
+	// CIP-1852 derivation path m/1852'/1815'/account'/0/address for the payment key
+	// and m/1852'/1815'/account'/2/stake for the stake key
+	AccountIndex uint   `envconfig:"WALLET_ACCOUNT_INDEX"`
+	AddressIndex uint32 `envconfig:"WALLET_ADDRESS_INDEX"`
+	StakeIndex   uint32 `envconfig:"WALLET_STAKE_INDEX"`
+	// Number of additional receive addresses to derive and watch for deposits,
+	// starting at the address index after the wallet address
+	ReceiveAddressCount uint32 `envconfig:"WALLET_RECEIVE_ADDRESS_COUNT"`
 }
 
 // Singleton config instance with default values
 var globalConfig = &Config{
 	Network: "preprod",
+	Indexer: IndexerConfig{
+		ConfirmationDepth: 3,
+	},
 	Reward: RewardConfig{
 		MinLovelace:  50_000_000, // 50 (t)ADA
 		RewardAmount: 5_000_000,  // 5 (t)ADA
+		PayoutMode:   "fixed",
+		PayoutPolicy: "first-input",
+		// Arbitrary label, since there's no standard for return addresses
+		ReturnAddressLabel: 1967,
+	},
+	Storage: StorageConfig{
+		Directory: "./data",
+	},
+	Submit: SubmitConfig{
+		Quorum: 1,
+	},
+	Wallet: WalletConfig{
+		KeystoreFile: "keystore.json",
+	},
+	TxBuilder: TxBuilderConfig{
+		ReservationTimeout: 10 * time.Minute,
+		TtlSlots:           600,
+	},
+	Treasury: TreasuryConfig{
+		TtlSlots:   86400, // 1 day
+		PendingDir: "./pending",
+	},
+	Signer: SignerConfig{
+		Timeout: 10 * time.Second,
+		MaxFee:  2_000_000,
+	},
+	Admin: AdminConfig{
+		ListenAddress: "unix://admin.sock",
+	},
+	Batch: BatchConfig{
+		MaxCount: 50,
+	},
+	Retry: RetryConfig{
+		MaxAttempts:    5,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     30 * time.Minute,
 	},
 }
 
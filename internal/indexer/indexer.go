@@ -15,24 +15,51 @@
 package indexer
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"sync"
 
 	"github.com/blinklabs-io/adder/event"
-	filter_chainsync "github.com/blinklabs-io/adder/filter/chainsync"
 	filter_event "github.com/blinklabs-io/adder/filter/event"
 	input_chainsync "github.com/blinklabs-io/adder/input/chainsync"
 	output_embedded "github.com/blinklabs-io/adder/output/embedded"
 	"github.com/blinklabs-io/adder/pipeline"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/backend"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/chain"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/tracker"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txbuilder"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+	ocommon "github.com/blinklabs-io/gouroboros/protocol/common"
 )
 
 type Indexer struct {
-	pipeline *pipeline.Pipeline
+	pipeline         *pipeline.Pipeline
+	recentBlocks     []blockPoint
+	pending          []pendingDeposit
+	watchedAddresses []string
+	watchedMutex     sync.RWMutex
+	// Whether to maintain the local UTxO set
+	localUtxoSet bool
+}
+
+type blockPoint struct {
+	slot        uint64
+	hash        string
+	blockNumber uint64
+}
+
+// pendingDeposit is a transaction event waiting for enough confirmations
+// before being processed
+type pendingDeposit struct {
+	evt         event.Event
+	slot        uint64
+	blockNumber uint64
 }
 
 // Singleton indexer instance
@@ -45,14 +72,83 @@ func (i *Indexer) Start() error {
 		slog.Error("failed to load wallet")
 		return errors.New("failed to load wallet")
 	}
+	// We only care about transactions on our wallet addresses and the reward address
+	i.watchedAddresses = []string{
+		w.PaymentAddress,
+	}
+	i.watchedAddresses = append(
+		i.watchedAddresses,
+		wallet.GetReceiveAddresses()...,
+	)
+	i.watchedAddresses = append(
+		i.watchedAddresses,
+		wallet.GetDepositAddresses()...,
+	)
+	if cfg.Reward.RewardAddress != "" {
+		i.watchedAddresses = append(
+			i.watchedAddresses,
+			cfg.Reward.RewardAddress,
+		)
+	}
+	// Rewards are paid from the treasury address, if configured
+	treasury, err := txbuilder.GetTreasury()
+	if err != nil {
+		return err
+	}
+	if treasury != nil {
+		i.watchedAddresses = append(i.watchedAddresses, treasury.Address)
+	}
+	// Watch deposit addresses handed out while we're running
+	wallet.AddDepositAddressListener(
+		func(depositAddr storage.DepositAddress) {
+			i.AddWatchedAddress(depositAddr.Address)
+		},
+	)
+	_, i.localUtxoSet = backend.GetBackend().(*backend.Local)
 	// Create pipeline
 	i.pipeline = pipeline.New()
 	// Configure pipeline input
 	inputOpts := []input_chainsync.ChainSyncOptionFunc{
-		input_chainsync.WithIntersectTip(true),
 		input_chainsync.WithAutoReconnect(true),
 		input_chainsync.WithNetwork(cfg.Network),
 	}
+	// Resume from the saved cursor, if available
+	intersectPoints, err := getIntersectPoints()
+	if err != nil {
+		return err
+	}
+	if i.localUtxoSet {
+		// Start from the point the local UTxO set was seeded at, if any, so
+		// that we don't miss any changes. A saved cursor before that point
+		// is kept, since replaying blocks that the UTxO set already reflects
+		// is harmless, while skipping blocks would miss deposits
+		seedPoint, err := i.seedUtxos()
+		if err != nil {
+			return err
+		}
+		if seedPoint != nil &&
+			(len(intersectPoints) == 0 ||
+				seedPoint.Slot < intersectPoints[0].Slot) {
+			intersectPoints = []ocommon.Point{*seedPoint}
+		}
+	}
+	if len(intersectPoints) > 0 && !cfg.Indexer.IntersectTip {
+		slog.Info(
+			fmt.Sprintf(
+				"resuming from saved cursor at slot %d",
+				intersectPoints[0].Slot,
+			),
+		)
+		inputOpts = append(
+			inputOpts,
+			input_chainsync.WithIntersectPoints(intersectPoints),
+		)
+	} else {
+		inputOpts = append(
+			inputOpts,
+			input_chainsync.WithIntersectTip(true),
+		)
+	}
 	if cfg.Indexer.Address != "" {
 		inputOpts = append(
 			inputOpts,
@@ -70,22 +166,21 @@ func (i *Indexer) Start() error {
 	)
 	i.pipeline.AddInput(input)
 	// Configure pipeline filters
-	// We only care about transaction events
+	// We only care about transaction events, plus block and rollback events
+	// for tracking our position on the chain
 	filterEvent := filter_event.New(
-		filter_event.WithTypes([]string{"chainsync.transaction"}),
+		filter_event.WithTypes(
+			[]string{
+				"chainsync.block",
+				"chainsync.rollback",
+				"chainsync.transaction",
+			},
+		),
 	)
 	i.pipeline.AddFilter(filterEvent)
-	// We only care about transactions on our wallet address and the reward address
-	filterAddresses := []string{
-		w.PaymentAddress,
-	}
-	if cfg.Reward.RewardAddress != "" {
-		filterAddresses = append(filterAddresses, cfg.Reward.RewardAddress)
-	}
-	filterChainsync := filter_chainsync.New(
-		filter_chainsync.WithAddresses(filterAddresses),
-	)
-	i.pipeline.AddFilter(filterChainsync)
+	// We filter by address ourselves, since the local UTxO set needs to see
+	// every TX and our own reward TXs need to be tracked even if they don't
+	// pay to a watched address
 	// Configure pipeline output
 	output := output_embedded.New(
 		output_embedded.WithCallbackFunc(i.handleEvent),
@@ -109,13 +204,251 @@ func (i *Indexer) Start() error {
 }
 
 func (i *Indexer) handleEvent(evt event.Event) error {
+	switch evt.Type {
+	case "chainsync.block":
+		return i.handleBlock(evt)
+	case "chainsync.rollback":
+		return i.handleRollback(evt)
+	case "chainsync.transaction":
+		return i.handleTransaction(evt)
+	}
+	return nil
+}
+
+func (i *Indexer) handleTransaction(evt event.Event) error {
+	cfg := config.GetConfig()
+	eventTx := evt.Payload.(event.TransactionEvent)
+	eventCtx := evt.Context.(event.TransactionContext)
+	// We see every TX on the chain, and most of them have nothing to do
+	// with us
+	watched := i.isWatched(eventTx)
+	if !watched &&
+		!tracker.GetTracker().IsTracked(eventCtx.TransactionHash) &&
+		!i.spendsLocalUtxo(eventTx) {
+		return nil
+	}
+	if i.localUtxoSet {
+		if err := i.updateUtxos(evt); err != nil {
+			return err
+		}
+	}
+	if err := tracker.GetTracker().HandleTransaction(
+		eventCtx.TransactionHash,
+		eventCtx.SlotNumber,
+		eventCtx.BlockNumber,
+		eventTx.BlockHash,
+	); err != nil {
+		return fmt.Errorf("failed to update transaction tracker: %w", err)
+	}
+	if !watched {
+		return nil
+	}
+	if cfg.Indexer.ConfirmationDepth == 0 {
+		processDeposit(evt)
+		return nil
+	}
+	// Hold the transaction until it has enough confirmations
+	i.pending = append(
+		i.pending,
+		pendingDeposit{
+			evt:         evt,
+			slot:        eventCtx.SlotNumber,
+			blockNumber: eventCtx.BlockNumber,
+		},
+	)
+	slog.Debug(
+		fmt.Sprintf(
+			"holding TX %s until %d confirmations",
+			eventCtx.TransactionHash,
+			cfg.Indexer.ConfirmationDepth,
+		),
+	)
+	return nil
+}
+
+func (i *Indexer) handleBlock(evt event.Event) error {
+	cfg := config.GetConfig()
+	eventBlock := evt.Payload.(event.BlockEvent)
+	eventCtx := evt.Context.(event.BlockContext)
+	i.recentBlocks = append(
+		i.recentBlocks,
+		blockPoint{
+			slot:        eventCtx.SlotNumber,
+			hash:        eventBlock.BlockHash,
+			blockNumber: eventCtx.BlockNumber,
+		},
+	)
+	txbuilder.SetTipSlot(eventCtx.SlotNumber)
+	// Protocol parameters can only change on an epoch boundary
+	if epoch, err := chain.SlotToEpoch(cfg.Network, eventCtx.SlotNumber); err == nil {
+		txbuilder.GetProtocolParams().SetEpoch(epoch)
+	}
+	// Check submitted reward TXs for confirmation or expiry
+	if err := tracker.GetTracker().HandleBlock(
+		eventCtx.SlotNumber,
+		eventCtx.BlockNumber,
+	); err != nil {
+		return fmt.Errorf("failed to update transaction tracker: %w", err)
+	}
+	// Process pending deposits that have reached the confirmation depth
+	var remaining []pendingDeposit
+	for _, deposit := range i.pending {
+		if eventCtx.BlockNumber-deposit.blockNumber >= cfg.Indexer.ConfirmationDepth {
+			processDeposit(deposit.evt)
+			continue
+		}
+		remaining = append(remaining, deposit)
+	}
+	i.pending = remaining
+	// Transaction events for a block follow the block event, so we only know
+	// that the previous block has been fully processed at this point. We
+	// also hold back the cursor while a block's deposits are still pending,
+	// so that they're seen again after a restart
+	lag := max(cfg.Indexer.ConfirmationDepth, 1)
+	if eventCtx.BlockNumber < lag {
+		return nil
+	}
+	safeBlockNumber := eventCtx.BlockNumber - lag
+	safeIdx := -1
+	for idx, block := range i.recentBlocks {
+		if block.blockNumber <= safeBlockNumber {
+			safeIdx = idx
+		}
+	}
+	if safeIdx < 0 {
+		return nil
+	}
+	safeBlock := i.recentBlocks[safeIdx]
+	if err := storage.GetStorage().UpdateCursor(
+		safeBlock.slot,
+		safeBlock.hash,
+	); err != nil {
+		return fmt.Errorf("failed to update cursor: %w", err)
+	}
+	i.recentBlocks = i.recentBlocks[safeIdx+1:]
+	// Remove spent UTxOs that are older than any point we could roll back to
+	if i.localUtxoSet {
+		cursor, err := storage.GetStorage().GetCursor()
+		if err != nil {
+			return fmt.Errorf("failed to load cursor: %w", err)
+		}
+		if err := storage.GetStorage().PruneUtxos(
+			cursor[len(cursor)-1].Slot,
+		); err != nil {
+			return fmt.Errorf("failed to prune local UTxO set: %w", err)
+		}
+	}
+	return nil
+}
+
+func (i *Indexer) handleRollback(evt event.Event) error {
+	eventRollback := evt.Payload.(event.RollbackEvent)
+	slog.Info(
+		fmt.Sprintf(
+			"rollback to slot %d (%s)",
+			eventRollback.SlotNumber,
+			eventRollback.BlockHash,
+		),
+	)
+	if err := storage.GetStorage().RollbackCursor(
+		eventRollback.SlotNumber,
+	); err != nil {
+		return fmt.Errorf("failed to rollback cursor: %w", err)
+	}
+	if err := storage.GetStorage().RollbackUtxos(
+		eventRollback.SlotNumber,
+	); err != nil {
+		return fmt.Errorf("failed to rollback local UTxO set: %w", err)
+	}
+	if err := tracker.GetTracker().HandleRollback(
+		eventRollback.SlotNumber,
+	); err != nil {
+		return fmt.Errorf("failed to update transaction tracker: %w", err)
+	}
+	var recentBlocks []blockPoint
+	for _, block := range i.recentBlocks {
+		if block.slot <= eventRollback.SlotNumber {
+			recentBlocks = append(recentBlocks, block)
+		}
+	}
+	i.recentBlocks = recentBlocks
+	// Drop pending deposits from rolled back blocks
+	var remaining []pendingDeposit
+	for _, deposit := range i.pending {
+		if deposit.slot > eventRollback.SlotNumber {
+			eventCtx := deposit.evt.Context.(event.TransactionContext)
+			slog.Warn(
+				fmt.Sprintf(
+					"dropping pending TX %s due to rollback",
+					eventCtx.TransactionHash,
+				),
+			)
+			continue
+		}
+		remaining = append(remaining, deposit)
+	}
+	i.pending = remaining
+	return nil
+}
+
+// AddWatchedAddress starts watching an address for deposits
+func (i *Indexer) AddWatchedAddress(addr string) {
+	i.watchedMutex.Lock()
+	defer i.watchedMutex.Unlock()
+	if slices.Contains(i.watchedAddresses, addr) {
+		return
+	}
+	i.watchedAddresses = append(i.watchedAddresses, addr)
+	slog.Info("watching new address " + addr)
+}
+
+// isWatchedAddress returns whether the address is one of the watched addresses
+func (i *Indexer) isWatchedAddress(addr string) bool {
+	i.watchedMutex.RLock()
+	defer i.watchedMutex.RUnlock()
+	return slices.Contains(i.watchedAddresses, addr)
+}
+
+// isWatched returns whether the TX sends funds to any of the watched
+// addresses. This matches the behavior of the adder address filter
+func (i *Indexer) isWatched(eventTx event.TransactionEvent) bool {
+	for _, output := range eventTx.Outputs {
+		if i.isWatchedAddress(output.Address().String()) {
+			return true
+		}
+	}
+	for _, input := range eventTx.ResolvedInputs {
+		if i.isWatchedAddress(input.Address().String()) {
+			return true
+		}
+	}
+	return false
+}
+
+func processDeposit(evt event.Event) {
 	// Build transaction
 	if err := txbuilder.HandleEvent(evt); err != nil {
 		slog.Warn(
 			fmt.Sprintf("Failed to build TX: %s", err),
 		)
 	}
-	return nil
+}
+
+// getIntersectPoints returns the chain points from the saved cursor
+func getIntersectPoints() ([]ocommon.Point, error) {
+	cursor, err := storage.GetStorage().GetCursor()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cursor: %w", err)
+	}
+	ret := make([]ocommon.Point, 0, len(cursor))
+	for _, point := range cursor {
+		hash, err := hex.DecodeString(point.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode cursor hash: %w", err)
+		}
+		ret = append(ret, ocommon.NewPoint(point.Slot, hash))
+	}
+	return ret, nil
 }
 
 // GetIndexer returns the global indexer instance
@@ -0,0 +1,364 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/blinklabs-io/adder/event"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/ledger/shelley"
+)
+
+// Slots are 10 times the block number in the synthetic chain
+const testSlotsPerBlock = 10
+
+func testAddress(t *testing.T, b byte) string {
+	t.Helper()
+	addr, err := lcommon.NewAddressFromParts(
+		lcommon.AddressTypeKeyNone,
+		0,
+		bytes.Repeat([]byte{b}, lcommon.AddressHashSize),
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to build address: %s", err)
+	}
+	return addr.String()
+}
+
+func blockEvent(blockNumber uint64) event.Event {
+	return event.New(
+		"chainsync.block",
+		time.Time{},
+		event.BlockContext{
+			BlockNumber: blockNumber,
+			SlotNumber:  blockNumber * testSlotsPerBlock,
+		},
+		event.BlockEvent{
+			BlockHash: fmt.Sprintf("%064x", blockNumber),
+		},
+	)
+}
+
+func txEvent(t *testing.T, blockNumber uint64, addr string) event.Event {
+	t.Helper()
+	outputAddr, err := lcommon.NewAddress(addr)
+	if err != nil {
+		t.Fatalf("failed to decode address: %s", err)
+	}
+	return event.New(
+		"chainsync.transaction",
+		time.Time{},
+		event.TransactionContext{
+			BlockNumber:     blockNumber,
+			SlotNumber:      blockNumber * testSlotsPerBlock,
+			TransactionHash: fmt.Sprintf("%064x", 1_000_000+blockNumber),
+		},
+		event.TransactionEvent{
+			BlockHash: fmt.Sprintf("%064x", blockNumber),
+			Outputs: []ledger.TransactionOutput{
+				&shelley.ShelleyTransactionOutput{
+					OutputAddress: outputAddr,
+					OutputAmount:  100_000_000,
+				},
+			},
+		},
+	)
+}
+
+func rollbackEvent(blockNumber uint64) event.Event {
+	return event.New(
+		"chainsync.rollback",
+		time.Time{},
+		nil,
+		event.RollbackEvent{
+			BlockHash:  fmt.Sprintf("%064x", blockNumber),
+			SlotNumber: blockNumber * testSlotsPerBlock,
+		},
+	)
+}
+
+// setupStorage opens an empty database for the test
+func setupStorage(t *testing.T) {
+	t.Helper()
+	cfg := config.GetConfig()
+	cfg.Storage.Directory = t.TempDir()
+	if err := storage.GetStorage().Load(); err != nil {
+		t.Fatalf("failed to load storage: %s", err)
+	}
+	t.Cleanup(func() {
+		storage.GetStorage().Close()
+	})
+}
+
+func TestHandleEvent(t *testing.T) {
+	watchedAddr := testAddress(t, 1)
+	otherAddr := testAddress(t, 2)
+	testDefs := []struct {
+		name              string
+		confirmationDepth uint64
+		events            []event.Event
+		// Number of deposits still waiting for confirmations
+		expectedPending int
+		// Slot of the most recent cursor point, or 0 for no cursor
+		expectedCursorSlot uint64
+	}{
+		{
+			name:              "deposit waits for confirmation depth",
+			confirmationDepth: 3,
+			events: []event.Event{
+				blockEvent(100),
+				txEvent(t, 100, watchedAddr),
+				blockEvent(101),
+				blockEvent(102),
+			},
+			expectedPending:    1,
+			expectedCursorSlot: 0,
+		},
+		{
+			name:              "deposit processed at confirmation depth",
+			confirmationDepth: 3,
+			events: []event.Event{
+				blockEvent(100),
+				txEvent(t, 100, watchedAddr),
+				blockEvent(101),
+				blockEvent(102),
+				blockEvent(103),
+			},
+			expectedPending:    0,
+			expectedCursorSlot: 1000,
+		},
+		{
+			name:              "deposit processed immediately without confirmation depth",
+			confirmationDepth: 0,
+			events: []event.Event{
+				blockEvent(100),
+				txEvent(t, 100, watchedAddr),
+			},
+			expectedPending:    0,
+			expectedCursorSlot: 0,
+		},
+		{
+			name:              "unwatched TX is ignored",
+			confirmationDepth: 3,
+			events: []event.Event{
+				blockEvent(100),
+				txEvent(t, 100, otherAddr),
+				blockEvent(101),
+			},
+			expectedPending:    0,
+			expectedCursorSlot: 0,
+		},
+		{
+			name:              "rollback drops pending deposit",
+			confirmationDepth: 3,
+			events: []event.Event{
+				blockEvent(100),
+				blockEvent(101),
+				txEvent(t, 101, watchedAddr),
+				rollbackEvent(100),
+			},
+			expectedPending:    0,
+			expectedCursorSlot: 0,
+		},
+		{
+			name:              "rollback keeps deposit in earlier block",
+			confirmationDepth: 3,
+			events: []event.Event{
+				blockEvent(100),
+				txEvent(t, 100, watchedAddr),
+				blockEvent(101),
+				rollbackEvent(100),
+			},
+			expectedPending:    1,
+			expectedCursorSlot: 0,
+		},
+		{
+			name:              "cursor lags by confirmation depth",
+			confirmationDepth: 3,
+			events: []event.Event{
+				blockEvent(100),
+				blockEvent(101),
+				blockEvent(102),
+				blockEvent(103),
+				blockEvent(104),
+				blockEvent(105),
+			},
+			expectedPending:    0,
+			expectedCursorSlot: 1020,
+		},
+		{
+			name:              "cursor lags by one block without confirmation depth",
+			confirmationDepth: 0,
+			events: []event.Event{
+				blockEvent(100),
+				blockEvent(101),
+				blockEvent(102),
+			},
+			expectedPending:    0,
+			expectedCursorSlot: 1010,
+		},
+		{
+			name:              "rollback removes later cursor points",
+			confirmationDepth: 1,
+			events: []event.Event{
+				blockEvent(100),
+				blockEvent(101),
+				blockEvent(102),
+				blockEvent(103),
+				rollbackEvent(101),
+			},
+			expectedPending:    0,
+			expectedCursorSlot: 1010,
+		},
+		{
+			name:              "cursor held back after rollback until depth is reached again",
+			confirmationDepth: 2,
+			events: []event.Event{
+				blockEvent(100),
+				blockEvent(101),
+				blockEvent(102),
+				rollbackEvent(100),
+				blockEvent(101),
+				blockEvent(102),
+			},
+			expectedPending:    0,
+			expectedCursorSlot: 1000,
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			setupStorage(t)
+			cfg := config.GetConfig()
+			cfg.Indexer.ConfirmationDepth = testDef.confirmationDepth
+			i := &Indexer{
+				watchedAddresses: []string{watchedAddr},
+			}
+			for _, evt := range testDef.events {
+				if err := i.handleEvent(evt); err != nil {
+					t.Fatalf("failed to handle %s event: %s", evt.Type, err)
+				}
+			}
+			if len(i.pending) != testDef.expectedPending {
+				t.Errorf(
+					"got %d pending deposits, expected %d",
+					len(i.pending),
+					testDef.expectedPending,
+				)
+			}
+			cursor, err := storage.GetStorage().GetCursor()
+			if err != nil {
+				t.Fatalf("failed to get cursor: %s", err)
+			}
+			var cursorSlot uint64
+			if len(cursor) > 0 {
+				cursorSlot = cursor[0].Slot
+			}
+			if cursorSlot != testDef.expectedCursorSlot {
+				t.Errorf(
+					"got cursor at slot %d, expected %d",
+					cursorSlot,
+					testDef.expectedCursorSlot,
+				)
+			}
+		})
+	}
+}
+
+func TestAddWatchedAddress(t *testing.T) {
+	setupStorage(t)
+	cfg := config.GetConfig()
+	cfg.Indexer.ConfirmationDepth = 3
+	watchedAddr := testAddress(t, 1)
+	newAddr := testAddress(t, 3)
+	i := &Indexer{
+		watchedAddresses: []string{watchedAddr},
+	}
+	events := []event.Event{
+		blockEvent(100),
+		txEvent(t, 100, newAddr),
+	}
+	for _, evt := range events {
+		if err := i.handleEvent(evt); err != nil {
+			t.Fatalf("failed to handle %s event: %s", evt.Type, err)
+		}
+	}
+	if len(i.pending) != 0 {
+		t.Fatalf("deposit to unwatched address was not ignored")
+	}
+	// Deposits to an address handed out at runtime are picked up without
+	// a restart
+	i.AddWatchedAddress(newAddr)
+	i.AddWatchedAddress(newAddr)
+	if len(i.watchedAddresses) != 2 {
+		t.Fatalf("got %d watched addresses, expected 2", len(i.watchedAddresses))
+	}
+	if err := i.handleEvent(txEvent(t, 101, newAddr)); err != nil {
+		t.Fatalf("failed to handle TX event: %s", err)
+	}
+	if len(i.pending) != 1 {
+		t.Fatalf("got %d pending deposits, expected 1", len(i.pending))
+	}
+}
+
+func TestGetIntersectPoints(t *testing.T) {
+	setupStorage(t)
+	points, err := getIntersectPoints()
+	if err != nil {
+		t.Fatalf("failed to get intersect points: %s", err)
+	}
+	if len(points) != 0 {
+		t.Fatalf("got %d intersect points without a cursor", len(points))
+	}
+	for slot := uint64(10); slot <= 150; slot += 10 {
+		if err := storage.GetStorage().UpdateCursor(
+			slot,
+			fmt.Sprintf("%064x", slot),
+		); err != nil {
+			t.Fatalf("failed to update cursor: %s", err)
+		}
+	}
+	points, err = getIntersectPoints()
+	if err != nil {
+		t.Fatalf("failed to get intersect points: %s", err)
+	}
+	// We resume from the newest point, falling back to older ones if the
+	// newest has been rolled back on the chain
+	if len(points) != 10 {
+		t.Fatalf("got %d intersect points, expected 10", len(points))
+	}
+	for idx, point := range points {
+		expectedSlot := uint64(150 - idx*10)
+		if point.Slot != expectedSlot {
+			t.Fatalf(
+				"got point %d at slot %d, expected %d",
+				idx,
+				point.Slot,
+				expectedSlot,
+			)
+		}
+		if hex.EncodeToString(point.Hash) != fmt.Sprintf("%064x", point.Slot) {
+			t.Fatalf("got hash %x for slot %d", point.Hash, point.Slot)
+		}
+	}
+}
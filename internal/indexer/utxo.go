@@ -0,0 +1,151 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexer
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/blinklabs-io/adder/event"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/chain"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	ocommon "github.com/blinklabs-io/gouroboros/protocol/common"
+)
+
+// seedUtxos populates an empty local UTxO set from the local node. It returns
+// the chain point that the UTxOs were queried at, which the indexer must
+// start from, or nil if no seeding was done
+func (i *Indexer) seedUtxos() (*ocommon.Point, error) {
+	cfg := config.GetConfig()
+	hasUtxos, err := storage.GetStorage().HasUtxos()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check local UTxO set: %w", err)
+	}
+	if hasUtxos {
+		return nil, nil
+	}
+	if cfg.Indexer.SocketPath == "" {
+		slog.Warn(
+			"local UTxO set is empty and can only be seeded from a local node socket: only UTxOs created from now on will be available",
+		)
+		return nil, nil
+	}
+	point, utxos, err := chain.QueryUtxosByAddress(
+		cfg.Indexer.SocketPath,
+		cfg.Network,
+		i.watchedAddresses,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seed local UTxO set: %w", err)
+	}
+	created := make([]storage.Utxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		created = append(
+			created,
+			storage.Utxo{
+				TxHash:  utxo.Id.Id().String(),
+				Index:   utxo.Id.Index(),
+				Address: utxo.Output.Address().String(),
+				Output:  utxo.Output.Cbor(),
+				Slot:    point.Slot,
+			},
+		)
+	}
+	if err := storage.GetStorage().ApplyUtxos(point.Slot, nil, created); err != nil {
+		return nil, fmt.Errorf("failed to seed local UTxO set: %w", err)
+	}
+	slog.Info(
+		fmt.Sprintf(
+			"seeded local UTxO set with %d UTxOs at slot %d",
+			len(created),
+			point.Slot,
+		),
+	)
+	return point, nil
+}
+
+// spendsLocalUtxo returns whether the TX spends a UTxO in the local UTxO set.
+// This is only needed when adder didn't resolve the TX inputs, since
+// isWatched already checks the resolved inputs
+func (i *Indexer) spendsLocalUtxo(eventTx event.TransactionEvent) bool {
+	if !i.localUtxoSet || len(eventTx.ResolvedInputs) > 0 ||
+		eventTx.Transaction == nil {
+		return false
+	}
+	for _, input := range eventTx.Transaction.Consumed() {
+		utxo, err := storage.GetStorage().GetUtxo(
+			input.Id().String(),
+			input.Index(),
+		)
+		if err != nil {
+			slog.Warn(
+				fmt.Sprintf("failed to lookup local UTxO: %s", err),
+			)
+			// Err on the side of updating the local UTxO set
+			return true
+		}
+		if utxo != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// updateUtxos applies a transaction to the local UTxO set
+func (i *Indexer) updateUtxos(evt event.Event) error {
+	eventTx := evt.Payload.(event.TransactionEvent)
+	eventCtx := evt.Context.(event.TransactionContext)
+	if eventTx.Transaction == nil {
+		return nil
+	}
+	// Consumed and Produced take into account whether the TX failed
+	// script validation, in which case only the collateral is spent
+	var spent []storage.UtxoRef
+	for _, input := range eventTx.Transaction.Consumed() {
+		spent = append(
+			spent,
+			storage.UtxoRef{
+				TxHash: input.Id().String(),
+				Index:  input.Index(),
+			},
+		)
+	}
+	var created []storage.Utxo
+	for _, utxo := range eventTx.Transaction.Produced() {
+		addr := utxo.Output.Address().String()
+		if !i.isWatchedAddress(addr) {
+			continue
+		}
+		created = append(
+			created,
+			storage.Utxo{
+				TxHash:  utxo.Id.Id().String(),
+				Index:   utxo.Id.Index(),
+				Address: addr,
+				Output:  utxo.Output.Cbor(),
+				Slot:    eventCtx.SlotNumber,
+			},
+		)
+	}
+	if err := storage.GetStorage().ApplyUtxos(
+		eventCtx.SlotNumber,
+		spent,
+		created,
+	); err != nil {
+		return fmt.Errorf("failed to update local UTxO set: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/blinklabs-io/adder/event"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/chain"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// Deposit is a normalized view of an incoming transaction that rules are
+// evaluated against
+type Deposit struct {
+	TxHash         string
+	Slot           uint64
+	BlockNumber    uint64
+	Time           time.Time
+	SourceAddress  string
+	DepositAddress string
+	// Customer the deposit address was handed out to, if any
+	CustomerId string
+	// Lovelace sent to the deposit address
+	Lovelace uint64
+	// Native assets sent to the deposit address, keyed by unit
+	// (policy ID + hex asset name)
+	Assets map[string]uint64
+	// Native assets in the resolved TX inputs, keyed by unit
+	HeldAssets map[string]uint64
+	// Decoded TX metadata, keyed by label
+	Metadata map[uint64]any
+}
+
+// NewDeposit builds a Deposit from a transaction event. The source address
+// and assets held in the TX inputs are provided by the caller, since looking
+// them up requires a chain backend
+func NewDeposit(
+	eventTx event.TransactionEvent,
+	eventCtx event.TransactionContext,
+	depositAddress string,
+	sourceAddress string,
+	heldAssets map[string]uint64,
+) Deposit {
+	cfg := config.GetConfig()
+	ret := Deposit{
+		TxHash:         eventCtx.TransactionHash,
+		Slot:           eventCtx.SlotNumber,
+		BlockNumber:    eventCtx.BlockNumber,
+		SourceAddress:  sourceAddress,
+		DepositAddress: depositAddress,
+		Assets:         make(map[string]uint64),
+		HeldAssets:     heldAssets,
+		Metadata:       make(map[uint64]any),
+	}
+	// The time is only used for time window rules, so we leave it unset
+	// for unsupported networks
+	if slotTime, err := chain.SlotToTime(cfg.Network, ret.Slot); err == nil {
+		ret.Time = slotTime
+	}
+	for _, txOutput := range eventTx.Outputs {
+		if txOutput.Address().String() != depositAddress {
+			continue
+		}
+		ret.Lovelace += txOutput.Amount()
+		AddAssets(ret.Assets, txOutput.Assets())
+	}
+	if ret.HeldAssets == nil {
+		ret.HeldAssets = make(map[string]uint64)
+	}
+	if eventTx.Transaction != nil {
+		if metaMap, ok := eventTx.Transaction.Metadata().(lcommon.MetaMap); ok {
+			for _, pair := range metaMap.Pairs {
+				label, ok := pair.Key.(lcommon.MetaInt)
+				if !ok || label.Value == nil || !label.Value.IsUint64() {
+					continue
+				}
+				ret.Metadata[label.Value.Uint64()] = decodeMetadatum(pair.Value)
+			}
+		}
+	}
+	return ret
+}
+
+// AddAssets adds the native assets in a TX output to the provided map, keyed
+// by unit (policy ID + hex asset name)
+func AddAssets(
+	dest map[string]uint64,
+	assets *lcommon.MultiAsset[lcommon.MultiAssetTypeOutput],
+) {
+	if assets == nil {
+		return
+	}
+	for _, policyId := range assets.Policies() {
+		for _, assetName := range assets.Assets(policyId) {
+			unit := policyId.String() + hex.EncodeToString(assetName)
+			dest[unit] += assets.Asset(policyId, assetName)
+		}
+	}
+}
+
+// decodeMetadatum converts TX metadata into plain Go values
+func decodeMetadatum(m lcommon.TransactionMetadatum) any {
+	switch v := m.(type) {
+	case lcommon.MetaInt:
+		if v.Value == nil {
+			return nil
+		}
+		return v.Value.String()
+	case lcommon.MetaText:
+		return v.Value
+	case lcommon.MetaBytes:
+		return v.Value
+	case lcommon.MetaList:
+		ret := make([]any, 0, len(v.Items))
+		for _, item := range v.Items {
+			ret = append(ret, decodeMetadatum(item))
+		}
+		return ret
+	case lcommon.MetaMap:
+		ret := make(map[string]any, len(v.Pairs))
+		for _, pair := range v.Pairs {
+			ret[fmt.Sprint(decodeMetadatum(pair.Key))] = decodeMetadatum(
+				pair.Value,
+			)
+		}
+		return ret
+	}
+	return nil
+}
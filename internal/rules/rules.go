@@ -0,0 +1,484 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+)
+
+// RulesFile is the on-disk format for reward rules
+type RulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule describes the conditions a deposit must meet to receive a reward. All
+// specified conditions must match. Rules are evaluated in order, and the
+// first matching rule determines the reward
+type Rule struct {
+	Name            string                `json:"name"`
+	MinLovelace     uint64                `json:"minLovelace,omitempty"`
+	MaxLovelace     uint64                `json:"maxLovelace,omitempty"`
+	SourceAddresses []string              `json:"sourceAddresses,omitempty"`
+	CustomerIds     []string              `json:"customerIds,omitempty"`
+	DepositedAssets []AssetRequirement    `json:"depositedAssets,omitempty"`
+	HeldAssets      []AssetRequirement    `json:"heldAssets,omitempty"`
+	Metadata        []MetadataRequirement `json:"metadata,omitempty"`
+	ValidFrom       *time.Time            `json:"validFrom,omitempty"`
+	ValidUntil      *time.Time            `json:"validUntil,omitempty"`
+	Reward          RewardSpec            `json:"reward"`
+}
+
+// AssetRequirement requires a minimum quantity of a native asset
+type AssetRequirement struct {
+	PolicyId    string `json:"policyId"`
+	AssetName   string `json:"assetName,omitempty"`
+	MinQuantity uint64 `json:"minQuantity,omitempty"`
+}
+
+// MetadataRequirement requires the TX metadata to contain the specified
+// label and, optionally, a text value containing the specified string
+type MetadataRequirement struct {
+	Label    uint64 `json:"label"`
+	Contains string `json:"contains,omitempty"`
+}
+
+// RewardSpec describes the reward paid when a rule matches. The Lovelace
+// amount is raised to the minimum UTxO value if necessary
+type RewardSpec struct {
+	Lovelace uint64        `json:"lovelace"`
+	Assets   []AssetAmount `json:"assets,omitempty"`
+	// Tokens to mint under the wallet minting policy. The policy ID is
+	// ignored
+	Mint []AssetAmount `json:"mint,omitempty"`
+}
+
+// AssetAmount is a quantity of a native asset
+type AssetAmount struct {
+	PolicyId  string `json:"policyId"`
+	AssetName string `json:"assetName"`
+	Quantity  uint64 `json:"quantity"`
+}
+
+// Unit returns the asset unit (policy ID + hex asset name)
+func (a AssetAmount) Unit() string {
+	return a.PolicyId + a.AssetName
+}
+
+func (a AssetAmount) validate() error {
+	if err := a.validateName(); err != nil {
+		return err
+	}
+	if len(a.PolicyId) != 56 {
+		return fmt.Errorf("invalid policy ID: %s", a.PolicyId)
+	}
+	if _, err := hex.DecodeString(a.PolicyId); err != nil {
+		return fmt.Errorf("invalid policy ID: %s", a.PolicyId)
+	}
+	return nil
+}
+
+// validateName checks the asset name and quantity, which is all that's
+// needed for minted tokens
+func (a AssetAmount) validateName() error {
+	if len(a.AssetName) > 64 {
+		return fmt.Errorf("asset name too long: %s", a.AssetName)
+	}
+	if _, err := hex.DecodeString(a.AssetName); err != nil {
+		return fmt.Errorf("asset name must be hex encoded: %s", a.AssetName)
+	}
+	if a.Quantity == 0 {
+		return fmt.Errorf("invalid quantity for asset %s", a.Unit())
+	}
+	return nil
+}
+
+// ParseMintAmount parses a mint amount in the format
+// <hex asset name>:<quantity>
+func ParseMintAmount(value string) (AssetAmount, error) {
+	assetName, qtyStr, ok := strings.Cut(value, ":")
+	if !ok {
+		return AssetAmount{}, fmt.Errorf("missing quantity: %s", value)
+	}
+	qty, err := strconv.ParseUint(qtyStr, 10, 64)
+	if err != nil {
+		return AssetAmount{}, fmt.Errorf("invalid quantity: %s", value)
+	}
+	ret := AssetAmount{
+		AssetName: assetName,
+		Quantity:  qty,
+	}
+	if err := ret.validateName(); err != nil {
+		return AssetAmount{}, err
+	}
+	return ret, nil
+}
+
+// ParseAssetAmount parses an asset amount in the format
+// <policy ID>.<hex asset name>:<quantity>
+func ParseAssetAmount(value string) (AssetAmount, error) {
+	unit, qtyStr, ok := strings.Cut(value, ":")
+	if !ok {
+		return AssetAmount{}, fmt.Errorf("missing quantity: %s", value)
+	}
+	policyId, assetName, _ := strings.Cut(unit, ".")
+	qty, err := strconv.ParseUint(qtyStr, 10, 64)
+	if err != nil {
+		return AssetAmount{}, fmt.Errorf("invalid quantity: %s", value)
+	}
+	ret := AssetAmount{
+		PolicyId:  policyId,
+		AssetName: assetName,
+		Quantity:  qty,
+	}
+	if err := ret.validate(); err != nil {
+		return AssetAmount{}, err
+	}
+	return ret, nil
+}
+
+// Explanation describes why a rule did or didn't match a deposit
+type Explanation struct {
+	Rule    string
+	Matched bool
+	Reasons []string
+}
+
+func (e Explanation) String() string {
+	if e.Matched {
+		return fmt.Sprintf(
+			"rule %q: matched: %s",
+			e.Rule,
+			strings.Join(e.Reasons, "; "),
+		)
+	}
+	return fmt.Sprintf(
+		"rule %q: no match: %s",
+		e.Rule,
+		strings.Join(e.Reasons, "; "),
+	)
+}
+
+// Result is the outcome of evaluating all rules against a deposit
+type Result struct {
+	// Match is the first matching rule, or nil if no rule matched
+	Match        *Rule
+	Explanations []Explanation
+}
+
+// Explain returns a human readable explanation of the evaluation
+func (r Result) Explain() string {
+	lines := make([]string, 0, len(r.Explanations))
+	for _, explanation := range r.Explanations {
+		lines = append(lines, explanation.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type Engine struct {
+	rules []Rule
+}
+
+// Singleton rule engine instance
+var globalEngine = &Engine{}
+
+// NewEngine returns a rule engine for the provided rules
+func NewEngine(rules []Rule) *Engine {
+	return &Engine{
+		rules: rules,
+	}
+}
+
+// Load configures the rules from the configured rules file, or from the
+// reward config when no rules file is specified
+func (e *Engine) Load() error {
+	cfg := config.GetConfig()
+	if cfg.Reward.RulesFile == "" {
+		rule, err := defaultRule()
+		if err != nil {
+			return err
+		}
+		e.rules = []Rule{rule}
+		return nil
+	}
+	data, err := os.ReadFile(cfg.Reward.RulesFile)
+	if err != nil {
+		return fmt.Errorf("failed to read rules file: %w", err)
+	}
+	var rulesFile RulesFile
+	if err := json.Unmarshal(data, &rulesFile); err != nil {
+		return fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	if len(rulesFile.Rules) == 0 {
+		return errors.New("no rules defined in rules file")
+	}
+	for idx, rule := range rulesFile.Rules {
+		if rule.Name == "" {
+			rulesFile.Rules[idx].Name = fmt.Sprintf("rule-%d", idx)
+		}
+		for _, asset := range rule.Reward.Assets {
+			if err := asset.validate(); err != nil {
+				return fmt.Errorf(
+					"invalid reward asset in rule %q: %w",
+					rulesFile.Rules[idx].Name,
+					err,
+				)
+			}
+		}
+		for _, asset := range rule.Reward.Mint {
+			if err := asset.validateName(); err != nil {
+				return fmt.Errorf(
+					"invalid mint asset in rule %q: %w",
+					rulesFile.Rules[idx].Name,
+					err,
+				)
+			}
+		}
+	}
+	e.rules = rulesFile.Rules
+	return nil
+}
+
+// defaultRule returns a rule built from the reward config
+func defaultRule() (Rule, error) {
+	cfg := config.GetConfig()
+	ret := Rule{
+		Name:        "default",
+		MinLovelace: cfg.Reward.MinLovelace,
+		Reward: RewardSpec{
+			Lovelace: cfg.Reward.RewardAmount,
+		},
+	}
+	if cfg.Reward.SourceAddress != "" {
+		ret.SourceAddresses = []string{cfg.Reward.SourceAddress}
+	}
+	for _, assetStr := range cfg.Reward.RewardAssets {
+		asset, err := ParseAssetAmount(assetStr)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid reward asset: %w", err)
+		}
+		ret.Reward.Assets = append(ret.Reward.Assets, asset)
+	}
+	for _, assetStr := range cfg.Reward.RewardMint {
+		asset, err := ParseMintAmount(assetStr)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid reward mint: %w", err)
+		}
+		ret.Reward.Mint = append(ret.Reward.Mint, asset)
+	}
+	return ret, nil
+}
+
+// Evaluate checks each rule in order against the deposit and returns the
+// first matching rule, along with an explanation for every rule evaluated
+func (e *Engine) Evaluate(deposit Deposit) Result {
+	var ret Result
+	for idx := range e.rules {
+		rule := &e.rules[idx]
+		explanation := rule.Evaluate(deposit)
+		ret.Explanations = append(ret.Explanations, explanation)
+		if explanation.Matched && ret.Match == nil {
+			ret.Match = rule
+		}
+	}
+	return ret
+}
+
+// Evaluate checks the rule against the deposit
+func (r *Rule) Evaluate(deposit Deposit) Explanation {
+	ret := Explanation{
+		Rule:    r.Name,
+		Matched: true,
+	}
+	check := func(ok bool, reason string) {
+		if !ok {
+			ret.Matched = false
+		}
+		ret.Reasons = append(ret.Reasons, reason)
+	}
+	if r.MinLovelace > 0 {
+		check(
+			deposit.Lovelace >= r.MinLovelace,
+			fmt.Sprintf(
+				"deposit of %d lovelace vs minimum %d",
+				deposit.Lovelace,
+				r.MinLovelace,
+			),
+		)
+	}
+	if r.MaxLovelace > 0 {
+		check(
+			deposit.Lovelace <= r.MaxLovelace,
+			fmt.Sprintf(
+				"deposit of %d lovelace vs maximum %d",
+				deposit.Lovelace,
+				r.MaxLovelace,
+			),
+		)
+	}
+	if len(r.SourceAddresses) > 0 {
+		check(
+			slices.Contains(r.SourceAddresses, deposit.SourceAddress),
+			fmt.Sprintf(
+				"source address %s in allowed list",
+				deposit.SourceAddress,
+			),
+		)
+	}
+	if len(r.CustomerIds) > 0 {
+		check(
+			slices.Contains(r.CustomerIds, deposit.CustomerId),
+			fmt.Sprintf(
+				"customer %q in allowed list",
+				deposit.CustomerId,
+			),
+		)
+	}
+	for _, req := range r.DepositedAssets {
+		have := req.quantity(deposit.Assets)
+		check(
+			have >= req.minQuantity(),
+			fmt.Sprintf(
+				"deposited %d of asset %s vs minimum %d",
+				have,
+				req.String(),
+				req.minQuantity(),
+			),
+		)
+	}
+	for _, req := range r.HeldAssets {
+		have := req.quantity(deposit.HeldAssets)
+		check(
+			have >= req.minQuantity(),
+			fmt.Sprintf(
+				"sender holds %d of asset %s vs minimum %d",
+				have,
+				req.String(),
+				req.minQuantity(),
+			),
+		)
+	}
+	for _, req := range r.Metadata {
+		value, ok := deposit.Metadata[req.Label]
+		if !ok {
+			check(false, fmt.Sprintf("metadata label %d not present", req.Label))
+			continue
+		}
+		if req.Contains == "" {
+			check(true, fmt.Sprintf("metadata label %d present", req.Label))
+			continue
+		}
+		check(
+			metadataContains(value, req.Contains),
+			fmt.Sprintf(
+				"metadata label %d contains %q",
+				req.Label,
+				req.Contains,
+			),
+		)
+	}
+	if r.ValidFrom != nil || r.ValidUntil != nil {
+		if deposit.Time.IsZero() {
+			check(false, "deposit time unknown")
+		} else {
+			if r.ValidFrom != nil {
+				check(
+					!deposit.Time.Before(*r.ValidFrom),
+					fmt.Sprintf(
+						"deposit time %s vs start %s",
+						deposit.Time.UTC().Format(time.RFC3339),
+						r.ValidFrom.UTC().Format(time.RFC3339),
+					),
+				)
+			}
+			if r.ValidUntil != nil {
+				check(
+					deposit.Time.Before(*r.ValidUntil),
+					fmt.Sprintf(
+						"deposit time %s vs end %s",
+						deposit.Time.UTC().Format(time.RFC3339),
+						r.ValidUntil.UTC().Format(time.RFC3339),
+					),
+				)
+			}
+		}
+	}
+	if len(ret.Reasons) == 0 {
+		ret.Reasons = append(ret.Reasons, "no conditions")
+	}
+	return ret
+}
+
+func (a AssetRequirement) String() string {
+	if a.AssetName == "" {
+		return a.PolicyId
+	}
+	return a.PolicyId + "." + a.AssetName
+}
+
+func (a AssetRequirement) minQuantity() uint64 {
+	return max(a.MinQuantity, 1)
+}
+
+// quantity returns the total quantity of matching assets. An empty asset
+// name matches any asset under the policy
+func (a AssetRequirement) quantity(assets map[string]uint64) uint64 {
+	if a.AssetName != "" {
+		return assets[a.PolicyId+a.AssetName]
+	}
+	var ret uint64
+	for unit, qty := range assets {
+		if strings.HasPrefix(unit, a.PolicyId) {
+			ret += qty
+		}
+	}
+	return ret
+}
+
+// metadataContains searches decoded metadata for a text value containing the
+// specified string
+func metadataContains(value any, search string) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.Contains(v, search)
+	case []any:
+		for _, item := range v {
+			if metadataContains(item, search) {
+				return true
+			}
+		}
+	case map[string]any:
+		for key, item := range v {
+			if strings.Contains(key, search) || metadataContains(item, search) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetEngine returns the global rule engine instance
+func GetEngine() *Engine {
+	return globalEngine
+}
@@ -0,0 +1,367 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+)
+
+const (
+	testPolicyId  = "00000000000000000000000000000000000000000000000000000001"
+	testAssetName = "746f6b656e" // "token"
+)
+
+func TestRuleEvaluate(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	testDefs := []struct {
+		name    string
+		rule    Rule
+		deposit Deposit
+		matched bool
+		// Substrings expected in the explanation
+		reasons []string
+	}{
+		{
+			name:    "no conditions",
+			rule:    Rule{Name: "any"},
+			matched: true,
+			reasons: []string{"no conditions"},
+		},
+		{
+			name:    "deposit above minimum",
+			rule:    Rule{Name: "min", MinLovelace: 50_000_000},
+			deposit: Deposit{Lovelace: 50_000_000},
+			matched: true,
+			reasons: []string{"deposit of 50000000 lovelace vs minimum 50000000"},
+		},
+		{
+			name:    "deposit below minimum",
+			rule:    Rule{Name: "min", MinLovelace: 50_000_000},
+			deposit: Deposit{Lovelace: 49_999_999},
+			reasons: []string{"deposit of 49999999 lovelace vs minimum 50000000"},
+		},
+		{
+			name: "deposit within tier",
+			rule: Rule{
+				Name:        "tier",
+				MinLovelace: 10_000_000,
+				MaxLovelace: 100_000_000,
+			},
+			deposit: Deposit{Lovelace: 100_000_000},
+			matched: true,
+		},
+		{
+			name: "deposit above tier",
+			rule: Rule{
+				Name:        "tier",
+				MinLovelace: 10_000_000,
+				MaxLovelace: 100_000_000,
+			},
+			deposit: Deposit{Lovelace: 100_000_001},
+			reasons: []string{"deposit of 100000001 lovelace vs maximum 100000000"},
+		},
+		{
+			name: "allowed source address",
+			rule: Rule{
+				Name:            "source",
+				SourceAddresses: []string{"addr_a", "addr_b"},
+			},
+			deposit: Deposit{SourceAddress: "addr_b"},
+			matched: true,
+			reasons: []string{"source address addr_b in allowed list"},
+		},
+		{
+			name: "other source address",
+			rule: Rule{
+				Name:            "source",
+				SourceAddresses: []string{"addr_a", "addr_b"},
+			},
+			deposit: Deposit{SourceAddress: "addr_c"},
+		},
+		{
+			name:    "allowed customer",
+			rule:    Rule{Name: "customer", CustomerIds: []string{"alice"}},
+			deposit: Deposit{CustomerId: "alice"},
+			matched: true,
+			reasons: []string{`customer "alice" in allowed list`},
+		},
+		{
+			name:    "deposit without customer",
+			rule:    Rule{Name: "customer", CustomerIds: []string{"alice"}},
+			deposit: Deposit{},
+			reasons: []string{`customer "" in allowed list`},
+		},
+		{
+			name: "deposited asset",
+			rule: Rule{
+				Name: "asset",
+				DepositedAssets: []AssetRequirement{
+					{PolicyId: testPolicyId, MinQuantity: 10},
+				},
+			},
+			deposit: Deposit{
+				Assets: map[string]uint64{
+					testPolicyId + testAssetName: 6,
+					testPolicyId + "00":          4,
+				},
+			},
+			matched: true,
+		},
+		{
+			name: "not enough of held asset",
+			rule: Rule{
+				Name: "held",
+				HeldAssets: []AssetRequirement{
+					{PolicyId: testPolicyId, AssetName: testAssetName},
+				},
+			},
+			deposit: Deposit{
+				HeldAssets: map[string]uint64{testPolicyId + "00": 1},
+			},
+			reasons: []string{"sender holds 0 of asset"},
+		},
+		{
+			name: "metadata contains text",
+			rule: Rule{
+				Name:     "metadata",
+				Metadata: []MetadataRequirement{{Label: 674, Contains: "promo"}},
+			},
+			deposit: Deposit{
+				Metadata: map[uint64]any{
+					674: map[string]any{"msg": []any{"summer promo"}},
+				},
+			},
+			matched: true,
+		},
+		{
+			name: "metadata label missing",
+			rule: Rule{
+				Name:     "metadata",
+				Metadata: []MetadataRequirement{{Label: 674}},
+			},
+			deposit: Deposit{Metadata: map[uint64]any{1: "x"}},
+			reasons: []string{"metadata label 674 not present"},
+		},
+		{
+			name: "within time window",
+			rule: Rule{
+				Name:       "window",
+				ValidFrom:  &start,
+				ValidUntil: &end,
+			},
+			deposit: Deposit{Time: start},
+			matched: true,
+		},
+		{
+			name: "after time window",
+			rule: Rule{
+				Name:       "window",
+				ValidFrom:  &start,
+				ValidUntil: &end,
+			},
+			deposit: Deposit{Time: end},
+			reasons: []string{"vs end 2025-01-02T00:00:00Z"},
+		},
+		{
+			name:    "unknown deposit time",
+			rule:    Rule{Name: "window", ValidFrom: &start},
+			deposit: Deposit{},
+			reasons: []string{"deposit time unknown"},
+		},
+		{
+			name: "all conditions must match",
+			rule: Rule{
+				Name:            "all",
+				MinLovelace:     10_000_000,
+				SourceAddresses: []string{"addr_a"},
+			},
+			deposit: Deposit{Lovelace: 20_000_000, SourceAddress: "addr_b"},
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			explanation := testDef.rule.Evaluate(testDef.deposit)
+			if explanation.Matched != testDef.matched {
+				t.Fatalf(
+					"got matched %v, expected %v: %s",
+					explanation.Matched,
+					testDef.matched,
+					explanation,
+				)
+			}
+			if explanation.Rule != testDef.rule.Name {
+				t.Fatalf("got rule name %q", explanation.Rule)
+			}
+			for _, reason := range testDef.reasons {
+				if !strings.Contains(explanation.String(), reason) {
+					t.Fatalf(
+						"expected explanation to contain %q, got: %s",
+						reason,
+						explanation,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	engine := NewEngine(
+		[]Rule{
+			{
+				Name:        "large",
+				MinLovelace: 100_000_000,
+				Reward:      RewardSpec{Lovelace: 20_000_000},
+			},
+			{
+				Name:        "small",
+				MinLovelace: 10_000_000,
+				Reward:      RewardSpec{Lovelace: 2_000_000},
+			},
+			{
+				Name:   "fallback",
+				Reward: RewardSpec{Lovelace: 1_000_000},
+			},
+		},
+	)
+	testDefs := []struct {
+		lovelace      uint64
+		expectedMatch string
+	}{
+		{lovelace: 150_000_000, expectedMatch: "large"},
+		{lovelace: 50_000_000, expectedMatch: "small"},
+		{lovelace: 1_000_000, expectedMatch: "fallback"},
+	}
+	for _, testDef := range testDefs {
+		result := engine.Evaluate(Deposit{Lovelace: testDef.lovelace})
+		if result.Match == nil || result.Match.Name != testDef.expectedMatch {
+			t.Fatalf(
+				"deposit of %d: expected rule %q to match:\n%s",
+				testDef.lovelace,
+				testDef.expectedMatch,
+				result.Explain(),
+			)
+		}
+		// Every rule is explained, including those after the match
+		lines := strings.Split(result.Explain(), "\n")
+		if len(lines) != 3 {
+			t.Fatalf(
+				"expected an explanation for each rule, got:\n%s",
+				result.Explain(),
+			)
+		}
+		if !strings.HasPrefix(lines[0], `rule "large": `) ||
+			!strings.HasPrefix(lines[2], `rule "fallback": matched`) {
+			t.Fatalf("unexpected explanation:\n%s", result.Explain())
+		}
+	}
+	// No rules, no match
+	if result := NewEngine(nil).Evaluate(Deposit{}); result.Match != nil {
+		t.Fatalf("expected no match without rules")
+	}
+}
+
+func TestEngineLoad(t *testing.T) {
+	cfg := config.GetConfig()
+	t.Cleanup(func() {
+		cfg.Reward.RulesFile = ""
+		cfg.Reward.SourceAddress = ""
+	})
+	// The reward config is used without a rules file
+	cfg.Reward.SourceAddress = "addr_a"
+	cfg.Reward.MinLovelace = 50_000_000
+	cfg.Reward.RewardAmount = 5_000_000
+	engine := &Engine{}
+	if err := engine.Load(); err != nil {
+		t.Fatalf("failed to load default rule: %s", err)
+	}
+	result := engine.Evaluate(
+		Deposit{SourceAddress: "addr_a", Lovelace: 50_000_000},
+	)
+	if result.Match == nil || result.Match.Reward.Lovelace != 5_000_000 {
+		t.Fatalf("expected the default rule to match:\n%s", result.Explain())
+	}
+	result = engine.Evaluate(
+		Deposit{SourceAddress: "addr_b", Lovelace: 50_000_000},
+	)
+	if result.Match != nil {
+		t.Fatalf("expected no match from another source address")
+	}
+	testDefs := []struct {
+		name        string
+		rulesFile   string
+		expectedErr string
+	}{
+		{
+			name:      "valid rules",
+			rulesFile: `{"rules": [{"minLovelace": 1000000, "reward": {"lovelace": 2000000}}]}`,
+		},
+		{
+			name:        "no rules",
+			rulesFile:   `{"rules": []}`,
+			expectedErr: "no rules defined",
+		},
+		{
+			name:        "invalid JSON",
+			rulesFile:   `{"rules": [`,
+			expectedErr: "failed to parse rules file",
+		},
+		{
+			name:        "invalid reward asset",
+			rulesFile:   `{"rules": [{"name": "bad", "reward": {"assets": [{"policyId": "abc", "assetName": "00", "quantity": 1}]}}]}`,
+			expectedErr: `invalid reward asset in rule "bad"`,
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			cfg.Reward.RulesFile = filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(
+				cfg.Reward.RulesFile,
+				[]byte(testDef.rulesFile),
+				0o600,
+			); err != nil {
+				t.Fatalf("failed to write rules file: %s", err)
+			}
+			engine := &Engine{}
+			err := engine.Load()
+			if testDef.expectedErr != "" {
+				if err == nil ||
+					!strings.Contains(err.Error(), testDef.expectedErr) {
+					t.Fatalf(
+						"expected error containing %q, got: %v",
+						testDef.expectedErr,
+						err,
+					)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to load rules: %s", err)
+			}
+			// Unnamed rules get a name based on their index
+			result := engine.Evaluate(Deposit{Lovelace: 1_000_000})
+			if result.Match == nil || result.Match.Name != "rule-0" {
+				t.Fatalf("expected rule-0 to match:\n%s", result.Explain())
+			}
+		})
+	}
+}
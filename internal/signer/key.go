@@ -0,0 +1,152 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/blinklabs-io/bursa"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/fivebinaries/go-cardano-serialization/bip32"
+)
+
+const (
+	// Text envelope type prefix for payment signing keys
+	paymentKeyTypePrefix = "Payment"
+
+	// Key sizes for the signing key formats used by cardano-cli and bursa
+	seedKeySize     = ed25519.SeedSize
+	extendedKeySize = bip32.XPrv_Size
+	// Size of the private part of an extended key, before the chain code
+	extendedPrivateSize = 64
+	// Extended key with the public key between the private key and the chain
+	// code, as used in PaymentExtendedSigningKeyShelley_ed25519_bip32 files
+	extendedKeyWithPublicSize = extendedKeySize + ed25519.PublicKeySize
+)
+
+// keySigner signs with a payment key held in memory
+type keySigner struct {
+	publicKey []byte
+	// Either a standard ed25519 key, as created by "cardano-cli address
+	// key-gen", or a BIP32-Ed25519 key derived from a mnemonic
+	privateKey  ed25519.PrivateKey
+	extendedKey bip32.XPrv
+}
+
+// NewKeySigner returns a signer for a payment signing key in a cardano-cli
+// text envelope
+func NewKeySigner(keyFile bursa.KeyFile) (Signer, error) {
+	if !strings.HasPrefix(keyFile.Type, paymentKeyTypePrefix) ||
+		!strings.Contains(keyFile.Type, "SigningKey") {
+		return nil, fmt.Errorf(
+			"not a payment signing key: %s",
+			keyFile.Type,
+		)
+	}
+	keyCbor, err := hex.DecodeString(keyFile.CborHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key CBOR hex: %w", err)
+	}
+	var keyBytes []byte
+	if _, err := cbor.Decode(keyCbor, &keyBytes); err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	ret := &keySigner{}
+	switch len(keyBytes) {
+	case seedKeySize:
+		ret.privateKey = ed25519.NewKeyFromSeed(keyBytes)
+		ret.publicKey = ret.privateKey.Public().(ed25519.PublicKey)
+	case extendedKeySize:
+		ret.extendedKey = bip32.XPrv(keyBytes)
+		ret.publicKey = ret.extendedKey.Public().PublicKey()
+	case extendedKeyWithPublicSize:
+		ret.extendedKey = bip32.XPrv(
+			append(
+				bytes.Clone(keyBytes[:extendedPrivateSize]),
+				keyBytes[extendedPrivateSize+ed25519.PublicKeySize:]...,
+			),
+		)
+		ret.publicKey = ret.extendedKey.Public().PublicKey()
+		keyPublic := keyBytes[extendedPrivateSize : extendedPrivateSize+ed25519.PublicKeySize]
+		if !bytes.Equal(ret.publicKey, keyPublic) {
+			return nil, errors.New("public key does not match private key")
+		}
+	default:
+		return nil, fmt.Errorf("unexpected key size: %d", len(keyBytes))
+	}
+	return ret, nil
+}
+
+// NewExtendedKeySigner returns a signer for a BIP32-Ed25519 payment key, such
+// as one derived from the wallet mnemonic
+func NewExtendedKeySigner(key bip32.XPrv) (Signer, error) {
+	if len(key) != extendedKeySize {
+		return nil, fmt.Errorf("unexpected key size: %d", len(key))
+	}
+	return &keySigner{
+		extendedKey: key,
+		publicKey:   key.Public().PublicKey(),
+	}, nil
+}
+
+// NewWalletSigner returns a signer for the wallet payment key
+func NewWalletSigner(w *bursa.Wallet) (Signer, error) {
+	if w == nil {
+		return nil, errors.New("no wallet provided")
+	}
+	return NewKeySigner(w.PaymentExtendedSKey)
+}
+
+// NewFileSigner returns a signer for a payment signing key file created by
+// cardano-cli
+func NewFileSigner(path string) (Signer, error) {
+	if path == "" {
+		return nil, errors.New("no signing key file provided")
+	}
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	var keyFile bursa.KeyFile
+	if err := json.Unmarshal(data, &keyFile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	ret, err := NewKeySigner(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ret, nil
+}
+
+func (k *keySigner) PublicKey() []byte {
+	return k.publicKey
+}
+
+func (k *keySigner) Sign(txBody []byte) ([]byte, error) {
+	txHash := lcommon.Blake2b256Hash(txBody).Bytes()
+	if k.privateKey != nil {
+		return ed25519.Sign(k.privateKey, txHash), nil
+	}
+	signature := k.extendedKey.Sign(txHash)
+	return signature[:], nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/blinklabs-io/bursa"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art"
+
+func testWallet(t *testing.T) *bursa.Wallet {
+	t.Helper()
+	w, err := bursa.NewWallet(testMnemonic, "preview", "", 0, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to create wallet: %s", err)
+	}
+	return w
+}
+
+// testKeyFile returns a text envelope for the raw key bytes
+func testKeyFile(t *testing.T, keyType string, keyBytes []byte) bursa.KeyFile {
+	t.Helper()
+	keyCbor, err := cbor.Encode(keyBytes)
+	if err != nil {
+		t.Fatalf("failed to encode key: %s", err)
+	}
+	return bursa.KeyFile{
+		Type:    keyType,
+		CborHex: hex.EncodeToString(keyCbor),
+	}
+}
+
+func TestNewKeySigner(t *testing.T) {
+	w := testWallet(t)
+	seed := bytes.Repeat([]byte{0x42}, ed25519.SeedSize)
+	seedPublicKey := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+	walletSigner, err := NewKeySigner(w.PaymentSKey)
+	if err != nil {
+		t.Fatalf("failed to create signer from wallet key: %s", err)
+	}
+	walletPublicKey := walletSigner.PublicKey()
+	// Extended key with a public key that doesn't match the private key
+	var extendedKey []byte
+	if _, err := cbor.Decode(
+		mustDecodeHex(t, w.PaymentExtendedSKey.CborHex),
+		&extendedKey,
+	); err != nil {
+		t.Fatalf("failed to decode extended key: %s", err)
+	}
+	badExtendedKey := bytes.Clone(extendedKey)
+	badExtendedKey[extendedPrivateSize] ^= 0xff
+	testDefs := []struct {
+		name              string
+		keyFile           bursa.KeyFile
+		expectedPublicKey []byte
+		expectError       bool
+	}{
+		{
+			name: "ed25519 key from cardano-cli",
+			keyFile: testKeyFile(
+				t,
+				"PaymentSigningKeyShelley_ed25519",
+				seed,
+			),
+			expectedPublicKey: seedPublicKey,
+		},
+		{
+			name:              "BIP32 key from bursa",
+			keyFile:           w.PaymentSKey,
+			expectedPublicKey: walletPublicKey,
+		},
+		{
+			name:              "extended key with public key",
+			keyFile:           w.PaymentExtendedSKey,
+			expectedPublicKey: walletPublicKey,
+		},
+		{
+			name: "extended key with mismatched public key",
+			keyFile: testKeyFile(
+				t,
+				w.PaymentExtendedSKey.Type,
+				badExtendedKey,
+			),
+			expectError: true,
+		},
+		{
+			name:        "stake key",
+			keyFile:     w.StakeSKey,
+			expectError: true,
+		},
+		{
+			name:        "verification key",
+			keyFile:     w.PaymentVKey,
+			expectError: true,
+		},
+		{
+			name: "unexpected key size",
+			keyFile: testKeyFile(
+				t,
+				"PaymentSigningKeyShelley_ed25519",
+				seed[:16],
+			),
+			expectError: true,
+		},
+	}
+	txBody := []byte("test TX body")
+	txHash := lcommon.Blake2b256Hash(txBody)
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			s, err := NewKeySigner(testDef.keyFile)
+			if testDef.expectError {
+				if err == nil {
+					t.Fatalf("did not get expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.Equal(s.PublicKey(), testDef.expectedPublicKey) {
+				t.Fatalf(
+					"got public key %x, expected %x",
+					s.PublicKey(),
+					testDef.expectedPublicKey,
+				)
+			}
+			signature, err := s.Sign(txBody)
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+			// The signature is for the TX body hash
+			if !ed25519.Verify(s.PublicKey(), txHash.Bytes(), signature) {
+				t.Fatalf("signature does not verify")
+			}
+		})
+	}
+}
+
+func mustDecodeHex(t *testing.T, data string) []byte {
+	t.Helper()
+	ret, err := hex.DecodeString(data)
+	if err != nil {
+		t.Fatalf("failed to decode hex: %s", err)
+	}
+	return ret
+}
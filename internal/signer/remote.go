@@ -0,0 +1,213 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// The remote signer protocol is JSON over HTTP, either on a local TCP port or
+// a Unix socket:
+//
+//	GET /public-key -> {"publicKey": "<hex>"}
+//	POST /sign {"txBody": "<hex>"} -> {"signature": "<hex>"}
+//
+// The signer hashes the CBOR-encoded TX body itself, so that it knows what it
+// signs. When the signer has a token, requests need to provide it as
+// "Authorization: Bearer <token>". Errors are returned with a non-200 status
+// and {"error": "<message>"}
+const (
+	remotePathPublicKey = "/public-key"
+	remotePathSign      = "/sign"
+
+	// Scheme for Unix socket URLs
+	unixScheme = "unix"
+	// Placeholder host for requests over a Unix socket
+	unixHost = "signer"
+
+	// Maximum size of a remote signer request or response body, which fits
+	// a hex-encoded TX body of the maximum TX size
+	maxRemoteBodySize = 64 * 1024
+)
+
+type publicKeyResponse struct {
+	PublicKey string `json:"publicKey"`
+}
+
+type signRequest struct {
+	TxBody string `json:"txBody"`
+}
+
+type signResponse struct {
+	Signature string `json:"signature"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// remoteSigner signs with a key held by a separate signing service
+type remoteSigner struct {
+	baseUrl   string
+	token     string
+	client    *http.Client
+	publicKey []byte
+}
+
+// NewRemoteSigner returns a signer for the remote signer at the provided URL,
+// which is either http://<host>:<port> or unix://<socket path>. The token is
+// sent with each request, if provided
+func NewRemoteSigner(
+	signerUrl string,
+	token string,
+	timeout time.Duration,
+) (Signer, error) {
+	u, err := url.Parse(signerUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signer URL: %w", err)
+	}
+	ret := &remoteSigner{
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+	switch u.Scheme {
+	case unixScheme:
+		socketPath := unixSocketPath(u)
+		ret.baseUrl = "http://" + unixHost
+		ret.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		}
+	case "http", "https":
+		ret.baseUrl = strings.TrimSuffix(signerUrl, "/")
+	default:
+		return nil, fmt.Errorf("unsupported remote signer URL: %s", signerUrl)
+	}
+	// Fetch the public key up front, which also makes sure that the signer
+	// is reachable
+	var resp publicKeyResponse
+	if err := ret.request(http.MethodGet, remotePathPublicKey, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get remote signer public key: %w", err)
+	}
+	ret.publicKey, err = hex.DecodeString(resp.PublicKey)
+	if err != nil || len(ret.publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf(
+			"invalid remote signer public key: %s",
+			resp.PublicKey,
+		)
+	}
+	return ret, nil
+}
+
+func (r *remoteSigner) PublicKey() []byte {
+	return r.publicKey
+}
+
+func (r *remoteSigner) Sign(txBody []byte) ([]byte, error) {
+	var resp signResponse
+	if err := r.request(
+		http.MethodPost,
+		remotePathSign,
+		signRequest{TxBody: hex.EncodeToString(txBody)},
+		&resp,
+	); err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	signature, err := hex.DecodeString(resp.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signer signature: %w", err)
+	}
+	// Make sure that the signer signed the TX with the key we expect
+	txHash := lcommon.Blake2b256Hash(txBody)
+	if !ed25519.Verify(r.publicKey, txHash.Bytes(), signature) {
+		return nil, errors.New("invalid signature from remote signer")
+	}
+	return signature, nil
+}
+
+// request sends a request to the remote signer and decodes the JSON response
+func (r *remoteSigner) request(
+	method string,
+	path string,
+	reqBody any,
+	respBody any,
+) error {
+	var body io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		method,
+		r.baseUrl+path,
+		body,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req) // #nosec G704
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp errorResponse
+		if err := json.Unmarshal(data, &errResp); err == nil &&
+			errResp.Error != "" {
+			return fmt.Errorf("%d: %s", resp.StatusCode, errResp.Error)
+		}
+		return fmt.Errorf("%d: %s", resp.StatusCode, data)
+	}
+	if err := json.Unmarshal(data, respBody); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// unixSocketPath returns the socket path from a unix:// URL, which may be
+// relative, as in unix://signer.sock
+func unixSocketPath(u *url.URL) string {
+	return u.Host + u.Path
+}
@@ -0,0 +1,300 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/ledger/conway"
+)
+
+// Policy restricts the TXs that the remote signer signs. Without allowed
+// addresses, any TX is signed
+type Policy struct {
+	// Addresses that TX outputs may pay to, besides the signer's own
+	// addresses
+	AllowedAddresses []string
+	// Maximum TX fee in lovelace, or 0 for no limit
+	MaxFee uint64
+	// Whether to sign TXs that mint or burn tokens
+	AllowMint bool
+	// Whether to sign TXs with certificates or other governance actions,
+	// which move deposits around
+	AllowCertificates bool
+	// Whether to sign TXs withdrawing staking rewards
+	AllowWithdrawals bool
+}
+
+// NewHandler returns an HTTP handler serving the remote signer protocol for
+// the provided signer. When a token is provided, requests without it are
+// rejected. TXs that the policy doesn't allow are refused
+func NewHandler(
+	s Signer,
+	token string,
+	policy Policy,
+) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET "+remotePathPublicKey,
+		func(w http.ResponseWriter, r *http.Request) {
+			writeJson(
+				w,
+				http.StatusOK,
+				publicKeyResponse{
+					PublicKey: hex.EncodeToString(s.PublicKey()),
+				},
+			)
+		},
+	)
+	mux.HandleFunc(
+		"POST "+remotePathSign,
+		func(w http.ResponseWriter, r *http.Request) {
+			var req signRequest
+			body := http.MaxBytesReader(w, r.Body, maxRemoteBodySize)
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+			txBody, err := hex.DecodeString(req.TxBody)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid TX body")
+				return
+			}
+			decodedBody, err := decodeTxBody(txBody)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			txHash := lcommon.Blake2b256Hash(txBody).String()
+			slog.Info(
+				fmt.Sprintf(
+					"TX %s pays a fee of %d lovelace",
+					txHash,
+					decodedBody.Fee(),
+				),
+			)
+			for _, txOutput := range decodedBody.Outputs() {
+				slog.Info(
+					fmt.Sprintf(
+						"TX %s pays %d lovelace to %s",
+						txHash,
+						txOutput.Amount(),
+						txOutput.Address().String(),
+					),
+				)
+			}
+			if len(policy.AllowedAddresses) > 0 {
+				if err := checkTx(s, decodedBody, policy); err != nil {
+					slog.Warn(
+						fmt.Sprintf("refusing to sign TX %s: %s", txHash, err),
+					)
+					writeError(w, http.StatusForbidden, err.Error())
+					return
+				}
+			}
+			signature, err := s.Sign(txBody)
+			if err != nil {
+				slog.Error(
+					fmt.Sprintf("failed to sign TX %s: %s", txHash, err),
+				)
+				writeError(w, http.StatusInternalServerError, "failed to sign")
+				return
+			}
+			slog.Info("signed TX " + txHash)
+			writeJson(
+				w,
+				http.StatusOK,
+				signResponse{Signature: hex.EncodeToString(signature)},
+			)
+		},
+	)
+	if token == "" {
+		return mux
+	}
+	return requireToken(mux, token)
+}
+
+// requireToken rejects requests without the bearer token
+func requireToken(next http.Handler, token string) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(provided, expected) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeTxBody decodes a CBOR-encoded TX body. Only Conway TX bodies are
+// supported, which is what the TX builder creates
+func decodeTxBody(txBody []byte) (*conway.ConwayTransactionBody, error) {
+	ret, err := conway.NewConwayTransactionBodyFromCbor(txBody)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"invalid TX body, only Conway TX bodies are supported: %w",
+			err,
+		)
+	}
+	return ret, nil
+}
+
+// checkTx makes sure that the TX is allowed by the policy. Besides the
+// outputs, this covers everything else that can move funds out of the
+// signer's addresses
+func checkTx(
+	s Signer,
+	body *conway.ConwayTransactionBody,
+	policy Policy,
+) error {
+	if policy.MaxFee > 0 && body.Fee() > policy.MaxFee {
+		return fmt.Errorf(
+			"fee of %d lovelace is above the maximum of %d",
+			body.Fee(),
+			policy.MaxFee,
+		)
+	}
+	if !policy.AllowMint && body.AssetMint() != nil &&
+		len(body.AssetMint().Policies()) > 0 {
+		return errors.New("minting is not allowed")
+	}
+	if !policy.AllowCertificates &&
+		(len(body.Certificates()) > 0 ||
+			len(body.VotingProcedures()) > 0 ||
+			len(body.ProposalProcedures()) > 0 ||
+			body.Donation() > 0) {
+		return errors.New("certificates and governance actions are not allowed")
+	}
+	if !policy.AllowWithdrawals && len(body.Withdrawals()) > 0 {
+		return errors.New("withdrawals are not allowed")
+	}
+	txOutputs := body.Outputs()
+	// The collateral return is paid out if a script fails
+	if collateralReturn := body.CollateralReturn(); collateralReturn != nil {
+		txOutputs = append(txOutputs, collateralReturn)
+	}
+	return checkTxOutputs(s, txOutputs, policy.AllowedAddresses)
+}
+
+// checkTxOutputs makes sure that the TX outputs only pay to the allowed
+// addresses, or to addresses with the signer's payment key, such as change
+func checkTxOutputs(
+	s Signer,
+	txOutputs []lcommon.TransactionOutput,
+	allowedAddresses []string,
+) error {
+	keyHash := KeyHash(s)
+	for _, txOutput := range txOutputs {
+		addr := txOutput.Address()
+		if slices.Contains(allowedAddresses, addr.String()) {
+			continue
+		}
+		// Shelley address types with an even type have a payment key
+		paymentKeyHash := addr.PaymentKeyHash()
+		if addr.Type() < lcommon.AddressTypeByron && addr.Type()%2 == 0 &&
+			bytes.Equal(paymentKeyHash.Bytes(), keyHash) {
+			continue
+		}
+		return fmt.Errorf(
+			"output to %s is not an allowed destination",
+			addr.String(),
+		)
+	}
+	return nil
+}
+
+// Serve serves the remote signer protocol for the provided signer on a Unix
+// socket, as unix://<socket path>, or a TCP address, as <host>:<port>. Anyone
+// who can connect can sign with the key, so the socket is only accessible by
+// the current user, and a token is required to listen on a non-loopback
+// address
+func Serve(
+	s Signer,
+	listenAddr string,
+	token string,
+	policy Policy,
+) error {
+	var listener net.Listener
+	u, err := url.Parse(listenAddr)
+	if err == nil && u.Scheme == unixScheme {
+		socketPath := unixSocketPath(u)
+		// Remove a socket left behind by a previous run
+		if info, err := os.Stat(socketPath); err == nil &&
+			info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(socketPath); err != nil {
+				return err
+			}
+		}
+		listener, err = net.Listen("unix", socketPath)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(socketPath, 0o600); err != nil {
+			listener.Close()
+			return err
+		}
+	} else {
+		listener, err = net.Listen("tcp", listenAddr)
+		if err != nil {
+			return err
+		}
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok &&
+			!addr.IP.IsLoopback() && token == "" {
+			listener.Close()
+			return fmt.Errorf(
+				"refusing to listen on non-loopback address %s without a token",
+				listenAddr,
+			)
+		}
+	}
+	slog.Info("remote signer listening on " + listenAddr)
+	server := &http.Server{
+		Handler:           NewHandler(s, token, policy),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := server.Serve(listener); err != nil &&
+		!errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to write remote signer response: %s", err),
+		)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, errorResponse{Error: message})
+}
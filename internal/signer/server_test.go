@@ -0,0 +1,364 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blinklabs-io/gouroboros/cbor"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+const testTimeout = 5 * time.Second
+
+// testSigner returns a signer for a fixed ed25519 key
+func testSigner(t *testing.T, b byte) Signer {
+	t.Helper()
+	s, err := NewKeySigner(
+		testKeyFile(
+			t,
+			"PaymentSigningKeyShelley_ed25519",
+			bytes.Repeat([]byte{b}, ed25519.SeedSize),
+		),
+	)
+	if err != nil {
+		t.Fatalf("failed to create signer: %s", err)
+	}
+	return s
+}
+
+// testTxBody returns a CBOR-encoded TX body paying to the addresses
+func testTxBody(t *testing.T, addrs ...lcommon.Address) []byte {
+	t.Helper()
+	return testTxBodyWith(t, nil, addrs...)
+}
+
+// testTxBodyWith returns a CBOR-encoded TX body paying to the addresses, with
+// the extra fields added or replaced by their CBOR map key
+func testTxBodyWith(
+	t *testing.T,
+	fields map[uint]any,
+	addrs ...lcommon.Address,
+) []byte {
+	t.Helper()
+	outputs := make([]any, 0, len(addrs))
+	for _, addr := range addrs {
+		outputs = append(outputs, testTxOutput(t, addr))
+	}
+	body := map[uint]any{
+		0: []any{[]any{bytes.Repeat([]byte{0x01}, 32), uint64(0)}},
+		1: outputs,
+		2: uint64(170_000),
+	}
+	for key, value := range fields {
+		body[key] = value
+	}
+	txBody, err := cbor.Encode(body)
+	if err != nil {
+		t.Fatalf("failed to encode TX body: %s", err)
+	}
+	return txBody
+}
+
+// testTxOutput returns a TX output paying 2 ADA to the address
+func testTxOutput(t *testing.T, addr lcommon.Address) []any {
+	t.Helper()
+	addrBytes, err := addr.Bytes()
+	if err != nil {
+		t.Fatalf("failed to encode address: %s", err)
+	}
+	return []any{addrBytes, uint64(2_000_000)}
+}
+
+// testKeyAddress returns an enterprise address for the payment key hash
+func testKeyAddress(t *testing.T, keyHash []byte) lcommon.Address {
+	t.Helper()
+	addr, err := lcommon.NewAddressFromParts(
+		lcommon.AddressTypeKeyNone,
+		0,
+		keyHash,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to build address: %s", err)
+	}
+	return addr
+}
+
+func TestRemoteSigner(t *testing.T) {
+	s := testSigner(t, 0x42)
+	ownAddr := testKeyAddress(t, KeyHash(s))
+	allowedAddr := testKeyAddress(t, bytes.Repeat([]byte{0x02}, 28))
+	otherAddr := testKeyAddress(t, bytes.Repeat([]byte{0x03}, 28))
+	allowed := Policy{AllowedAddresses: []string{allowedAddr.String()}}
+	mint := map[uint]any{
+		9: map[cbor.ByteString]map[cbor.ByteString]int64{
+			cbor.NewByteString(bytes.Repeat([]byte{0x04}, 28)): {
+				cbor.NewByteString([]byte("token")): 1,
+			},
+		},
+	}
+	testDefs := []struct {
+		name        string
+		serverToken string
+		clientToken string
+		policy      Policy
+		txBody      []byte
+		// Expected error from creating the client, or from signing
+		expectedError string
+	}{
+		{
+			name:   "sign without restrictions",
+			txBody: testTxBody(t, otherAddr, ownAddr),
+		},
+		{
+			name:        "sign with token",
+			serverToken: "secret",
+			clientToken: "secret",
+			txBody:      testTxBody(t, otherAddr),
+		},
+		{
+			name:          "missing token",
+			serverToken:   "secret",
+			txBody:        testTxBody(t, otherAddr),
+			expectedError: "invalid token",
+		},
+		{
+			name:          "wrong token",
+			serverToken:   "secret",
+			clientToken:   "guess",
+			txBody:        testTxBody(t, otherAddr),
+			expectedError: "invalid token",
+		},
+		{
+			name:   "allowed destination and change",
+			policy: allowed,
+			txBody: testTxBody(t, allowedAddr, ownAddr),
+		},
+		{
+			name:          "destination not allowed",
+			policy:        allowed,
+			txBody:        testTxBody(t, allowedAddr, otherAddr),
+			expectedError: "not an allowed destination",
+		},
+		{
+			name: "fee within maximum",
+			policy: Policy{
+				AllowedAddresses: allowed.AllowedAddresses,
+				MaxFee:           170_000,
+			},
+			txBody: testTxBody(t, allowedAddr),
+		},
+		{
+			name: "fee above maximum",
+			policy: Policy{
+				AllowedAddresses: allowed.AllowedAddresses,
+				MaxFee:           169_999,
+			},
+			txBody:        testTxBody(t, allowedAddr),
+			expectedError: "above the maximum",
+		},
+		{
+			name:          "mint not allowed",
+			policy:        allowed,
+			txBody:        testTxBodyWith(t, mint, allowedAddr),
+			expectedError: "minting is not allowed",
+		},
+		{
+			name: "mint allowed",
+			policy: Policy{
+				AllowedAddresses: allowed.AllowedAddresses,
+				AllowMint:        true,
+			},
+			txBody: testTxBodyWith(t, mint, allowedAddr),
+		},
+		{
+			name:   "mint without restrictions",
+			txBody: testTxBodyWith(t, mint, otherAddr),
+		},
+		{
+			name:   "certificate not allowed",
+			policy: allowed,
+			txBody: testTxBodyWith(
+				t,
+				map[uint]any{
+					// Stake registration
+					4: []any{
+						[]any{0, []any{0, bytes.Repeat([]byte{0x03}, 28)}},
+					},
+				},
+				allowedAddr,
+			),
+			expectedError: "certificates and governance actions are not allowed",
+		},
+		{
+			name:   "withdrawal not allowed",
+			policy: allowed,
+			txBody: testTxBodyWith(
+				t,
+				map[uint]any{
+					5: map[cbor.ByteString]uint64{
+						// Testnet reward address
+						cbor.NewByteString(
+							append(
+								[]byte{0xe0},
+								bytes.Repeat([]byte{0x03}, 28)...,
+							),
+						): 1_000_000,
+					},
+				},
+				allowedAddr,
+			),
+			expectedError: "withdrawals are not allowed",
+		},
+		{
+			name:   "collateral return not allowed",
+			policy: allowed,
+			txBody: testTxBodyWith(
+				t,
+				map[uint]any{16: testTxOutput(t, otherAddr)},
+				allowedAddr,
+			),
+			expectedError: "not an allowed destination",
+		},
+		{
+			name:          "invalid TX body",
+			txBody:        []byte("not a TX body"),
+			expectedError: "only Conway TX bodies are supported",
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			server := httptest.NewServer(
+				NewHandler(s, testDef.serverToken, testDef.policy),
+			)
+			defer server.Close()
+			client, err := NewRemoteSigner(
+				server.URL,
+				testDef.clientToken,
+				testTimeout,
+			)
+			if err == nil {
+				if !bytes.Equal(client.PublicKey(), s.PublicKey()) {
+					t.Fatalf("got wrong public key from remote signer")
+				}
+				var signature []byte
+				signature, err = client.Sign(testDef.txBody)
+				if err == nil {
+					txHash := lcommon.Blake2b256Hash(testDef.txBody)
+					if !ed25519.Verify(
+						s.PublicKey(),
+						txHash.Bytes(),
+						signature,
+					) {
+						t.Fatalf("signature does not verify")
+					}
+				}
+			}
+			if testDef.expectedError == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("did not get expected error")
+			}
+			if !strings.Contains(err.Error(), testDef.expectedError) {
+				t.Fatalf(
+					"got error %q, expected %q",
+					err,
+					testDef.expectedError,
+				)
+			}
+		})
+	}
+}
+
+func TestRemoteSignerUnixSocket(t *testing.T) {
+	s := testSigner(t, 0x42)
+	socketPath := filepath.Join(t.TempDir(), "signer.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	server := &http.Server{
+		Handler:           NewHandler(s, "", Policy{}),
+		ReadHeaderTimeout: testTimeout,
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+	client, err := NewRemoteSigner("unix://"+socketPath, "", testTimeout)
+	if err != nil {
+		t.Fatalf("failed to create remote signer: %s", err)
+	}
+	txBody := testTxBody(t, testKeyAddress(t, KeyHash(s)))
+	signature, err := client.Sign(txBody)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	txHash := lcommon.Blake2b256Hash(txBody)
+	if !ed25519.Verify(s.PublicKey(), txHash.Bytes(), signature) {
+		t.Fatalf("signature does not verify")
+	}
+}
+
+func TestRemoteSignerWrongKey(t *testing.T) {
+	// A signer that signs with a different key than it advertises
+	server := httptest.NewServer(
+		NewHandler(
+			&mismatchedSigner{
+				Signer: testSigner(t, 0x42),
+				other:  testSigner(t, 0x43),
+			},
+			"",
+			Policy{},
+		),
+	)
+	defer server.Close()
+	client, err := NewRemoteSigner(server.URL, "", testTimeout)
+	if err != nil {
+		t.Fatalf("failed to create remote signer: %s", err)
+	}
+	if _, err := client.Sign(testTxBody(t)); err == nil {
+		t.Fatalf("did not get expected error")
+	}
+}
+
+func TestServeRefusesPublicAddressWithoutToken(t *testing.T) {
+	err := Serve(testSigner(t, 0x42), "0.0.0.0:0", "", Policy{})
+	if err == nil || !strings.Contains(err.Error(), "without a token") {
+		t.Fatalf("got error %v, expected refusal", err)
+	}
+}
+
+type mismatchedSigner struct {
+	Signer
+	other Signer
+}
+
+func (m *mismatchedSigner) Sign(txBody []byte) ([]byte, error) {
+	return m.other.Sign(txBody)
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"fmt"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+	"github.com/blinklabs-io/bursa"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+const (
+	SignerWallet = "wallet"
+	SignerFile   = "file"
+	SignerRemote = "remote"
+)
+
+// Signer signs TXs with a payment key, which doesn't need to be held by this
+// process
+type Signer interface {
+	// PublicKey returns the ed25519 public key
+	PublicKey() []byte
+	// Sign returns the ed25519 signature for the hash of the CBOR-encoded TX
+	// body. The body is provided rather than its hash so that remote signers
+	// can inspect what they're signing
+	Sign(txBody []byte) ([]byte, error)
+}
+
+var globalSigner Signer
+
+// Setup creates the signer specified in the config
+func Setup() (Signer, error) {
+	// Return existing signer instance if available
+	if globalSigner != nil {
+		return globalSigner, nil
+	}
+	cfg := config.GetConfig()
+	signerType := Type()
+	var ret Signer
+	var err error
+	switch signerType {
+	case SignerWallet:
+		w, err := wallet.Setup()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure wallet: %w", err)
+		}
+		ret, err = NewWalletSigner(w)
+		if err != nil {
+			return nil, err
+		}
+	case SignerFile:
+		ret, err = NewFileSigner(cfg.Signer.KeyFile)
+	case SignerRemote:
+		ret, err = NewRemoteSigner(
+			cfg.Signer.Url,
+			cfg.Signer.Token,
+			cfg.Signer.Timeout,
+		)
+	default:
+		return nil, fmt.Errorf("unknown signer type: %s", signerType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	globalSigner = ret
+	return globalSigner, nil
+}
+
+// Type returns the configured signer type, picking one based on the other
+// config values if none was specified
+func Type() string {
+	cfg := config.GetConfig()
+	switch {
+	case cfg.Signer.Type != "":
+		return cfg.Signer.Type
+	case cfg.Signer.Url != "":
+		return SignerRemote
+	case cfg.Signer.KeyFile != "":
+		return SignerFile
+	default:
+		return SignerWallet
+	}
+}
+
+// SetupWallet sets up the wallet for the signer. When signing with the wallet,
+// that's the wallet the signer was created from. Otherwise, the wallet address
+// is built from the signer payment key and the configured stake address, so
+// that the mnemonic doesn't need to be loaded
+func SetupWallet(s Signer) (*bursa.Wallet, error) {
+	if w := wallet.GetWallet(); w != nil {
+		return w, nil
+	}
+	addr, err := PaymentAddress(s)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.SetupWatchOnly(addr)
+}
+
+// PaymentAddress returns the address for the signer payment key and the
+// configured stake address, or an enterprise address if no stake address is
+// configured
+func PaymentAddress(s Signer) (string, error) {
+	cfg := config.GetConfig()
+	network, ok := ouroboros.NetworkByName(cfg.Network)
+	if !ok {
+		return "", fmt.Errorf("unknown network: %s", cfg.Network)
+	}
+	addrType := uint8(lcommon.AddressTypeKeyNone)
+	var stakeKeyHash []byte
+	if cfg.Signer.StakeAddress != "" {
+		stakeAddr, err := lcommon.NewAddress(cfg.Signer.StakeAddress)
+		if err != nil {
+			return "", fmt.Errorf("invalid stake address: %w", err)
+		}
+		if stakeAddr.Type() != lcommon.AddressTypeNoneKey {
+			return "", fmt.Errorf(
+				"not a stake key address: %s",
+				cfg.Signer.StakeAddress,
+			)
+		}
+		if stakeAddr.NetworkId() != uint(network.Id) {
+			return "", fmt.Errorf(
+				"stake address %s does not belong to network %s",
+				cfg.Signer.StakeAddress,
+				network.Name,
+			)
+		}
+		addrType = lcommon.AddressTypeKeyKey
+		stakeKeyHash = stakeAddr.StakeKeyHash().Bytes()
+	}
+	addr, err := lcommon.NewAddressFromParts(
+		addrType,
+		network.Id,
+		KeyHash(s),
+		stakeKeyHash,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to build address: %w", err)
+	}
+	return addr.String(), nil
+}
+
+// GetSigner returns the global signer instance
+func GetSigner() Signer {
+	return globalSigner
+}
+
+// KeyHash returns the payment key hash of the signer key
+func KeyHash(s Signer) []byte {
+	keyHash := lcommon.Blake2b224Hash(s.PublicKey())
+	return keyHash.Bytes()
+}
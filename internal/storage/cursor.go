@@ -0,0 +1,105 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// Number of recent chain points to keep for rollback safety
+	cursorMaxPoints = 10
+)
+
+var (
+	cursorBucket = []byte("cursor")
+	cursorKey    = []byte("points")
+)
+
+// CursorPoint represents a processed chain point
+type CursorPoint struct {
+	Slot uint64 `json:"slot"`
+	Hash string `json:"hash"`
+}
+
+// GetCursor returns the most recently processed chain points, newest first
+func (s *Storage) GetCursor() ([]CursorPoint, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret []CursorPoint
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(cursorBucket).Get(cursorKey)
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// UpdateCursor records a newly processed chain point
+func (s *Storage) UpdateCursor(slot uint64, hash string) error {
+	return s.modifyCursor(func(points []CursorPoint) []CursorPoint {
+		points = append(
+			[]CursorPoint{{Slot: slot, Hash: hash}},
+			points...,
+		)
+		if len(points) > cursorMaxPoints {
+			points = points[:cursorMaxPoints]
+		}
+		return points
+	})
+}
+
+// RollbackCursor removes any recorded chain points after the specified slot
+func (s *Storage) RollbackCursor(slot uint64) error {
+	return s.modifyCursor(func(points []CursorPoint) []CursorPoint {
+		ret := make([]CursorPoint, 0, len(points))
+		for _, point := range points {
+			if point.Slot <= slot {
+				ret = append(ret, point)
+			}
+		}
+		return ret
+	})
+}
+
+func (s *Storage) modifyCursor(
+	modifyFunc func([]CursorPoint) []CursorPoint,
+) error {
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(cursorBucket)
+		var points []CursorPoint
+		if data := bucket.Get(cursorKey); data != nil {
+			if err := json.Unmarshal(data, &points); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(modifyFunc(points))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(cursorKey, data)
+	})
+}
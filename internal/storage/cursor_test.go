@@ -0,0 +1,158 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+)
+
+// setupStorage loads a storage instance backed by a temporary directory
+func setupStorage(t *testing.T) *Storage {
+	t.Helper()
+	cfg := config.GetConfig()
+	origDirectory := cfg.Storage.Directory
+	cfg.Storage.Directory = t.TempDir()
+	s := &Storage{}
+	if err := s.Load(); err != nil {
+		t.Fatalf("failed to load storage: %s", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		cfg.Storage.Directory = origDirectory
+	})
+	return s
+}
+
+func testPointHash(slot uint64) string {
+	return fmt.Sprintf("%064x", slot)
+}
+
+func TestCursor(t *testing.T) {
+	testDefs := []struct {
+		name string
+		// Slots of the chain points to record, in order
+		slots []uint64
+		// Slot to roll back to after recording the points, or 0 for none
+		rollbackSlot  uint64
+		expectedSlots []uint64
+	}{
+		{
+			name:          "empty",
+			expectedSlots: nil,
+		},
+		{
+			name:          "newest first",
+			slots:         []uint64{10, 20, 30},
+			expectedSlots: []uint64{30, 20, 10},
+		},
+		{
+			name:  "trimmed to the most recent points",
+			slots: []uint64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 110, 120},
+			expectedSlots: []uint64{
+				120, 110, 100, 90, 80, 70, 60, 50, 40, 30,
+			},
+		},
+		{
+			name:          "rollback removes newer points",
+			slots:         []uint64{10, 20, 30, 40},
+			rollbackSlot:  25,
+			expectedSlots: []uint64{20, 10},
+		},
+		{
+			name:          "rollback keeps the point at the slot",
+			slots:         []uint64{10, 20, 30},
+			rollbackSlot:  20,
+			expectedSlots: []uint64{20, 10},
+		},
+		{
+			name:          "rollback before all points",
+			slots:         []uint64{10, 20},
+			rollbackSlot:  5,
+			expectedSlots: []uint64{},
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			s := setupStorage(t)
+			for _, slot := range testDef.slots {
+				if err := s.UpdateCursor(slot, testPointHash(slot)); err != nil {
+					t.Fatalf("failed to update cursor: %s", err)
+				}
+			}
+			if testDef.rollbackSlot > 0 {
+				if err := s.RollbackCursor(testDef.rollbackSlot); err != nil {
+					t.Fatalf("failed to roll back cursor: %s", err)
+				}
+			}
+			cursor, err := s.GetCursor()
+			if err != nil {
+				t.Fatalf("failed to get cursor: %s", err)
+			}
+			if len(cursor) != len(testDef.expectedSlots) {
+				t.Fatalf(
+					"got %d cursor points, expected %d",
+					len(cursor),
+					len(testDef.expectedSlots),
+				)
+			}
+			for idx, point := range cursor {
+				if point.Slot != testDef.expectedSlots[idx] {
+					t.Fatalf(
+						"got point %d at slot %d, expected %d",
+						idx,
+						point.Slot,
+						testDef.expectedSlots[idx],
+					)
+				}
+				if point.Hash != testPointHash(point.Slot) {
+					t.Fatalf(
+						"got hash %s for slot %d",
+						point.Hash,
+						point.Slot,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestCursorPersisted(t *testing.T) {
+	s := setupStorage(t)
+	for _, slot := range []uint64{10, 20, 30} {
+		if err := s.UpdateCursor(slot, testPointHash(slot)); err != nil {
+			t.Fatalf("failed to update cursor: %s", err)
+		}
+	}
+	// The cursor survives a restart
+	if err := s.Close(); err != nil {
+		t.Fatalf("failed to close storage: %s", err)
+	}
+	if _, err := s.GetCursor(); err == nil {
+		t.Fatalf("expected an error with the storage closed")
+	}
+	if err := s.Load(); err != nil {
+		t.Fatalf("failed to load storage: %s", err)
+	}
+	cursor, err := s.GetCursor()
+	if err != nil {
+		t.Fatalf("failed to get cursor: %s", err)
+	}
+	if len(cursor) != 3 || cursor[0].Slot != 30 || cursor[2].Slot != 10 {
+		t.Fatalf("got cursor %v after restart", cursor)
+	}
+}
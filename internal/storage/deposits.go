@@ -0,0 +1,201 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	depositAddressesBucket = []byte("depositAddresses")
+	// Maps customer IDs to their deposit address
+	customersBucket = []byte("customers")
+)
+
+// ErrPayoutAddressMismatch is returned when assigning a deposit address to a
+// customer that already has one with another payout address
+var ErrPayoutAddressMismatch = errors.New(
+	"customer already has a different payout address",
+)
+
+// DepositAddress is a derived wallet address handed out to a customer, keyed
+// by address
+type DepositAddress struct {
+	Address    string `json:"address"`
+	CustomerId string `json:"customerId"`
+	// Payment key index the address was derived from
+	Index uint32 `json:"index"`
+	// Where to send rewards for deposits from the customer, if known
+	PayoutAddress string    `json:"payoutAddress,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// GetDepositAddress returns the deposit address entry for the specified
+// address, or nil if there isn't one
+func (s *Storage) GetDepositAddress(addr string) (*DepositAddress, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret *DepositAddress
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(depositAddressesBucket).Get([]byte(addr))
+		if data == nil {
+			return nil
+		}
+		ret = &DepositAddress{}
+		return json.Unmarshal(data, ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetCustomerDepositAddress returns the deposit address entry for the
+// specified customer, or nil if there isn't one
+func (s *Storage) GetCustomerDepositAddress(
+	customerId string,
+) (*DepositAddress, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret *DepositAddress
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ret, err = customerDepositAddress(tx, customerId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// AssignDepositAddress returns the deposit address for the specified
+// customer, creating it if it doesn't exist yet. New addresses are derived
+// with the provided function from the next unused index, starting at
+// firstIndex. The payout address is only set when the address is created, and
+// ErrPayoutAddressMismatch is returned if the customer already has another
+// one. The second return value is whether a new address was created
+func (s *Storage) AssignDepositAddress(
+	customerId string,
+	payoutAddress string,
+	firstIndex uint32,
+	deriveFunc func(index uint32) (string, error),
+) (*DepositAddress, bool, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, false, err
+	}
+	var ret *DepositAddress
+	var created bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		existing, err := customerDepositAddress(tx, customerId)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if payoutAddress != "" &&
+				payoutAddress != existing.PayoutAddress {
+				return fmt.Errorf(
+					"%w: customer %s has payout address %q",
+					ErrPayoutAddressMismatch,
+					customerId,
+					existing.PayoutAddress,
+				)
+			}
+			ret = existing
+			return nil
+		}
+		bucket := tx.Bucket(depositAddressesBucket)
+		// The bucket sequence holds the next unused index
+		index := max(bucket.Sequence(), uint64(firstIndex))
+		addr, err := deriveFunc(uint32(index)) // #nosec G115
+		if err != nil {
+			return err
+		}
+		if err := bucket.SetSequence(index + 1); err != nil {
+			return err
+		}
+		ret = &DepositAddress{
+			Address:       addr,
+			CustomerId:    customerId,
+			Index:         uint32(index), // #nosec G115
+			PayoutAddress: payoutAddress,
+			CreatedAt:     time.Now(),
+		}
+		data, err := json.Marshal(ret)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte(addr), data); err != nil {
+			return err
+		}
+		created = true
+		return tx.Bucket(customersBucket).Put(
+			[]byte(customerId),
+			[]byte(addr),
+		)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return ret, created, nil
+}
+
+// ListDepositAddresses returns all deposit addresses handed out to customers
+func (s *Storage) ListDepositAddresses() ([]DepositAddress, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret []DepositAddress
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(depositAddressesBucket).ForEach(func(k, v []byte) error {
+			var depositAddr DepositAddress
+			if err := json.Unmarshal(v, &depositAddr); err != nil {
+				return err
+			}
+			ret = append(ret, depositAddr)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func customerDepositAddress(
+	tx *bolt.Tx,
+	customerId string,
+) (*DepositAddress, error) {
+	addr := tx.Bucket(customersBucket).Get([]byte(customerId))
+	if addr == nil {
+		return nil, nil
+	}
+	data := tx.Bucket(depositAddressesBucket).Get(addr)
+	if data == nil {
+		return nil, nil
+	}
+	ret := &DepositAddress{}
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
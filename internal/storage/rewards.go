@@ -0,0 +1,161 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var rewardsBucket = []byte("rewards")
+
+type RewardStatus string
+
+const (
+	RewardStatusPending   RewardStatus = "pending"
+	RewardStatusBuilt     RewardStatus = "built"
+	RewardStatusSubmitted RewardStatus = "submitted"
+	RewardStatusConfirmed RewardStatus = "confirmed"
+	RewardStatusFailed    RewardStatus = "failed"
+	// Given up on after too many failed attempts
+	RewardStatusDeadLetter RewardStatus = "deadLetter"
+)
+
+// Reward is a reward ledger entry, keyed by the hash of the transaction that
+// triggered the reward
+type Reward struct {
+	TxHash      string       `json:"txHash"`
+	Status      RewardStatus `json:"status"`
+	Rule        string       `json:"rule,omitempty"`
+	RewardTxId  string       `json:"rewardTxId,omitempty"`
+	Destination string       `json:"destination,omitempty"`
+	// Customer the deposit was attributed to, if any
+	CustomerId    string `json:"customerId,omitempty"`
+	DepositAmount uint64 `json:"depositAmount"`
+	RewardAmount  uint64 `json:"rewardAmount"`
+	// Native assets included in the reward, keyed by unit
+	RewardAssets map[string]uint64 `json:"rewardAssets,omitempty"`
+	// Tokens minted for the reward, keyed by hex asset name
+	MintedAssets map[string]uint64 `json:"mintedAssets,omitempty"`
+	// Slot after which the reward TX can no longer be added to the chain
+	Ttl uint64 `json:"ttl,omitempty"`
+	// Number of failed attempts to pay out the reward
+	Attempts int `json:"attempts,omitempty"`
+	// When to retry building or submitting the reward TX, if scheduled
+	NextAttempt time.Time `json:"nextAttempt,omitzero"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// GetReward returns the reward ledger entry for the specified triggering TX
+// hash, or nil if there isn't one
+func (s *Storage) GetReward(txHash string) (*Reward, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret *Reward
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(rewardsBucket).Get([]byte(txHash))
+		if data == nil {
+			return nil
+		}
+		ret = &Reward{}
+		return json.Unmarshal(data, ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ClaimReward atomically records a new pending reward. It returns false
+// without modifying anything if the triggering TX already has an entry that
+// isn't in the failed state
+func (s *Storage) ClaimReward(reward *Reward) (bool, error) {
+	if err := s.checkLoaded(); err != nil {
+		return false, err
+	}
+	var claimed bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(rewardsBucket)
+		if data := bucket.Get([]byte(reward.TxHash)); data != nil {
+			var existing Reward
+			if err := json.Unmarshal(data, &existing); err != nil {
+				return err
+			}
+			if existing.Status != RewardStatusFailed {
+				return nil
+			}
+			reward.CreatedAt = existing.CreatedAt
+		} else {
+			reward.CreatedAt = time.Now()
+		}
+		reward.Status = RewardStatusPending
+		reward.UpdatedAt = time.Now()
+		if err := putReward(bucket, reward); err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return claimed, nil
+}
+
+// UpdateReward stores the provided reward ledger entry
+func (s *Storage) UpdateReward(reward *Reward) error {
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+	reward.UpdatedAt = time.Now()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putReward(tx.Bucket(rewardsBucket), reward)
+	})
+}
+
+// ListRewards returns all reward ledger entries
+func (s *Storage) ListRewards() ([]Reward, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret []Reward
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(rewardsBucket).ForEach(func(k, v []byte) error {
+			var reward Reward
+			if err := json.Unmarshal(v, &reward); err != nil {
+				return err
+			}
+			ret = append(ret, reward)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func putReward(bucket *bolt.Bucket, reward *Reward) error {
+	data, err := json.Marshal(reward)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(reward.TxHash), data)
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	dbFileName = "workshop.db"
+)
+
+var allBuckets = [][]byte{
+	cursorBucket,
+	customersBucket,
+	depositAddressesBucket,
+	rewardsBucket,
+	transactionsBucket,
+	utxosBucket,
+}
+
+type Storage struct {
+	db *bolt.DB
+}
+
+// Singleton storage instance
+var globalStorage = &Storage{}
+
+// Load opens the on-disk database, creating it if necessary
+func (s *Storage) Load() error {
+	if s.db != nil {
+		return nil
+	}
+	cfg := config.GetConfig()
+	if err := os.MkdirAll(cfg.Storage.Directory, 0o700); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	db, err := bolt.Open(
+		filepath.Join(cfg.Storage.Directory, dbFileName),
+		0o600,
+		&bolt.Options{Timeout: 1 * time.Second},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	s.db = db
+	return nil
+}
+
+// Close closes the on-disk database
+func (s *Storage) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+func (s *Storage) checkLoaded() error {
+	if s.db == nil {
+		return errors.New("storage not loaded")
+	}
+	return nil
+}
+
+// GetStorage returns the global storage instance
+func GetStorage() *Storage {
+	return globalStorage
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var transactionsBucket = []byte("transactions")
+
+type TxStatus string
+
+const (
+	// Treasury TX waiting for cosigner signatures before it can be submitted
+	TxStatusPendingSignatures TxStatus = "pendingSignatures"
+	// Submitted, but not seen on chain yet
+	TxStatusSubmitted TxStatus = "submitted"
+	// Seen in a block, but without enough confirmations yet
+	TxStatusIncluded TxStatus = "included"
+	// Seen in a block with enough confirmations
+	TxStatusConfirmed TxStatus = "confirmed"
+	// The chain moved past the TTL without the TX being included
+	TxStatusExpired TxStatus = "expired"
+	// The block the TX was included in was rolled back. The TX may still be
+	// included in another block before it expires
+	TxStatusRolledBack TxStatus = "rolledBack"
+	// Explicitly rejected by the node
+	TxStatusRejected TxStatus = "rejected"
+)
+
+// Transaction is a reward TX that we submitted, keyed by TX ID
+type Transaction struct {
+	TxId   string   `json:"txId"`
+	Status TxStatus `json:"status"`
+	// Slot after which the TX can no longer be added to the chain
+	Ttl uint64 `json:"ttl,omitempty"`
+	// Hashes of the TXs that triggered the rewards paid out by this TX
+	RewardTxHashes []string `json:"rewardTxHashes,omitempty"`
+	// Hex-encoded signed TX, so that it can be resubmitted
+	TxCbor string `json:"txCbor,omitempty"`
+	// Block the TX was included in, if any
+	Slot        uint64    `json:"slot,omitempty"`
+	BlockNumber uint64    `json:"blockNumber,omitempty"`
+	BlockHash   string    `json:"blockHash,omitempty"`
+	SubmittedAt time.Time `json:"submittedAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Resolved returns whether the TX has reached a final state
+func (t *Transaction) Resolved() bool {
+	return t.Status == TxStatusConfirmed || t.Status == TxStatusExpired ||
+		t.Status == TxStatusRejected
+}
+
+// GetTransaction returns the submitted TX with the specified ID, or nil if
+// there isn't one
+func (s *Storage) GetTransaction(txId string) (*Transaction, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret *Transaction
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(transactionsBucket).Get([]byte(txId))
+		if data == nil {
+			return nil
+		}
+		ret = &Transaction{}
+		return json.Unmarshal(data, ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// LookupTransaction returns the submitted TX with the specified ID. If there
+// isn't one, it looks for a reward triggered by the TX with that hash instead,
+// and returns it along with its reward TX, if it was submitted. Both are nil
+// if neither is found
+func (s *Storage) LookupTransaction(txId string) (*Transaction, *Reward, error) {
+	ret, err := s.GetTransaction(txId)
+	if err != nil || ret != nil {
+		return ret, nil, err
+	}
+	reward, err := s.GetReward(txId)
+	if err != nil || reward == nil || reward.RewardTxId == "" {
+		return nil, reward, err
+	}
+	ret, err = s.GetTransaction(reward.RewardTxId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ret, reward, nil
+}
+
+// UpdateTransaction stores the provided submitted TX
+func (s *Storage) UpdateTransaction(transaction *Transaction) error {
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+	transaction.UpdatedAt = time.Now()
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(transactionsBucket).Put(
+			[]byte(transaction.TxId),
+			data,
+		)
+	})
+}
+
+// ListTransactions returns all submitted TXs
+func (s *Storage) ListTransactions() ([]Transaction, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret []Transaction
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(transactionsBucket).ForEach(func(k, v []byte) error {
+			var transaction Transaction
+			if err := json.Unmarshal(v, &transaction); err != nil {
+				return err
+			}
+			ret = append(ret, transaction)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
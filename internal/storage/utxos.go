@@ -0,0 +1,218 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var utxosBucket = []byte("utxos")
+
+// Utxo is an entry in the local UTxO set. Spent entries are kept until
+// they're too old to be affected by a rollback
+type Utxo struct {
+	TxHash  string `json:"txHash"`
+	Index   uint32 `json:"index"`
+	Address string `json:"address"`
+	// CBOR encoded TX output
+	Output []byte `json:"output"`
+	// Slot of the TX that created the UTxO
+	Slot uint64 `json:"slot"`
+	// Slot of the TX that spent the UTxO, or 0 if unspent
+	SpentSlot uint64 `json:"spentSlot,omitempty"`
+}
+
+// UtxoRef identifies a UTxO
+type UtxoRef struct {
+	TxHash string
+	Index  uint32
+}
+
+func utxoKey(txHash string, index uint32) []byte {
+	return fmt.Appendf(nil, "%s#%d", txHash, index)
+}
+
+// GetUtxo returns the UTxO for the specified ref, or nil if it isn't in the
+// local UTxO set. Recently spent UTxOs are also returned, so that the inputs
+// of recent TXs can be resolved
+func (s *Storage) GetUtxo(txHash string, index uint32) (*Utxo, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret *Utxo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(utxosBucket).Get(utxoKey(txHash, index))
+		if data == nil {
+			return nil
+		}
+		ret = &Utxo{}
+		return json.Unmarshal(data, ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetUtxosByAddress returns the unspent UTxOs for the specified address
+func (s *Storage) GetUtxosByAddress(address string) ([]Utxo, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	var ret []Utxo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(utxosBucket).ForEach(func(k, v []byte) error {
+			var utxo Utxo
+			if err := json.Unmarshal(v, &utxo); err != nil {
+				return err
+			}
+			if utxo.Address == address && utxo.SpentSlot == 0 {
+				ret = append(ret, utxo)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// HasUtxos returns whether the local UTxO set contains any entries
+func (s *Storage) HasUtxos() (bool, error) {
+	if err := s.checkLoaded(); err != nil {
+		return false, err
+	}
+	var ret bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		k, _ := tx.Bucket(utxosBucket).Cursor().First()
+		ret = k != nil
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return ret, nil
+}
+
+// ApplyUtxos atomically marks the specified UTxOs as spent and adds the
+// created UTxOs. Spent refs that aren't in the local UTxO set are ignored
+func (s *Storage) ApplyUtxos(
+	slot uint64,
+	spent []UtxoRef,
+	created []Utxo,
+) error {
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(utxosBucket)
+		for _, ref := range spent {
+			key := utxoKey(ref.TxHash, ref.Index)
+			data := bucket.Get(key)
+			if data == nil {
+				continue
+			}
+			var utxo Utxo
+			if err := json.Unmarshal(data, &utxo); err != nil {
+				return err
+			}
+			utxo.SpentSlot = slot
+			if err := putUtxo(bucket, utxo); err != nil {
+				return err
+			}
+		}
+		for _, utxo := range created {
+			if err := putUtxo(bucket, utxo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RollbackUtxos undoes any changes to the local UTxO set after the specified
+// slot
+func (s *Storage) RollbackUtxos(slot uint64) error {
+	return s.modifyUtxos(func(utxo *Utxo) (bool, bool) {
+		if utxo.Slot > slot {
+			return false, true
+		}
+		if utxo.SpentSlot > slot {
+			utxo.SpentSlot = 0
+			return true, false
+		}
+		return false, false
+	})
+}
+
+// PruneUtxos removes UTxOs spent before the specified slot
+func (s *Storage) PruneUtxos(slot uint64) error {
+	return s.modifyUtxos(func(utxo *Utxo) (bool, bool) {
+		return false, utxo.SpentSlot > 0 && utxo.SpentSlot < slot
+	})
+}
+
+// modifyUtxos calls the provided function for each UTxO, which returns
+// whether the UTxO should be updated or removed
+func (s *Storage) modifyUtxos(modifyFunc func(*Utxo) (bool, bool)) error {
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(utxosBucket)
+		var updated []Utxo
+		var removed [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var utxo Utxo
+			if err := json.Unmarshal(v, &utxo); err != nil {
+				return err
+			}
+			update, remove := modifyFunc(&utxo)
+			if remove {
+				// Keys can't be modified during iteration
+				removed = append(removed, append([]byte{}, k...))
+			} else if update {
+				updated = append(updated, utxo)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range removed {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		for _, utxo := range updated {
+			if err := putUtxo(bucket, utxo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func putUtxo(bucket *bolt.Bucket, utxo Utxo) error {
+	data, err := json.Marshal(utxo)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(utxoKey(utxo.TxHash, utxo.Index), data)
+}
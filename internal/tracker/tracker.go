@@ -0,0 +1,338 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracker
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+)
+
+// trackedTx is a submitted TX that hasn't reached a final state yet
+type trackedTx struct {
+	tx *storage.Transaction
+	// Number of the first block seen at or past the TTL, or 0 if none
+	expiryBlock uint64
+}
+
+// Tracker follows reward TXs after they're submitted, using the blocks seen
+// by the indexer to decide whether they were confirmed, expired or rolled
+// back
+type Tracker struct {
+	mutex     sync.Mutex
+	txs       map[string]*trackedTx
+	listeners []func(storage.Transaction)
+	// Status changes that listeners haven't been notified of yet
+	changed []storage.Transaction
+}
+
+// Singleton tracker instance
+var globalTracker = &Tracker{
+	txs: make(map[string]*trackedTx),
+}
+
+// Load resumes tracking the TXs that hadn't reached a final state in a
+// previous run
+func (t *Tracker) Load() error {
+	txs, err := storage.GetStorage().ListTransactions()
+	if err != nil {
+		return fmt.Errorf("failed to list transactions: %w", err)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for idx := range txs {
+		tx := &txs[idx]
+		if tx.Resolved() {
+			continue
+		}
+		t.txs[tx.TxId] = &trackedTx{tx: tx}
+	}
+	if len(t.txs) > 0 {
+		slog.Info(
+			fmt.Sprintf("tracking %d unconfirmed transactions", len(t.txs)),
+		)
+	}
+	return nil
+}
+
+// AddListener registers a function to call whenever the status of a tracked
+// TX changes. Listeners are called once the change has been stored, without
+// the tracker locked
+func (t *Tracker) AddListener(listener func(storage.Transaction)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.listeners = append(t.listeners, listener)
+}
+
+// Track starts tracking a submitted TX, or a treasury TX waiting for
+// signatures, paying out the rewards for the specified triggering TX hashes
+func (t *Tracker) Track(
+	txId string,
+	status storage.TxStatus,
+	ttl uint64,
+	rewardTxHashes []string,
+	txBytes []byte,
+) error {
+	tx := &storage.Transaction{
+		TxId:           txId,
+		Status:         status,
+		Ttl:            ttl,
+		RewardTxHashes: rewardTxHashes,
+		TxCbor:         hex.EncodeToString(txBytes),
+		SubmittedAt:    time.Now(),
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err := storage.GetStorage().UpdateTransaction(tx); err != nil {
+		return fmt.Errorf("failed to store transaction: %w", err)
+	}
+	t.txs[txId] = &trackedTx{tx: tx}
+	return nil
+}
+
+// IsTracked returns whether the specified TX is being tracked
+func (t *Tracker) IsTracked(txId string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, ok := t.txs[txId]
+	return ok
+}
+
+// Status returns the specified submitted TX, or nil if it's unknown
+func (t *Tracker) Status(txId string) (*storage.Transaction, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if tracked, ok := t.txs[txId]; ok {
+		ret := *tracked.tx
+		return &ret, nil
+	}
+	return storage.GetStorage().GetTransaction(txId)
+}
+
+// Reject stops tracking a TX that was rejected by the node, since it can't
+// be included in a block
+func (t *Tracker) Reject(txId string) error {
+	return t.update(func() error {
+		tracked, ok := t.txs[txId]
+		if !ok {
+			return nil
+		}
+		delete(t.txs, txId)
+		return t.updateStatus(tracked, storage.TxStatusRejected)
+	})
+}
+
+// HandleTransaction records that a tracked TX was included in a block
+func (t *Tracker) HandleTransaction(
+	txId string,
+	slot uint64,
+	blockNumber uint64,
+	blockHash string,
+) error {
+	return t.update(func() error {
+		tracked, ok := t.txs[txId]
+		if !ok {
+			return nil
+		}
+		tracked.tx.Slot = slot
+		tracked.tx.BlockNumber = blockNumber
+		tracked.tx.BlockHash = blockHash
+		tracked.expiryBlock = 0
+		slog.Info(
+			fmt.Sprintf(
+				"reward TX %s included in block %d (slot %d)",
+				txId,
+				blockNumber,
+				slot,
+			),
+		)
+		return t.updateStatus(tracked, storage.TxStatusIncluded)
+	})
+}
+
+// HandleBlock updates the tracked TXs for a new block, marking them confirmed
+// once they have enough confirmations, or expired once a block at or past
+// their TTL has enough confirmations without them being included
+func (t *Tracker) HandleBlock(slot uint64, blockNumber uint64) error {
+	cfg := config.GetConfig()
+	return t.update(func() error {
+		var errs []error
+		for txId, tracked := range t.txs {
+			var newStatus storage.TxStatus
+			if tracked.tx.Status == storage.TxStatusIncluded {
+				// The indexer replays a few blocks after a restart, so the
+				// block may be older than the one the TX was included in
+				if blockNumber >= tracked.tx.BlockNumber &&
+					blockNumber-tracked.tx.BlockNumber >= cfg.Indexer.ConfirmationDepth {
+					newStatus = storage.TxStatusConfirmed
+				}
+			} else if tracked.tx.Ttl > 0 {
+				if tracked.expiryBlock == 0 && slot >= tracked.tx.Ttl {
+					tracked.expiryBlock = blockNumber
+				}
+				if tracked.expiryBlock > 0 &&
+					blockNumber >= tracked.expiryBlock &&
+					blockNumber-tracked.expiryBlock >= cfg.Indexer.ConfirmationDepth {
+					newStatus = storage.TxStatusExpired
+				}
+			}
+			if newStatus == "" {
+				continue
+			}
+			if err := t.updateStatus(tracked, newStatus); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			delete(t.txs, txId)
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// HandleRollback updates the tracked TXs that were included in rolled back
+// blocks
+func (t *Tracker) HandleRollback(slot uint64) error {
+	return t.update(func() error {
+		var errs []error
+		for txId, tracked := range t.txs {
+			if tracked.expiryBlock > 0 && slot < tracked.tx.Ttl {
+				tracked.expiryBlock = 0
+			}
+			if tracked.tx.Status != storage.TxStatusIncluded ||
+				tracked.tx.Slot <= slot {
+				continue
+			}
+			slog.Warn(
+				fmt.Sprintf(
+					"reward TX %s rolled back from block %d",
+					txId,
+					tracked.tx.BlockNumber,
+				),
+			)
+			tracked.tx.Slot = 0
+			tracked.tx.BlockNumber = 0
+			tracked.tx.BlockHash = ""
+			if err := t.updateStatus(
+				tracked,
+				storage.TxStatusRolledBack,
+			); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// update calls updateFunc with the mutex held, and then notifies listeners of
+// the status changes it made once the mutex is released, so that they can
+// take their time or call back into the tracker
+func (t *Tracker) update(updateFunc func() error) error {
+	t.mutex.Lock()
+	err := updateFunc()
+	changed := t.changed
+	t.changed = nil
+	listeners := slices.Clone(t.listeners)
+	t.mutex.Unlock()
+	for _, tx := range changed {
+		for _, listener := range listeners {
+			listener(tx)
+		}
+	}
+	return err
+}
+
+// updateStatus stores the new status of a tracked TX, updates the rewards it
+// pays out and queues a notification for listeners. The caller must hold the
+// mutex
+func (t *Tracker) updateStatus(
+	tracked *trackedTx,
+	status storage.TxStatus,
+) error {
+	tracked.tx.Status = status
+	if err := storage.GetStorage().UpdateTransaction(tracked.tx); err != nil {
+		return fmt.Errorf("failed to store transaction: %w", err)
+	}
+	switch status {
+	case storage.TxStatusConfirmed:
+		slog.Info(
+			fmt.Sprintf("reward TX %s confirmed", tracked.tx.TxId),
+		)
+		if err := updateRewards(
+			tracked.tx,
+			storage.RewardStatusConfirmed,
+			"",
+		); err != nil {
+			return err
+		}
+	case storage.TxStatusExpired:
+		slog.Warn(
+			fmt.Sprintf(
+				"reward TX %s expired before being included in a block",
+				tracked.tx.TxId,
+			),
+		)
+		if err := updateRewards(
+			tracked.tx,
+			storage.RewardStatusFailed,
+			fmt.Sprintf("reward TX %s expired", tracked.tx.TxId),
+		); err != nil {
+			return err
+		}
+	}
+	t.changed = append(t.changed, *tracked.tx)
+	return nil
+}
+
+// updateRewards updates the status of the rewards paid out by a TX
+func updateRewards(
+	tx *storage.Transaction,
+	status storage.RewardStatus,
+	rewardErr string,
+) error {
+	for _, txHash := range tx.RewardTxHashes {
+		reward, err := storage.GetStorage().GetReward(txHash)
+		if err != nil {
+			return fmt.Errorf("failed to lookup reward ledger: %w", err)
+		}
+		// Skip rewards that have since been paid out by another TX
+		if reward == nil || reward.RewardTxId != tx.TxId {
+			continue
+		}
+		// Only rewards still waiting on this TX can fail because of it
+		if status == storage.RewardStatusFailed &&
+			reward.Status != storage.RewardStatusBuilt &&
+			reward.Status != storage.RewardStatusSubmitted {
+			continue
+		}
+		reward.Status = status
+		reward.Error = rewardErr
+		if err := storage.GetStorage().UpdateReward(reward); err != nil {
+			return fmt.Errorf("failed to update reward ledger: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetTracker returns the global tracker instance
+func GetTracker() *Tracker {
+	return globalTracker
+}
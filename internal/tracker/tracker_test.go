@@ -0,0 +1,382 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracker
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+)
+
+var (
+	testTxId         = fmt.Sprintf("%064x", 1)
+	testRewardTxHash = fmt.Sprintf("%064x", 2)
+)
+
+// setupTracker returns an empty tracker backed by a new database, tracking a
+// submitted TX with the specified TTL that pays out a single reward. It
+// returns the TX statuses that listeners are notified of
+func setupTracker(t *testing.T, ttl uint64) (*Tracker, *[]storage.TxStatus) {
+	t.Helper()
+	cfg := config.GetConfig()
+	origDirectory := cfg.Storage.Directory
+	origDepth := cfg.Indexer.ConfirmationDepth
+	cfg.Storage.Directory = t.TempDir()
+	cfg.Indexer.ConfirmationDepth = 3
+	if err := storage.GetStorage().Load(); err != nil {
+		t.Fatalf("failed to load storage: %s", err)
+	}
+	t.Cleanup(func() {
+		storage.GetStorage().Close()
+		cfg.Storage.Directory = origDirectory
+		cfg.Indexer.ConfirmationDepth = origDepth
+	})
+	if err := storage.GetStorage().UpdateReward(
+		&storage.Reward{
+			TxHash:     testRewardTxHash,
+			Status:     storage.RewardStatusSubmitted,
+			RewardTxId: testTxId,
+		},
+	); err != nil {
+		t.Fatalf("failed to store reward: %s", err)
+	}
+	tr := &Tracker{
+		txs: make(map[string]*trackedTx),
+	}
+	var notified []storage.TxStatus
+	tr.AddListener(func(tx storage.Transaction) {
+		// Listeners can call back into the tracker
+		if tr.IsTracked(tx.TxId) == tx.Resolved() {
+			t.Errorf("TX %s tracked after being resolved", tx.TxId)
+		}
+		notified = append(notified, tx.Status)
+	})
+	if err := tr.Track(
+		testTxId,
+		storage.TxStatusSubmitted,
+		ttl,
+		[]string{testRewardTxHash},
+		[]byte{0x80},
+	); err != nil {
+		t.Fatalf("failed to track TX: %s", err)
+	}
+	return tr, &notified
+}
+
+func TestTracker(t *testing.T) {
+	included := func(tr *Tracker) error {
+		return tr.HandleTransaction(testTxId, 1000, 100, "")
+	}
+	block := func(slot uint64, blockNumber uint64) func(*Tracker) error {
+		return func(tr *Tracker) error {
+			return tr.HandleBlock(slot, blockNumber)
+		}
+	}
+	rollback := func(slot uint64) func(*Tracker) error {
+		return func(tr *Tracker) error {
+			return tr.HandleRollback(slot)
+		}
+	}
+	testDefs := []struct {
+		name  string
+		ttl   uint64
+		steps []func(*Tracker) error
+		// Final status of the TX and the reward it pays out
+		expectedStatus       storage.TxStatus
+		expectedRewardStatus storage.RewardStatus
+		// Statuses that listeners were notified of, in order
+		expectedNotified []storage.TxStatus
+	}{
+		{
+			name:                 "submitted",
+			ttl:                  1050,
+			expectedStatus:       storage.TxStatusSubmitted,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+		},
+		{
+			name: "included",
+			steps: []func(*Tracker) error{
+				included,
+				block(1000, 100),
+				block(1010, 101),
+				block(1020, 102),
+			},
+			expectedStatus:       storage.TxStatusIncluded,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+			expectedNotified:     []storage.TxStatus{storage.TxStatusIncluded},
+		},
+		{
+			name: "confirmed after the confirmation depth",
+			steps: []func(*Tracker) error{
+				included,
+				block(1000, 100),
+				block(1010, 101),
+				block(1020, 102),
+				block(1030, 103),
+			},
+			expectedStatus:       storage.TxStatusConfirmed,
+			expectedRewardStatus: storage.RewardStatusConfirmed,
+			expectedNotified: []storage.TxStatus{
+				storage.TxStatusIncluded,
+				storage.TxStatusConfirmed,
+			},
+		},
+		{
+			name: "replayed older block doesn't confirm",
+			steps: []func(*Tracker) error{
+				included,
+				block(900, 90),
+			},
+			expectedStatus:       storage.TxStatusIncluded,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+			expectedNotified:     []storage.TxStatus{storage.TxStatusIncluded},
+		},
+		{
+			name: "not expired before the TTL",
+			ttl:  1050,
+			steps: []func(*Tracker) error{
+				block(1040, 104),
+				block(1049, 110),
+			},
+			expectedStatus:       storage.TxStatusSubmitted,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+		},
+		{
+			name: "not expired until the TTL block is deep enough",
+			ttl:  1050,
+			steps: []func(*Tracker) error{
+				block(1050, 105),
+				block(1060, 106),
+				block(1070, 107),
+			},
+			expectedStatus:       storage.TxStatusSubmitted,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+		},
+		{
+			name: "expired at the TTL",
+			ttl:  1050,
+			steps: []func(*Tracker) error{
+				block(1040, 104),
+				block(1050, 105),
+				block(1060, 106),
+				block(1070, 107),
+				block(1080, 108),
+			},
+			expectedStatus:       storage.TxStatusExpired,
+			expectedRewardStatus: storage.RewardStatusFailed,
+			expectedNotified:     []storage.TxStatus{storage.TxStatusExpired},
+		},
+		{
+			name: "included before the TTL isn't expired",
+			ttl:  1050,
+			steps: []func(*Tracker) error{
+				included,
+				block(1050, 105),
+				block(1060, 106),
+				block(1070, 107),
+				block(1080, 108),
+			},
+			expectedStatus:       storage.TxStatusConfirmed,
+			expectedRewardStatus: storage.RewardStatusConfirmed,
+			expectedNotified: []storage.TxStatus{
+				storage.TxStatusIncluded,
+				storage.TxStatusConfirmed,
+			},
+		},
+		{
+			name: "rolled back",
+			steps: []func(*Tracker) error{
+				included,
+				block(1000, 100),
+				rollback(990),
+				block(1030, 103),
+			},
+			expectedStatus:       storage.TxStatusRolledBack,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+			expectedNotified: []storage.TxStatus{
+				storage.TxStatusIncluded,
+				storage.TxStatusRolledBack,
+			},
+		},
+		{
+			name: "rollback after the TX block is ignored",
+			steps: []func(*Tracker) error{
+				included,
+				rollback(1000),
+			},
+			expectedStatus:       storage.TxStatusIncluded,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+			expectedNotified:     []storage.TxStatus{storage.TxStatusIncluded},
+		},
+		{
+			name: "included again after a rollback",
+			steps: []func(*Tracker) error{
+				included,
+				rollback(990),
+				func(tr *Tracker) error {
+					return tr.HandleTransaction(testTxId, 1005, 101, "")
+				},
+				block(1005, 101),
+				block(1045, 104),
+			},
+			expectedStatus:       storage.TxStatusConfirmed,
+			expectedRewardStatus: storage.RewardStatusConfirmed,
+			expectedNotified: []storage.TxStatus{
+				storage.TxStatusIncluded,
+				storage.TxStatusRolledBack,
+				storage.TxStatusIncluded,
+				storage.TxStatusConfirmed,
+			},
+		},
+		{
+			// The block at the TTL was rolled back, so the TX could still be
+			// included in the replacement chain
+			name: "rollback of the TTL block resets expiry",
+			ttl:  1050,
+			steps: []func(*Tracker) error{
+				block(1050, 105),
+				rollback(1040),
+				block(1045, 105),
+				block(1046, 106),
+				block(1047, 107),
+				block(1048, 108),
+			},
+			expectedStatus:       storage.TxStatusSubmitted,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+		},
+		{
+			name: "rejected",
+			steps: []func(*Tracker) error{
+				func(tr *Tracker) error {
+					return tr.Reject(testTxId)
+				},
+			},
+			expectedStatus:       storage.TxStatusRejected,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+			expectedNotified:     []storage.TxStatus{storage.TxStatusRejected},
+		},
+		{
+			name: "other TXs are ignored",
+			steps: []func(*Tracker) error{
+				func(tr *Tracker) error {
+					return tr.HandleTransaction(
+						fmt.Sprintf("%064x", 3),
+						1000,
+						100,
+						"",
+					)
+				},
+				func(tr *Tracker) error {
+					return tr.Reject(fmt.Sprintf("%064x", 3))
+				},
+			},
+			expectedStatus:       storage.TxStatusSubmitted,
+			expectedRewardStatus: storage.RewardStatusSubmitted,
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			tr, notified := setupTracker(t, testDef.ttl)
+			for idx, step := range testDef.steps {
+				if err := step(tr); err != nil {
+					t.Fatalf("step %d failed: %s", idx, err)
+				}
+			}
+			tx, err := tr.Status(testTxId)
+			if err != nil {
+				t.Fatalf("failed to get TX status: %s", err)
+			}
+			if tx.Status != testDef.expectedStatus {
+				t.Fatalf(
+					"got TX status %s, expected %s",
+					tx.Status,
+					testDef.expectedStatus,
+				)
+			}
+			// The status is stored along with the tracked copy
+			storedTx, err := storage.GetStorage().GetTransaction(testTxId)
+			if err != nil {
+				t.Fatalf("failed to get stored TX: %s", err)
+			}
+			if storedTx.Status != tx.Status {
+				t.Fatalf("got stored TX status %s", storedTx.Status)
+			}
+			if tr.IsTracked(testTxId) == tx.Resolved() {
+				t.Fatalf("expected TX tracked %t", !tx.Resolved())
+			}
+			reward, err := storage.GetStorage().GetReward(testRewardTxHash)
+			if err != nil {
+				t.Fatalf("failed to get reward: %s", err)
+			}
+			if reward.Status != testDef.expectedRewardStatus {
+				t.Fatalf(
+					"got reward status %s, expected %s",
+					reward.Status,
+					testDef.expectedRewardStatus,
+				)
+			}
+			if !slices.Equal(*notified, testDef.expectedNotified) {
+				t.Fatalf(
+					"listeners notified of %v, expected %v",
+					*notified,
+					testDef.expectedNotified,
+				)
+			}
+		})
+	}
+}
+
+func TestTrackerLoad(t *testing.T) {
+	tr, _ := setupTracker(t, 1050)
+	otherTxId := fmt.Sprintf("%064x", 3)
+	if err := tr.Track(
+		otherTxId,
+		storage.TxStatusSubmitted,
+		0,
+		nil,
+		[]byte{0x80},
+	); err != nil {
+		t.Fatalf("failed to track TX: %s", err)
+	}
+	if err := tr.HandleTransaction(testTxId, 1000, 100, ""); err != nil {
+		t.Fatalf("failed to handle TX: %s", err)
+	}
+	if err := tr.Reject(otherTxId); err != nil {
+		t.Fatalf("failed to reject TX: %s", err)
+	}
+	// Only TXs that haven't reached a final state are tracked after a restart
+	loaded := &Tracker{
+		txs: make(map[string]*trackedTx),
+	}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("failed to load tracker: %s", err)
+	}
+	if !loaded.IsTracked(testTxId) || loaded.IsTracked(otherTxId) {
+		t.Fatalf("expected only the included TX to be tracked")
+	}
+	if err := loaded.HandleBlock(1030, 103); err != nil {
+		t.Fatalf("failed to handle block: %s", err)
+	}
+	tx, err := loaded.Status(testTxId)
+	if err != nil {
+		t.Fatalf("failed to get TX status: %s", err)
+	}
+	if tx.Status != storage.TxStatusConfirmed {
+		t.Fatalf("got TX status %s after a restart", tx.Status)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/tracker"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txsubmit"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+	"github.com/blinklabs-io/bursa"
+)
+
+// batchItem is a reward waiting to be paid out
+type batchItem struct {
+	reward *storage.Reward
+	payout Payout
+}
+
+// Batcher collects pending rewards and pays them out in a single TX once the
+// batch window expires or the batch is full
+type Batcher struct {
+	mutex   sync.Mutex
+	pending []batchItem
+	timer   *time.Timer
+	// Makes sure only one batch is built at a time, so that batches don't
+	// select the same inputs
+	processMutex sync.Mutex
+}
+
+// Singleton batcher instance
+var globalBatcher = &Batcher{}
+
+// Add queues a reward for the next batch
+func (b *Batcher) Add(reward *storage.Reward, payout Payout) {
+	cfg := config.GetConfig()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending = append(
+		b.pending,
+		batchItem{
+			reward: reward,
+			payout: payout,
+		},
+	)
+	if cfg.Batch.MaxCount > 0 && len(b.pending) >= cfg.Batch.MaxCount {
+		b.flush()
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(cfg.Batch.Window, b.Flush)
+	}
+}
+
+// Flush pays out any pending rewards immediately
+func (b *Batcher) Flush() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.flush()
+}
+
+// flush starts processing the pending rewards. The caller must hold the mutex
+func (b *Batcher) flush() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	items := b.pending
+	b.pending = nil
+	go func() {
+		if err := b.process(items); err != nil {
+			slog.Error(
+				fmt.Sprintf("failed to pay out reward batch: %s", err),
+			)
+		}
+	}()
+}
+
+// process pays out the rewards, waiting for any other batch to finish first
+func (b *Batcher) process(items []batchItem) error {
+	b.processMutex.Lock()
+	defer b.processMutex.Unlock()
+	return processBatch(items)
+}
+
+// GetBatcher returns the global batcher instance
+func GetBatcher() *Batcher {
+	return globalBatcher
+}
+
+// ResumePendingRewards pays out rewards that were recorded in the ledger but
+// not yet built, such as those still waiting in a batch at shutdown
+func ResumePendingRewards() error {
+	rewards, err := storage.GetStorage().ListRewards()
+	if err != nil {
+		return fmt.Errorf("failed to list rewards: %w", err)
+	}
+	var items []batchItem
+	for idx := range rewards {
+		reward := &rewards[idx]
+		// Rewards waiting for a retry are handled by the retrier
+		if reward.Status != storage.RewardStatusPending ||
+			!reward.NextAttempt.IsZero() {
+			continue
+		}
+		items = append(
+			items,
+			batchItem{
+				reward: reward,
+				payout: rewardPayout(reward),
+			},
+		)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	slog.Info(
+		fmt.Sprintf("resuming %d pending rewards", len(items)),
+	)
+	for _, item := range items {
+		GetBatcher().Add(item.reward, item.payout)
+	}
+	GetBatcher().Flush()
+	return nil
+}
+
+// rewardPayout returns the payout for a reward recorded in the ledger
+func rewardPayout(reward *storage.Reward) Payout {
+	return Payout{
+		Address:  reward.Destination,
+		Lovelace: reward.RewardAmount,
+		Assets:   reward.RewardAssets,
+		Mint:     reward.MintedAssets,
+	}
+}
+
+// processBatch builds and submits one or more TXs paying out the rewards
+func processBatch(items []batchItem) error {
+	w := wallet.GetWallet()
+	if w == nil {
+		return retryRewards(items, errors.New("cannot initialize wallet"))
+	}
+	s := signer.GetSigner()
+	if s == nil {
+		return retryRewards(items, errors.New("no signer configured"))
+	}
+	sourceAddr, err := rewardSourceAddress(w)
+	if err != nil {
+		return retryRewards(items, err)
+	}
+	utxos, err := getWalletUtxos(sourceAddr)
+	if err != nil {
+		return retryRewards(items, err)
+	}
+	return submitBatch(w, s, sourceAddr, &utxos, items)
+}
+
+// submitBatch builds and submits a TX paying out the rewards, splitting them
+// across multiple TXs if they don't fit in one. The provided UTxOs are updated
+// to account for each TX submitted
+func submitBatch(
+	w *bursa.Wallet,
+	s signer.Signer,
+	sourceAddr string,
+	utxos *[]UTxO.UTxO,
+	items []batchItem,
+) error {
+	payouts := make([]Payout, 0, len(items))
+	for _, item := range items {
+		payouts = append(payouts, item.payout)
+	}
+	tx, err := BuildRewardTxFromUtxos(w, s, *utxos, payouts...)
+	if errors.Is(err, ErrTxTooLarge) && len(items) > 1 {
+		half := len(items) / 2
+		slog.Debug(
+			fmt.Sprintf(
+				"reward batch of %d too large for a single TX, splitting",
+				len(items),
+			),
+		)
+		return errors.Join(
+			submitBatch(w, s, sourceAddr, utxos, items[:half]),
+			submitBatch(w, s, sourceAddr, utxos, items[half:]),
+		)
+	}
+	if err != nil {
+		return retryRewards(items, err)
+	}
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		return retryRewards(items, err)
+	}
+	txId := hex.EncodeToString(tx.Id().Payload)
+	rewardTxHashes := make([]string, 0, len(items))
+	for _, item := range items {
+		item.reward.Status = storage.RewardStatusBuilt
+		item.reward.RewardTxId = txId
+		item.reward.Ttl = uint64(tx.TransactionBody.Ttl) // #nosec G115
+		item.reward.NextAttempt = time.Time{}
+		if err := storage.GetStorage().UpdateReward(item.reward); err != nil {
+			return fmt.Errorf("failed to update reward ledger: %w", err)
+		}
+		rewardTxHashes = append(rewardTxHashes, item.reward.TxHash)
+	}
+	// Treasury TXs that need more signatures are exported for the cosigners
+	// instead of being submitted
+	pending, err := needsSignatures(txBytes)
+	if err != nil {
+		return retryRewards(items, err)
+	}
+	txStatus := storage.TxStatusSubmitted
+	if pending {
+		txStatus = storage.TxStatusPendingSignatures
+	}
+	// Watch for the TX on chain, so that the rewards are marked confirmed.
+	// We start before submitting, since a TX that fails to submit with a
+	// transient error may still make it to the node
+	if err := tracker.GetTracker().Track(
+		txId,
+		txStatus,
+		uint64(tx.TransactionBody.Ttl), // #nosec G115
+		rewardTxHashes,
+		txBytes,
+	); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to track transaction %s: %s", txId, err),
+		)
+	}
+	if pending {
+		treasury, err := GetTreasury()
+		if err != nil {
+			return err
+		}
+		if err := exportPendingTx(treasury, txId, txBytes); err != nil {
+			rejectTx(txId)
+			return retryRewards(items, err)
+		}
+		// The inputs stay reserved until the TX is confirmed or expires
+		GetReservations().Reserve(tx, sourceAddr)
+		*utxos = GetReservations().Apply(*utxos)
+		return nil
+	}
+	if err := txsubmit.SubmitTx(txBytes); err != nil {
+		if !isTransientError(err) {
+			rejectTx(txId)
+			return retryRewards(items, err)
+		}
+		// Keep the inputs reserved, since the TX may have made it to the node
+		GetReservations().Reserve(tx, sourceAddr)
+		*utxos = GetReservations().Apply(*utxos)
+		rewards := make([]*storage.Reward, 0, len(items))
+		for _, item := range items {
+			rewards = append(rewards, item.reward)
+		}
+		if retryErr := GetRetrier().retrySubmit(txId, rewards, err); retryErr != nil {
+			slog.Error(
+				fmt.Sprintf("failed to update reward ledger: %s", retryErr),
+			)
+		}
+		return err
+	}
+	GetReservations().Reserve(tx, sourceAddr)
+	*utxos = GetReservations().Apply(*utxos)
+	for _, item := range items {
+		item.reward.Status = storage.RewardStatusSubmitted
+		item.reward.Error = ""
+		if err := storage.GetStorage().UpdateReward(item.reward); err != nil {
+			return fmt.Errorf("failed to update reward ledger: %w", err)
+		}
+	}
+	slog.Info(
+		fmt.Sprintf(
+			"submitted transaction %s paying out %d rewards",
+			txId,
+			len(items),
+		),
+	)
+	return nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+)
+
+// testRewards records pending rewards paying 2 ADA each to distinct addresses
+func testRewards(t *testing.T, count int) []batchItem {
+	t.Helper()
+	ret := make([]batchItem, 0, count)
+	for idx := range count {
+		reward := &storage.Reward{
+			TxHash:       fmt.Sprintf("%064x", idx+1),
+			Destination:  testAddress(t, byte(idx)+1),
+			RewardAmount: 2_000_000,
+		}
+		claimed, err := storage.GetStorage().ClaimReward(reward)
+		if err != nil || !claimed {
+			t.Fatalf("failed to record reward: %v", err)
+		}
+		ret = append(
+			ret,
+			batchItem{
+				reward: reward,
+				payout: rewardPayout(reward),
+			},
+		)
+	}
+	return ret
+}
+
+// waitSubmitted waits until the specified number of TXs has been submitted
+func waitSubmitted(t *testing.T, api *testSubmitApi, count int) [][]byte {
+	t.Helper()
+	for range 500 {
+		if submitted := api.submitted(); len(submitted) >= count {
+			return submitted
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d submitted TXs", count)
+	return nil
+}
+
+// checkPaidOnce makes sure that each reward is paid exactly once across the
+// submitted TXs, by the TX recorded in the reward ledger
+func checkPaidOnce(t *testing.T, items []batchItem, submitted [][]byte) {
+	t.Helper()
+	// Destination address => IDs of the TXs paying it
+	paidBy := make(map[string][]string)
+	spent := make(map[string]bool)
+	for _, txBytes := range submitted {
+		tx := decodeTx(t, txBytes)
+		for _, input := range tx.Inputs() {
+			if spent[input.String()] {
+				t.Fatalf("input %s spent by more than one TX", input)
+			}
+			spent[input.String()] = true
+		}
+		for _, output := range tx.Outputs() {
+			addr := output.Address().String()
+			paidBy[addr] = append(paidBy[addr], tx.Hash().String())
+		}
+	}
+	for _, item := range items {
+		reward, err := storage.GetStorage().GetReward(item.reward.TxHash)
+		if err != nil {
+			t.Fatalf("failed to look up reward: %s", err)
+		}
+		txIds := paidBy[reward.Destination]
+		if len(txIds) != 1 {
+			t.Fatalf(
+				"reward %s paid by %d TXs",
+				reward.TxHash,
+				len(txIds),
+			)
+		}
+		if reward.Status != storage.RewardStatusSubmitted ||
+			reward.RewardTxId != txIds[0] {
+			t.Fatalf(
+				"expected reward %s submitted in TX %s, got: %+v",
+				reward.TxHash,
+				txIds[0],
+				reward,
+			)
+		}
+	}
+}
+
+func TestBatcherTriggers(t *testing.T) {
+	testDefs := []struct {
+		name     string
+		window   time.Duration
+		maxCount int
+		rewards  int
+		// Whether the rewards are paid before the window expires
+		early bool
+	}{
+		{
+			name:    "window expires",
+			window:  500 * time.Millisecond,
+			rewards: 2,
+		},
+		{
+			name:     "window expires before the batch is full",
+			window:   500 * time.Millisecond,
+			maxCount: 5,
+			rewards:  4,
+		},
+		{
+			name:     "batch full",
+			window:   time.Hour,
+			maxCount: 3,
+			rewards:  3,
+			early:    true,
+		},
+	}
+	cfg := config.GetConfig()
+	origBatch := cfg.Batch
+	t.Cleanup(func() { cfg.Batch = origBatch })
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			w, _, mem := setupTest(t)
+			api := startSubmitApi(t)
+			mem.AddUtxos(testUtxo(t, w.PaymentAddress, 0x10, 100_000_000))
+			cfg.Batch.Window = testDef.window
+			cfg.Batch.MaxCount = testDef.maxCount
+			b := &Batcher{}
+			t.Cleanup(b.Flush)
+			items := testRewards(t, testDef.rewards)
+			for idx, item := range items {
+				b.Add(item.reward, item.payout)
+				if idx == len(items)-1 {
+					break
+				}
+				// Nothing is paid before the batch is complete
+				time.Sleep(20 * time.Millisecond)
+				if len(api.submitted()) > 0 {
+					t.Fatalf("batch paid after %d rewards", idx+1)
+				}
+			}
+			if !testDef.early {
+				time.Sleep(testDef.window / 2)
+				if len(api.submitted()) > 0 {
+					t.Fatalf("batch paid before the window expired")
+				}
+			}
+			submitted := waitSubmitted(t, api, 1)
+			// Wait for the batch to finish updating the reward ledger
+			b.processMutex.Lock()
+			b.processMutex.Unlock()
+			if len(submitted) != 1 {
+				t.Fatalf("expected a single TX, got %d", len(submitted))
+			}
+			checkPaidOnce(t, items, submitted)
+			// Only the rewards and change are paid
+			outputs := decodeTx(t, submitted[0]).Outputs()
+			if len(outputs) != testDef.rewards+1 {
+				t.Fatalf(
+					"expected %d rewards and change, got %d outputs",
+					testDef.rewards,
+					len(outputs),
+				)
+			}
+		})
+	}
+}
+
+func TestSubmitBatchSplit(t *testing.T) {
+	w, s, mem := setupTest(t)
+	api := startSubmitApi(t)
+	// Lower the maximum TX size, so that the batch doesn't fit in one TX
+	pp, err := mem.ProtocolParams()
+	if err != nil {
+		t.Fatalf("failed to get protocol parameters: %s", err)
+	}
+	pp.MaxTxSize = 2048
+	mem.SetProtocolParams(pp)
+	utxos := []UTxO.UTxO{testUtxo(t, w.PaymentAddress, 0x10, 1_000_000_000)}
+	items := testRewards(t, 50)
+	if err := submitBatch(w, s, w.PaymentAddress, &utxos, items); err != nil {
+		t.Fatalf("failed to submit batch: %s", err)
+	}
+	submitted := api.submitted()
+	if len(submitted) < 2 {
+		t.Fatalf("expected the batch to be split, got %d TXs", len(submitted))
+	}
+	checkPaidOnce(t, items, submitted)
+	// Each TX spends the change from the one before
+	if len(utxos) != 1 {
+		t.Fatalf(
+			"expected only the last change output left, got %d",
+			len(utxos),
+		)
+	}
+	lastTx := decodeTx(t, submitted[len(submitted)-1])
+	changeTxId := hex.EncodeToString(utxos[0].Input.TransactionId)
+	if changeTxId != lastTx.Hash().String() {
+		t.Fatalf("expected the change from the last TX to be left")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/Salvionied/apollo/serialization/NativeScript"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// MintPolicy is a native script minting policy controlled by the signer
+// payment key
+type MintPolicy struct {
+	Script NativeScript.NativeScript
+	// Slot after which minting is no longer possible, or 0 if not time locked
+	LockSlot uint64
+}
+
+// NewMintPolicy returns the minting policy for a payment key. The policy
+// requires a signature from the key with the provided hash and, if lockSlot is
+// non-zero, that the TX is submitted before the specified slot
+func NewMintPolicy(keyHash []byte, lockSlot uint64) (*MintPolicy, error) {
+	if len(keyHash) != lcommon.AddressHashSize {
+		return nil, errors.New("invalid payment key hash")
+	}
+	script := NativeScript.NewScriptPubKey(keyHash)
+	if lockSlot > 0 {
+		script = NativeScript.NewScriptAll(
+			[]NativeScript.NativeScript{
+				script,
+				NativeScript.NewInvalidHereafter(
+					int64(lockSlot), // #nosec G115
+				),
+			},
+		)
+	}
+	return &MintPolicy{
+		Script:   script,
+		LockSlot: lockSlot,
+	}, nil
+}
+
+// GetMintPolicy returns the minting policy for the current signer and config
+func GetMintPolicy() (*MintPolicy, error) {
+	cfg := config.GetConfig()
+	s := signer.GetSigner()
+	if s == nil {
+		return nil, errors.New("no signer configured")
+	}
+	return NewMintPolicy(signer.KeyHash(s), cfg.Mint.LockSlot)
+}
+
+// PolicyId returns the hex-encoded policy ID
+func (p *MintPolicy) PolicyId() (string, error) {
+	hash, err := p.Script.Hash()
+	if err != nil {
+		return "", fmt.Errorf("failed to hash minting policy: %w", err)
+	}
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Cbor returns the CBOR encoding of the policy script
+func (p *MintPolicy) Cbor() ([]byte, error) {
+	return p.Script.MarshalCBOR()
+}
@@ -0,0 +1,234 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/bursa"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// testKeySigner returns a signer for a fixed cardano-cli style payment key,
+// which doesn't belong to the wallet
+func testKeySigner(t *testing.T) signer.Signer {
+	t.Helper()
+	keyCbor, err := cbor.Encode(bytes.Repeat([]byte{0x42}, ed25519.SeedSize))
+	if err != nil {
+		t.Fatalf("failed to encode key: %s", err)
+	}
+	s, err := signer.NewKeySigner(
+		bursa.KeyFile{
+			Type:    "PaymentSigningKeyShelley_ed25519",
+			CborHex: hex.EncodeToString(keyCbor),
+		},
+	)
+	if err != nil {
+		t.Fatalf("failed to create signer: %s", err)
+	}
+	return s
+}
+
+func TestBuildRewardTxMint(t *testing.T) {
+	w, _, mem := setupTest(t)
+	s := testKeySigner(t)
+	cfg := config.GetConfig()
+	// The TTL is capped at the policy lock slot
+	lockSlot := currentSlot() + 100
+	cfg.Mint.LockSlot = lockSlot
+	t.Cleanup(func() { cfg.Mint.LockSlot = 0 })
+	destAddr := testAddress(t, 0x01)
+	utxos := []UTxO.UTxO{testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)}
+	mem.AddUtxos(utxos...)
+	assetName := hex.EncodeToString([]byte("reward"))
+	tx, err := BuildRewardTxFromUtxos(
+		w,
+		s,
+		utxos,
+		Payout{
+			Address:  destAddr,
+			Lovelace: 2_000_000,
+			Mint:     map[string]uint64{assetName: 100},
+		},
+	)
+	if err != nil {
+		t.Fatalf("failed to build reward TX: %s", err)
+	}
+	checkSignature(t, tx, s)
+	mintPolicy, err := NewMintPolicy(signer.KeyHash(s), lockSlot)
+	if err != nil {
+		t.Fatalf("failed to create mint policy: %s", err)
+	}
+	policyId, err := mintPolicy.PolicyId()
+	if err != nil {
+		t.Fatalf("failed to get policy ID: %s", err)
+	}
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		t.Fatalf("failed to encode TX: %s", err)
+	}
+	decodedTx := decodeTx(t, txBytes)
+	if decodedTx.TTL() != lockSlot {
+		t.Fatalf("got TTL %d, expected lock slot %d", decodedTx.TTL(), lockSlot)
+	}
+	// The policy script is attached for the ledger to check the mint
+	scripts := decodedTx.Witnesses().NativeScripts()
+	if len(scripts) != 1 || scripts[0].Hash().String() != policyId {
+		t.Fatalf("expected the mint policy script in the witness set")
+	}
+	policyHash := lcommon.NewBlake2b224(mustDecodeHex(t, policyId))
+	mint := decodedTx.AssetMint()
+	if mint == nil ||
+		mint.Asset(policyHash, []byte("reward")) != 100 {
+		t.Fatalf("expected 100 reward tokens to be minted")
+	}
+	var sent uint64
+	for _, output := range decodedTx.Outputs() {
+		if output.Address().String() != destAddr || output.Assets() == nil {
+			continue
+		}
+		sent += output.Assets().Asset(policyHash, []byte("reward"))
+	}
+	if sent != 100 {
+		t.Fatalf("expected the minted tokens to be sent, got %d", sent)
+	}
+}
+
+func TestBuildRewardTxFeePadding(t *testing.T) {
+	w, _, _ := setupTest(t)
+	s := testKeySigner(t)
+	cfg := config.GetConfig()
+	// 5-of-5 treasury, so four signatures are added after the TX is built
+	cfg.Treasury.Keys = []string{hex.EncodeToString(signer.KeyHash(s))}
+	for idx := 2; idx <= 5; idx++ {
+		cfg.Treasury.Keys = append(
+			cfg.Treasury.Keys,
+			strings.Repeat(fmt.Sprintf("%02x", idx), 28),
+		)
+	}
+	cfg.Treasury.Required = 5
+	t.Cleanup(func() {
+		cfg.Treasury.Keys = nil
+		cfg.Treasury.Required = 0
+	})
+	treasury, err := GetTreasury()
+	if err != nil {
+		t.Fatalf("failed to get treasury: %s", err)
+	}
+	utxos := []UTxO.UTxO{testUtxo(t, treasury.Address, 0x10, 100_000_000)}
+	tx, err := BuildRewardTxFromUtxos(
+		w,
+		s,
+		utxos,
+		Payout{
+			Address:  testAddress(t, 0x01),
+			Lovelace: 2_000_000,
+			Mint: map[string]uint64{
+				hex.EncodeToString([]byte("reward")): 1,
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("failed to build reward TX: %s", err)
+	}
+	checkSignature(t, tx, s)
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		t.Fatalf("failed to encode TX: %s", err)
+	}
+	// Both scripts are attached
+	scripts := decodeTx(t, txBytes).Witnesses().NativeScripts()
+	if len(scripts) != 2 {
+		t.Fatalf(
+			"expected the treasury and mint policy scripts, got %d",
+			len(scripts),
+		)
+	}
+	// apollo doesn't count the scripts or the cosigner signatures when
+	// estimating the fee, so the fee must have been padded to cover the TX
+	// once it's fully signed
+	pp, err := GetProtocolParams().Get()
+	if err != nil {
+		t.Fatalf("failed to get protocol parameters: %s", err)
+	}
+	signedSize := len(txBytes) + 4*vkeyWitnessSize
+	minFee := int64(pp.MinFeeConstant) +
+		int64(signedSize)*pp.MinFeeCoefficient
+	if tx.TransactionBody.Fee < minFee {
+		t.Fatalf(
+			"fee %d is below the minimum of %d for the fully signed TX",
+			tx.TransactionBody.Fee,
+			minFee,
+		)
+	}
+}
+
+func TestBuildRewardTxTooLarge(t *testing.T) {
+	w, s, mem := setupTest(t)
+	// Lower the maximum TX size, so that a few outputs are enough to go over
+	pp, err := mem.ProtocolParams()
+	if err != nil {
+		t.Fatalf("failed to get protocol parameters: %s", err)
+	}
+	pp.MaxTxSize = 2048
+	mem.SetProtocolParams(pp)
+	utxos := []UTxO.UTxO{testUtxo(t, w.PaymentAddress, 0x10, 1_000_000_000)}
+	payouts := make([]Payout, 0, 50)
+	for idx := range 50 {
+		payouts = append(
+			payouts,
+			Payout{
+				Address:  testAddress(t, byte(idx)+1),
+				Lovelace: 2_000_000,
+			},
+		)
+	}
+	if _, err := BuildRewardTxFromUtxos(w, s, utxos, payouts...); !errors.Is(
+		err,
+		ErrTxTooLarge,
+	) {
+		t.Fatalf("expected TX too large error, got: %v", err)
+	}
+	// A smaller batch fits
+	tx, err := BuildRewardTxFromUtxos(w, s, utxos, payouts[:10]...)
+	if err != nil {
+		t.Fatalf("failed to build reward TX: %s", err)
+	}
+	if len(tx.TransactionBody.Outputs) != 11 {
+		t.Fatalf(
+			"expected 10 payouts and change, got %d outputs",
+			len(tx.TransactionBody.Outputs),
+		)
+	}
+}
+
+func mustDecodeHex(t *testing.T, value string) []byte {
+	t.Helper()
+	ret, err := hex.DecodeString(value)
+	if err != nil {
+		t.Fatalf("failed to decode hex: %s", err)
+	}
+	return ret
+}
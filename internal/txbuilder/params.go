@@ -0,0 +1,159 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/Salvionied/apollo/txBuilding/Backend/Base"
+	"github.com/Salvionied/apollo/txBuilding/Backend/FixedChainContext"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/backend"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+)
+
+// ProtocolParams caches the current protocol parameters, which can only
+// change on an epoch boundary
+type ProtocolParams struct {
+	mutex  sync.Mutex
+	params *Base.ProtocolParameters
+	epoch  uint64
+}
+
+var globalProtocolParams = &ProtocolParams{}
+
+// GetProtocolParams returns the global protocol parameters cache
+func GetProtocolParams() *ProtocolParams {
+	return globalProtocolParams
+}
+
+// Get returns the cached protocol parameters, fetching them if necessary
+func (p *ProtocolParams) Get() (Base.ProtocolParameters, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.params != nil {
+		return *p.params, nil
+	}
+	params, err := fetchProtocolParams()
+	if err != nil {
+		return Base.ProtocolParameters{}, fmt.Errorf(
+			"protocol parameters unavailable: %w",
+			err,
+		)
+	}
+	p.params = &params
+	return params, nil
+}
+
+// SetEpoch records the epoch of the latest block seen by the indexer. The
+// cached protocol parameters are dropped when a new epoch starts, and they're
+// fetched again the next time they're needed
+func (p *ProtocolParams) SetEpoch(epoch uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if epoch <= p.epoch {
+		return
+	}
+	if p.epoch > 0 && p.params != nil {
+		slog.Info(
+			fmt.Sprintf(
+				"epoch %d started, refreshing protocol parameters",
+				epoch,
+			),
+		)
+		p.params = nil
+	}
+	p.epoch = epoch
+}
+
+// fetchProtocolParams gets the current protocol parameters from the chain
+// backend, or from the local node if the backend doesn't provide them
+func fetchProtocolParams() (Base.ProtocolParameters, error) {
+	cfg := config.GetConfig()
+	b := backend.GetBackend()
+	if b == nil {
+		return Base.ProtocolParameters{}, errors.New(
+			"no chain backend configured",
+		)
+	}
+	params, err := b.ProtocolParams()
+	if errors.Is(err, backend.ErrNotSupported) {
+		socketPath := cfg.Indexer.SocketPath
+		if socketPath == "" {
+			socketPath = cfg.Submit.SocketPath
+		}
+		if socketPath == "" {
+			return Base.ProtocolParameters{}, errors.New(
+				"chain backend doesn't provide protocol parameters and no node socket path is configured",
+			)
+		}
+		params, err = backend.NodeProtocolParams(socketPath, cfg.Network)
+	}
+	if err != nil {
+		return Base.ProtocolParameters{}, err
+	}
+	// Make sure we didn't get empty values, which would produce TXs with a
+	// fee that's too low
+	if params.MinFeeCoefficient == 0 || params.MinFeeConstant == 0 ||
+		params.MaxTxSize == 0 {
+		return Base.ProtocolParameters{}, errors.New(
+			"protocol parameters are missing fee or size values",
+		)
+	}
+	return params, nil
+}
+
+// chainContext is an apollo chain context using the cached protocol
+// parameters. The rest of the chain context isn't used, since UTxOs are
+// provided to apollo directly and we submit TXs ourselves
+type chainContext struct {
+	FixedChainContext.FixedChainContext
+}
+
+// getChainContext returns the chain context used when building transactions
+func getChainContext() (Base.ChainContext, error) {
+	cfg := config.GetConfig()
+	params, err := GetProtocolParams().Get()
+	if err != nil {
+		return nil, err
+	}
+	cc := &chainContext{
+		FixedChainContext: FixedChainContext.InitFixedChainContext(),
+	}
+	cc.ProtocolParams = params
+	if network, ok := ouroboros.NetworkByName(cfg.Network); ok {
+		cc.GenesisParams.NetworkMagic = int(network.NetworkMagic)
+	}
+	return cc, nil
+}
+
+// MaxTxFee returns the fee for a TX of the maximum size and execution units.
+// apollo uses it as the initial fee when estimating the actual fee, so it
+// needs to have the same encoded size as a real fee
+func (c *chainContext) MaxTxFee() (int, error) {
+	maxTxExSteps, err := strconv.Atoi(c.ProtocolParams.MaxTxExSteps)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max TX execution steps: %w", err)
+	}
+	maxTxExMem, err := strconv.Atoi(c.ProtocolParams.MaxTxExMem)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max TX execution memory: %w", err)
+	}
+	return Base.Fee(c, c.ProtocolParams.MaxTxSize, maxTxExSteps, maxTxExMem)
+}
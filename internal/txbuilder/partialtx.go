@@ -0,0 +1,304 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+const (
+	// Witness set map key for vkey witnesses
+	witnessKeyVkey = 0
+
+	// Text envelope types used by cardano-cli
+	txEnvelopeType        = "Tx ConwayEra"
+	txEnvelopeDescription = "Ledger Cddl Format"
+	txEnvelopePrefix      = "Tx "
+	witnessEnvelopePrefix = "TxWitness "
+
+	// cardano-cli key witness type for a Shelley vkey witness
+	keyWitnessTypeShelley = 0
+)
+
+// rawTx is a TX decoded just far enough to change the witnesses. The body is
+// kept as-is, since encoding it again could change the TX ID and invalidate
+// existing signatures
+type rawTx struct {
+	cbor.StructAsArray
+	Body      cbor.RawMessage
+	Witnesses map[uint64]cbor.RawMessage
+	Valid     cbor.RawMessage
+	AuxData   cbor.RawMessage
+}
+
+// PartialTx is a treasury TX collecting signatures from its cosigners
+type PartialTx struct {
+	raw       rawTx
+	bodyHash  []byte
+	witnesses []lcommon.VkeyWitness
+	useSetTag bool
+}
+
+// textEnvelope is the JSON file format used by cardano-cli for TXs, witnesses
+// and keys
+type textEnvelope struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	CborHex     string `json:"cborHex"`
+}
+
+// DecodePartialTx decodes a signed or partially signed TX
+func DecodePartialTx(txBytes []byte) (*PartialTx, error) {
+	ret := &PartialTx{}
+	if _, err := cbor.Decode(txBytes, &ret.raw); err != nil {
+		return nil, fmt.Errorf("failed to decode TX: %w", err)
+	}
+	if ret.raw.Witnesses == nil {
+		ret.raw.Witnesses = make(map[uint64]cbor.RawMessage)
+	}
+	if vkeyCbor, ok := ret.raw.Witnesses[witnessKeyVkey]; ok {
+		var vkeyWitnesses cbor.SetType[lcommon.VkeyWitness]
+		if _, err := cbor.Decode(vkeyCbor, &vkeyWitnesses); err != nil {
+			return nil, fmt.Errorf("failed to decode TX witnesses: %w", err)
+		}
+		ret.witnesses = vkeyWitnesses.Items()
+		// Keep the set tag if the TX already used it
+		ret.useSetTag = len(vkeyCbor) > 0 && vkeyCbor[0] == 0xd9
+	}
+	bodyHash := lcommon.Blake2b256Hash(ret.raw.Body)
+	ret.bodyHash = bodyHash.Bytes()
+	return ret, nil
+}
+
+// Id returns the hex-encoded TX ID
+func (p *PartialTx) Id() string {
+	return hex.EncodeToString(p.bodyHash)
+}
+
+// SignerKeyHashes returns the payment key hashes of the keys that signed the
+// TX
+func (p *PartialTx) SignerKeyHashes() [][]byte {
+	ret := make([][]byte, 0, len(p.witnesses))
+	for _, witness := range p.witnesses {
+		keyHash := lcommon.Blake2b224Hash(witness.Vkey)
+		ret = append(ret, keyHash.Bytes())
+	}
+	return ret
+}
+
+// AddWitness adds a signature to the TX after checking that it's valid. It
+// returns false if the TX was already signed by the key
+func (p *PartialTx) AddWitness(vkey []byte, signature []byte) (bool, error) {
+	if len(vkey) != ed25519.PublicKeySize {
+		return false, errors.New("invalid verification key size")
+	}
+	if !ed25519.Verify(vkey, p.bodyHash, signature) {
+		keyHash := lcommon.Blake2b224Hash(vkey)
+		return false, fmt.Errorf(
+			"invalid signature from key %s",
+			keyHash.String(),
+		)
+	}
+	for _, witness := range p.witnesses {
+		if bytes.Equal(witness.Vkey, vkey) {
+			return false, nil
+		}
+	}
+	p.witnesses = append(
+		p.witnesses,
+		lcommon.VkeyWitness{
+			Vkey:      vkey,
+			Signature: signature,
+		},
+	)
+	return true, nil
+}
+
+// AddCosignerWitness adds a signature to the TX like AddWitness, refusing
+// signatures from keys that aren't treasury cosigners
+func (p *PartialTx) AddCosignerWitness(
+	treasury *Treasury,
+	vkey []byte,
+	signature []byte,
+) (bool, error) {
+	keyHash := lcommon.Blake2b224Hash(vkey)
+	if !treasury.IsCosigner(keyHash.Bytes()) {
+		return false, fmt.Errorf(
+			"key %s is not a treasury cosigner",
+			keyHash.String(),
+		)
+	}
+	return p.AddWitness(vkey, signature)
+}
+
+// Merge adds the treasury cosigner signatures from another copy of the same
+// TX, and returns the number of signatures added
+func (p *PartialTx) Merge(other *PartialTx, treasury *Treasury) (int, error) {
+	if !bytes.Equal(p.bodyHash, other.bodyHash) {
+		return 0, fmt.Errorf(
+			"TX %s does not match TX %s",
+			other.Id(),
+			p.Id(),
+		)
+	}
+	var ret int
+	for _, witness := range other.witnesses {
+		added, err := p.AddCosignerWitness(
+			treasury,
+			witness.Vkey,
+			witness.Signature,
+		)
+		if err != nil {
+			return ret, err
+		}
+		if added {
+			ret++
+		}
+	}
+	return ret, nil
+}
+
+// Sign adds a signature from the signer payment key
+func (p *PartialTx) Sign(s signer.Signer) (bool, error) {
+	signature, err := s.Sign(p.raw.Body)
+	if err != nil {
+		return false, err
+	}
+	return p.AddWitness(s.PublicKey(), signature)
+}
+
+// Bytes returns the CBOR encoding of the TX with the collected signatures
+func (p *PartialTx) Bytes() ([]byte, error) {
+	if len(p.witnesses) > 0 {
+		vkeyCbor, err := cbor.Encode(
+			cbor.NewSetType(p.witnesses, p.useSetTag),
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.raw.Witnesses[witnessKeyVkey] = vkeyCbor
+	}
+	return cbor.Encode(&p.raw)
+}
+
+// ReadTxFile reads a TX from a cardano-cli text envelope, or from a file with
+// the raw or hex-encoded CBOR
+func ReadTxFile(path string) ([]byte, error) {
+	envelopeType, txBytes, err := readCborFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if envelopeType != "" && !strings.HasPrefix(envelopeType, txEnvelopePrefix) {
+		return nil, fmt.Errorf(
+			"%s is not a transaction file: %s",
+			path,
+			envelopeType,
+		)
+	}
+	return txBytes, nil
+}
+
+// WriteTxFile writes a TX to a file, as raw CBOR if the file name ends in
+// .cbor, and as a cardano-cli text envelope otherwise
+func WriteTxFile(path string, txBytes []byte) error {
+	data := txBytes
+	if filepath.Ext(path) != ".cbor" {
+		var err error
+		data, err = json.MarshalIndent(
+			textEnvelope{
+				Type:        txEnvelopeType,
+				Description: txEnvelopeDescription,
+				CborHex:     hex.EncodeToString(txBytes),
+			},
+			"",
+			"    ",
+		)
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+	return os.WriteFile(path, data, 0o644) // #nosec G306
+}
+
+// ReadSignatures reads the signatures from a TX file, or from a witness file
+// created with "cardano-cli transaction witness". The TX ID is only known for
+// TX files
+func ReadSignatures(path string) (string, []lcommon.VkeyWitness, error) {
+	envelopeType, data, err := readCborFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !strings.HasPrefix(envelopeType, witnessEnvelopePrefix) {
+		tx, err := DecodePartialTx(data)
+		if err != nil {
+			return "", nil, err
+		}
+		return tx.Id(), tx.witnesses, nil
+	}
+	var keyWitness struct {
+		cbor.StructAsArray
+		Type    uint64
+		Witness cbor.RawMessage
+	}
+	if _, err := cbor.Decode(data, &keyWitness); err != nil {
+		return "", nil, fmt.Errorf("failed to decode witness: %w", err)
+	}
+	if keyWitness.Type != keyWitnessTypeShelley {
+		return "", nil, errors.New("only payment key witnesses are supported")
+	}
+	var witness lcommon.VkeyWitness
+	if _, err := cbor.Decode(keyWitness.Witness, &witness); err != nil {
+		return "", nil, fmt.Errorf("failed to decode witness: %w", err)
+	}
+	return "", []lcommon.VkeyWitness{witness}, nil
+}
+
+// readCborFile reads CBOR from a cardano-cli text envelope, or from a file
+// with the raw or hex-encoded CBOR. The envelope type is empty for the latter
+func readCborFile(path string) (string, []byte, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return "", nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var envelope textEnvelope
+		if err := json.Unmarshal(trimmed, &envelope); err != nil {
+			return "", nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		ret, err := hex.DecodeString(envelope.CborHex)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid CBOR hex in %s: %w", path, err)
+		}
+		return envelope.Type, ret, nil
+	}
+	if ret, err := hex.DecodeString(string(trimmed)); err == nil {
+		return "", ret, nil
+	}
+	return "", data, nil
+}
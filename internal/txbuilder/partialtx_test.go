@@ -0,0 +1,534 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blinklabs-io/gouroboros/cbor"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// TX body with its map keys out of canonical order, which would change if the
+// body was encoded again
+const testPartialTxBody = "a2021903e80080"
+
+// TX body with a different fee
+const testOtherPartialTxBody = "a20219044c0080"
+
+// testKey returns an ed25519 key with a seed made of the provided byte
+func testKey(seed byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+}
+
+// testKeyHash returns the hex-encoded payment key hash for testKey
+func testKeyHash(seed byte) string {
+	keyHash := lcommon.Blake2b224Hash(testKey(seed).Public().(ed25519.PublicKey))
+	return keyHash.String()
+}
+
+// testWitness returns a signature of the TX body by testKey
+func testWitness(seed byte, body []byte) lcommon.VkeyWitness {
+	key := testKey(seed)
+	bodyHash := lcommon.Blake2b256Hash(body)
+	return lcommon.VkeyWitness{
+		Vkey:      key.Public().(ed25519.PublicKey),
+		Signature: ed25519.Sign(key, bodyHash.Bytes()),
+	}
+}
+
+// testPartialTx returns a TX with the body, signed by testKey for each of the
+// seeds
+func testPartialTx(
+	t *testing.T,
+	body []byte,
+	seeds []byte,
+	useSetTag bool,
+) []byte {
+	t.Helper()
+	witnessSet := []byte{0xa0}
+	if len(seeds) > 0 {
+		witnesses := make([]lcommon.VkeyWitness, 0, len(seeds))
+		for _, seed := range seeds {
+			witnesses = append(witnesses, testWitness(seed, body))
+		}
+		vkeyCbor, err := cbor.Encode(cbor.NewSetType(witnesses, useSetTag))
+		if err != nil {
+			t.Fatalf("failed to encode witnesses: %s", err)
+		}
+		witnessSet = append([]byte{0xa1, witnessKeyVkey}, vkeyCbor...)
+	}
+	ret := append([]byte{0x84}, body...)
+	ret = append(ret, witnessSet...)
+	return append(ret, 0xf5, 0xf6)
+}
+
+// testTreasuryKeys returns a treasury with the keys for the seeds as
+// cosigners
+func testTreasuryKeys(t *testing.T, seeds []byte, required int) *Treasury {
+	t.Helper()
+	keys := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		keys = append(keys, testKeyHash(seed))
+	}
+	ret, err := NewTreasury(keys, required, "preview")
+	if err != nil {
+		t.Fatalf("failed to create treasury: %s", err)
+	}
+	return ret
+}
+
+// signerSeeds returns the seeds of the keys that signed the TX, in order
+func signerSeeds(t *testing.T, tx *PartialTx) []byte {
+	t.Helper()
+	var ret []byte
+	for _, keyHash := range tx.SignerKeyHashes() {
+		for seed := range byte(10) {
+			if testKeyHash(seed) == hex.EncodeToString(keyHash) {
+				ret = append(ret, seed)
+			}
+		}
+	}
+	return ret
+}
+
+func TestPartialTxRoundTrip(t *testing.T) {
+	body := mustDecodeHex(t, testPartialTxBody)
+	bodyHash := lcommon.Blake2b256Hash(body)
+	testDefs := []struct {
+		name      string
+		seeds     []byte
+		useSetTag bool
+	}{
+		{
+			name: "unsigned",
+		},
+		{
+			name:      "signed with set tag",
+			seeds:     []byte{1},
+			useSetTag: true,
+		},
+		{
+			name:  "signed without set tag",
+			seeds: []byte{1},
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			txBytes := testPartialTx(t, body, testDef.seeds, testDef.useSetTag)
+			tx, err := DecodePartialTx(txBytes)
+			if err != nil {
+				t.Fatalf("failed to decode TX: %s", err)
+			}
+			if tx.Id() != bodyHash.String() {
+				t.Fatalf("got TX ID %s, expected %s", tx.Id(), bodyHash)
+			}
+			encoded, err := tx.Bytes()
+			if err != nil {
+				t.Fatalf("failed to encode TX: %s", err)
+			}
+			if !bytes.Equal(encoded, txBytes) {
+				t.Fatalf("TX changed: %x, expected %x", encoded, txBytes)
+			}
+			// Adding a signature keeps the body, and the set tag if it was
+			// used
+			witness := testWitness(2, body)
+			if _, err := tx.AddWitness(
+				witness.Vkey,
+				witness.Signature,
+			); err != nil {
+				t.Fatalf("failed to add signature: %s", err)
+			}
+			encoded, err = tx.Bytes()
+			if err != nil {
+				t.Fatalf("failed to encode TX: %s", err)
+			}
+			signed, err := DecodePartialTx(encoded)
+			if err != nil {
+				t.Fatalf("failed to decode TX: %s", err)
+			}
+			if !bytes.Equal(signed.raw.Body, body) ||
+				signed.Id() != bodyHash.String() {
+				t.Fatalf("TX body changed: %x", signed.raw.Body)
+			}
+			if len(signed.witnesses) != len(testDef.seeds)+1 {
+				t.Fatalf("got %d signatures", len(signed.witnesses))
+			}
+			vkeyCbor := signed.raw.Witnesses[witnessKeyVkey]
+			if useSetTag := vkeyCbor[0] == 0xd9; useSetTag != testDef.useSetTag {
+				t.Fatalf("got witnesses %x", vkeyCbor)
+			}
+		})
+	}
+}
+
+func TestPartialTxAddWitness(t *testing.T) {
+	body := mustDecodeHex(t, testPartialTxBody)
+	otherBody := mustDecodeHex(t, testOtherPartialTxBody)
+	testDefs := []struct {
+		name          string
+		witness       lcommon.VkeyWitness
+		added         bool
+		expectedError string
+	}{
+		{
+			name:    "valid signature",
+			witness: testWitness(2, body),
+			added:   true,
+		},
+		{
+			name:    "already signed",
+			witness: testWitness(1, body),
+		},
+		{
+			name:          "signature of another TX",
+			witness:       testWitness(2, otherBody),
+			expectedError: "invalid signature from key " + testKeyHash(2),
+		},
+		{
+			name: "invalid key size",
+			witness: lcommon.VkeyWitness{
+				Vkey:      []byte{1, 2, 3},
+				Signature: testWitness(2, body).Signature,
+			},
+			expectedError: "invalid verification key size",
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			tx, err := DecodePartialTx(testPartialTx(t, body, []byte{1}, true))
+			if err != nil {
+				t.Fatalf("failed to decode TX: %s", err)
+			}
+			added, err := tx.AddWitness(
+				testDef.witness.Vkey,
+				testDef.witness.Signature,
+			)
+			switch {
+			case testDef.expectedError != "":
+				if err == nil ||
+					!strings.Contains(err.Error(), testDef.expectedError) {
+					t.Fatalf(
+						"expected an error containing %q, got: %v",
+						testDef.expectedError,
+						err,
+					)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			case added != testDef.added:
+				t.Fatalf("got added %t, expected %t", added, testDef.added)
+			}
+		})
+	}
+}
+
+func TestPartialTxMerge(t *testing.T) {
+	body := mustDecodeHex(t, testPartialTxBody)
+	treasury := testTreasuryKeys(t, []byte{1, 2, 3}, 2)
+	testDefs := []struct {
+		name          string
+		body          []byte
+		seeds         []byte
+		added         int
+		expectedError string
+	}{
+		{
+			name:  "cosigner signatures",
+			body:  body,
+			seeds: []byte{1, 2, 3},
+			// The first cosigner already signed
+			added: 2,
+		},
+		{
+			name:          "non-cosigner signature",
+			body:          body,
+			seeds:         []byte{4},
+			expectedError: "key " + testKeyHash(4) + " is not a treasury cosigner",
+		},
+		{
+			name:          "different body",
+			body:          mustDecodeHex(t, testOtherPartialTxBody),
+			seeds:         []byte{2},
+			expectedError: "does not match",
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			tx, err := DecodePartialTx(testPartialTx(t, body, []byte{1}, true))
+			if err != nil {
+				t.Fatalf("failed to decode TX: %s", err)
+			}
+			other, err := DecodePartialTx(
+				testPartialTx(t, testDef.body, testDef.seeds, true),
+			)
+			if err != nil {
+				t.Fatalf("failed to decode TX: %s", err)
+			}
+			added, err := tx.Merge(other, treasury)
+			switch {
+			case testDef.expectedError != "":
+				if err == nil ||
+					!strings.Contains(err.Error(), testDef.expectedError) {
+					t.Fatalf(
+						"expected an error containing %q, got: %v",
+						testDef.expectedError,
+						err,
+					)
+				}
+				if len(tx.witnesses) != 1 {
+					t.Fatalf("got %d signatures", len(tx.witnesses))
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			case added != testDef.added:
+				t.Fatalf("added %d signatures, expected %d", added, testDef.added)
+			}
+		})
+	}
+}
+
+func TestTreasuryTrimSignatures(t *testing.T) {
+	body := mustDecodeHex(t, testPartialTxBody)
+	treasury := testTreasuryKeys(t, []byte{1, 2, 3}, 2)
+	testDefs := []struct {
+		name    string
+		seeds   []byte
+		dropped int
+		kept    []byte
+	}{
+		{
+			name:  "required signatures",
+			seeds: []byte{1, 3},
+			kept:  []byte{1, 3},
+		},
+		{
+			// Other signatures, such as for minting under the signer
+			// policy, are kept
+			name:    "extra cosigner signature",
+			seeds:   []byte{4, 1, 2, 3},
+			dropped: 1,
+			kept:    []byte{4, 1, 2},
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			tx, err := DecodePartialTx(
+				testPartialTx(t, body, testDef.seeds, true),
+			)
+			if err != nil {
+				t.Fatalf("failed to decode TX: %s", err)
+			}
+			if dropped := treasury.TrimSignatures(tx); dropped != testDef.dropped {
+				t.Fatalf(
+					"dropped %d signatures, expected %d",
+					dropped,
+					testDef.dropped,
+				)
+			}
+			txBytes, err := tx.Bytes()
+			if err != nil {
+				t.Fatalf("failed to encode TX: %s", err)
+			}
+			trimmed, err := DecodePartialTx(txBytes)
+			if err != nil {
+				t.Fatalf("failed to decode TX: %s", err)
+			}
+			if kept := signerSeeds(t, trimmed); !bytes.Equal(kept, testDef.kept) {
+				t.Fatalf("kept signatures %v, expected %v", kept, testDef.kept)
+			}
+		})
+	}
+}
+
+func TestReadSignatures(t *testing.T) {
+	body := mustDecodeHex(t, testPartialTxBody)
+	bodyHash := lcommon.Blake2b256Hash(body)
+	// Writes a cardano-cli witness file with the specified witness type
+	writeWitnessFile := func(t *testing.T, witnessType uint64) string {
+		t.Helper()
+		witnessCbor, err := cbor.Encode(
+			&struct {
+				cbor.StructAsArray
+				Type    uint64
+				Witness lcommon.VkeyWitness
+			}{
+				Type:    witnessType,
+				Witness: testWitness(1, body),
+			},
+		)
+		if err != nil {
+			t.Fatalf("failed to encode witness: %s", err)
+		}
+		data, err := json.Marshal(
+			textEnvelope{
+				Type:        "TxWitness ConwayEra",
+				Description: "Key Witness ShelleyEra",
+				CborHex:     hex.EncodeToString(witnessCbor),
+			},
+		)
+		if err != nil {
+			t.Fatalf("failed to encode witness file: %s", err)
+		}
+		path := filepath.Join(t.TempDir(), "tx.witness")
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("failed to write witness file: %s", err)
+		}
+		return path
+	}
+	testDefs := []struct {
+		name          string
+		path          func(t *testing.T) string
+		txId          string
+		expectedError string
+	}{
+		{
+			name: "TX file",
+			path: func(t *testing.T) string {
+				path := filepath.Join(t.TempDir(), "tx.json")
+				if err := WriteTxFile(
+					path,
+					testPartialTx(t, body, []byte{1}, true),
+				); err != nil {
+					t.Fatalf("failed to write TX file: %s", err)
+				}
+				return path
+			},
+			txId: bodyHash.String(),
+		},
+		{
+			name: "witness file",
+			path: func(t *testing.T) string {
+				return writeWitnessFile(t, keyWitnessTypeShelley)
+			},
+		},
+		{
+			name: "bootstrap witness file",
+			path: func(t *testing.T) string {
+				return writeWitnessFile(t, 1)
+			},
+			expectedError: "only payment key witnesses are supported",
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			txId, witnesses, err := ReadSignatures(testDef.path(t))
+			if testDef.expectedError != "" {
+				if err == nil ||
+					!strings.Contains(err.Error(), testDef.expectedError) {
+					t.Fatalf(
+						"expected an error containing %q, got: %v",
+						testDef.expectedError,
+						err,
+					)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read signatures: %s", err)
+			}
+			if txId != testDef.txId {
+				t.Fatalf("got TX ID %q, expected %q", txId, testDef.txId)
+			}
+			expected := testWitness(1, body)
+			if len(witnesses) != 1 ||
+				!bytes.Equal(witnesses[0].Vkey, expected.Vkey) ||
+				!bytes.Equal(witnesses[0].Signature, expected.Signature) {
+				t.Fatalf("got signatures %v", witnesses)
+			}
+		})
+	}
+}
+
+func TestTxFileRoundTrip(t *testing.T) {
+	txBytes := testPartialTx(
+		t,
+		mustDecodeHex(t, testPartialTxBody),
+		[]byte{1},
+		true,
+	)
+	testDefs := []struct {
+		name     string
+		fileName string
+		envelope bool
+	}{
+		{
+			name:     "raw CBOR",
+			fileName: "tx.cbor",
+		},
+		{
+			name:     "text envelope",
+			fileName: "tx.json",
+			envelope: true,
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), testDef.fileName)
+			if err := WriteTxFile(path, txBytes); err != nil {
+				t.Fatalf("failed to write TX file: %s", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read TX file: %s", err)
+			}
+			var envelope textEnvelope
+			isEnvelope := json.Unmarshal(data, &envelope) == nil
+			if isEnvelope != testDef.envelope ||
+				(isEnvelope && envelope.Type != txEnvelopeType) {
+				t.Fatalf("unexpected file contents: %s", data)
+			}
+			readBytes, err := ReadTxFile(path)
+			if err != nil {
+				t.Fatalf("failed to read TX file: %s", err)
+			}
+			if !bytes.Equal(readBytes, txBytes) {
+				t.Fatalf("got TX %x, expected %x", readBytes, txBytes)
+			}
+		})
+	}
+	// Hex-encoded CBOR is accepted too, but not other envelope types
+	hexPath := filepath.Join(t.TempDir(), "tx.hex")
+	if err := os.WriteFile(
+		hexPath,
+		[]byte(hex.EncodeToString(txBytes)+"\n"),
+		0o600,
+	); err != nil {
+		t.Fatalf("failed to write TX file: %s", err)
+	}
+	if readBytes, err := ReadTxFile(hexPath); err != nil ||
+		!bytes.Equal(readBytes, txBytes) {
+		t.Fatalf("failed to read hex TX file: %v", err)
+	}
+	witnessPath := filepath.Join(t.TempDir(), "tx.witness")
+	if err := os.WriteFile(
+		witnessPath,
+		[]byte(`{"type": "TxWitness ConwayEra", "cborHex": "00"}`),
+		0o600,
+	); err != nil {
+		t.Fatalf("failed to write witness file: %s", err)
+	}
+	if _, err := ReadTxFile(witnessPath); err == nil ||
+		!strings.Contains(err.Error(), "is not a transaction file") {
+		t.Fatalf("expected a wrong file type error, got: %v", err)
+	}
+}
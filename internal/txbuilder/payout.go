@@ -0,0 +1,153 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	serAddress "github.com/Salvionied/apollo/serialization/Address"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/rules"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+)
+
+const (
+	PayoutModeFixed     = "fixed"
+	PayoutModeDepositor = "depositor"
+
+	PayoutPolicyFirstInput   = "first-input"
+	PayoutPolicyLargestInput = "largest-input"
+	PayoutPolicyMetadata     = "metadata"
+)
+
+// resolvedInput is the output spent by a TX input
+type resolvedInput struct {
+	address  string
+	lovelace uint64
+}
+
+// payoutAddress determines where the reward for a deposit should be sent
+func payoutAddress(
+	deposit rules.Deposit,
+	inputs []resolvedInput,
+) (string, error) {
+	cfg := config.GetConfig()
+	switch cfg.Reward.PayoutMode {
+	case PayoutModeFixed, "":
+		if cfg.Reward.RewardAddress == "" {
+			return "", errors.New("no reward address defined")
+		}
+		return cfg.Reward.RewardAddress, nil
+	case PayoutModeDepositor:
+		// Handled below
+	default:
+		return "", fmt.Errorf(
+			"unknown payout mode: %s",
+			cfg.Reward.PayoutMode,
+		)
+	}
+	// Customers are paid out to the address they were registered with, since
+	// their deposits may come from exchange or other shared addresses
+	if deposit.CustomerId != "" {
+		depositAddr, _ := wallet.LookupDepositAddress(deposit.DepositAddress)
+		if depositAddr.PayoutAddress != "" {
+			return depositAddr.PayoutAddress, nil
+		}
+		slog.Warn(
+			fmt.Sprintf(
+				"no payout address for customer %s, falling back to payout policy for TX %s",
+				deposit.CustomerId,
+				deposit.TxHash,
+			),
+		)
+	}
+	if len(inputs) == 0 {
+		return "", errors.New("could not determine depositor address")
+	}
+	switch cfg.Reward.PayoutPolicy {
+	case PayoutPolicyFirstInput, "":
+		return inputs[0].address, nil
+	case PayoutPolicyLargestInput:
+		largest := inputs[0]
+		for _, input := range inputs[1:] {
+			if input.lovelace > largest.lovelace {
+				largest = input
+			}
+		}
+		return largest.address, nil
+	case PayoutPolicyMetadata:
+		addr, err := metadataReturnAddress(
+			deposit,
+			cfg.Reward.ReturnAddressLabel,
+		)
+		if err != nil {
+			slog.Warn(
+				fmt.Sprintf(
+					"falling back to first input address for TX %s: %s",
+					deposit.TxHash,
+					err,
+				),
+			)
+			return inputs[0].address, nil
+		}
+		return addr, nil
+	default:
+		return "", fmt.Errorf(
+			"unknown payout policy: %s",
+			cfg.Reward.PayoutPolicy,
+		)
+	}
+}
+
+// metadataReturnAddress extracts a return address from the TX metadata. The
+// address can be a single text value or, since metadata text values are
+// limited to 64 bytes, a list of text chunks
+func metadataReturnAddress(deposit rules.Deposit, label uint64) (string, error) {
+	value, ok := deposit.Metadata[label]
+	if !ok {
+		return "", fmt.Errorf("metadata label %d not present", label)
+	}
+	var addr string
+	switch v := value.(type) {
+	case string:
+		addr = v
+	case []any:
+		var sb strings.Builder
+		for _, item := range v {
+			chunk, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf(
+					"metadata label %d contains non-text value",
+					label,
+				)
+			}
+			sb.WriteString(chunk)
+		}
+		addr = sb.String()
+	default:
+		return "", fmt.Errorf(
+			"metadata label %d does not contain an address",
+			label,
+		)
+	}
+	// Make sure it's a valid address
+	if _, err := serAddress.DecodeAddress(addr); err != nil {
+		return "", fmt.Errorf("invalid return address in metadata: %w", err)
+	}
+	return addr, nil
+}
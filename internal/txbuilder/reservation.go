@@ -0,0 +1,159 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Salvionied/apollo/serialization/Transaction"
+	"github.com/Salvionied/apollo/serialization/TransactionInput"
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+)
+
+// reservation tracks the wallet UTxOs spent and created by a submitted TX
+// that hasn't been confirmed yet
+type reservation struct {
+	spent   []TransactionInput.TransactionInput
+	created []UTxO.UTxO
+	expires time.Time
+}
+
+// Reservations keeps track of wallet UTxOs used by submitted TXs, so that
+// the next TX doesn't try to spend them again before the chain backend
+// catches up
+type Reservations struct {
+	mutex        sync.Mutex
+	reservations map[string]*reservation
+}
+
+// Singleton reservations instance
+var globalReservations = &Reservations{
+	reservations: make(map[string]*reservation),
+}
+
+// Reserve records the inputs spent by a submitted TX along with any outputs
+// to the specified address, which become available for the next TX
+func (r *Reservations) Reserve(tx *Transaction.Transaction, addr string) {
+	cfg := config.GetConfig()
+	txId := tx.Id().Payload
+	res := &reservation{
+		spent:   slices.Clone(tx.TransactionBody.Inputs),
+		expires: time.Now().Add(cfg.TxBuilder.ReservationTimeout),
+	}
+	// Keep the UTxOs reserved until the TX expires, since it could still make
+	// it into a block until then
+	if ttl := tx.TransactionBody.Ttl; ttl > 0 {
+		if expires, err := ttlTime(uint64(ttl)); err == nil && expires.After(res.expires) { // #nosec G115
+			res.expires = expires
+		}
+	}
+	for idx, output := range tx.TransactionBody.Outputs {
+		if output.GetAddress().String() != addr {
+			continue
+		}
+		res.created = append(
+			res.created,
+			UTxO.UTxO{
+				Input: TransactionInput.TransactionInput{
+					TransactionId: txId,
+					Index:         idx,
+				},
+				Output: output,
+			},
+		)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reservations[hex.EncodeToString(txId)] = res
+}
+
+// Release removes the reservation for the specified TX, if any. This is
+// called once the TX is confirmed
+func (r *Reservations) Release(txId string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.reservations[txId]; !ok {
+		return
+	}
+	delete(r.reservations, txId)
+	slog.Debug(
+		fmt.Sprintf("released UTxO reservation for confirmed TX %s", txId),
+	)
+}
+
+// Apply removes reserved UTxOs from the provided list and adds the outputs of
+// unconfirmed TXs that haven't been spent yet
+func (r *Reservations) Apply(utxos []UTxO.UTxO) []UTxO.UTxO {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// Remove expired reservations
+	now := time.Now()
+	for txId, res := range r.reservations {
+		if now.After(res.expires) {
+			slog.Warn(
+				fmt.Sprintf(
+					"UTxO reservation for TX %s expired before confirmation",
+					txId,
+				),
+			)
+			delete(r.reservations, txId)
+		}
+	}
+	var spent []TransactionInput.TransactionInput
+	for _, res := range r.reservations {
+		spent = append(spent, res.spent...)
+	}
+	isSpent := func(utxo UTxO.UTxO) bool {
+		return slices.ContainsFunc(spent, utxo.Input.EqualTo)
+	}
+	ret := slices.DeleteFunc(slices.Clone(utxos), isSpent)
+	for _, res := range r.reservations {
+		for _, utxo := range res.created {
+			if isSpent(utxo) {
+				continue
+			}
+			// The chain backend may already know about the output
+			if slices.ContainsFunc(
+				ret,
+				func(u UTxO.UTxO) bool { return u.Input.EqualTo(utxo.Input) },
+			) {
+				continue
+			}
+			ret = append(ret, utxo)
+		}
+	}
+	return ret
+}
+
+// GetReservations returns the global reservations instance
+func GetReservations() *Reservations {
+	return globalReservations
+}
+
+// getWalletUtxos returns the spendable UTxOs for the wallet, taking into
+// account TXs that have been submitted but not confirmed
+func getWalletUtxos(addr string) ([]UTxO.UTxO, error) {
+	utxos, err := getUtxosByAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	return GetReservations().Apply(utxos), nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/Salvionied/apollo/serialization/Transaction"
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/rules"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/bursa"
+)
+
+// buildReservedTx builds a reward TX spending only the UTxO
+func buildReservedTx(
+	t *testing.T,
+	w *bursa.Wallet,
+	s signer.Signer,
+	utxo UTxO.UTxO,
+) *Transaction.Transaction {
+	t.Helper()
+	tx, err := BuildRewardTxFromUtxos(
+		w,
+		s,
+		[]UTxO.UTxO{utxo},
+		NewPayout(
+			testAddress(t, 0x01),
+			rules.RewardSpec{Lovelace: 5_000_000},
+		),
+	)
+	if err != nil {
+		t.Fatalf("failed to build reward TX: %s", err)
+	}
+	return tx
+}
+
+// utxoIds returns the "<TX ID>#<index>" of each UTxO
+func utxoIds(utxos []UTxO.UTxO) []string {
+	ret := make([]string, 0, len(utxos))
+	for _, utxo := range utxos {
+		ret = append(ret, utxo.GetKey())
+	}
+	return ret
+}
+
+func TestReservationsApply(t *testing.T) {
+	w, s, _ := setupTest(t)
+	spentUtxo := testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)
+	otherUtxo := testUtxo(t, w.PaymentAddress, 0x11, 50_000_000)
+	tx := buildReservedTx(t, w, s, spentUtxo)
+	r := GetReservations()
+	r.Reserve(tx, w.PaymentAddress)
+	// The spent UTxO is removed and the change becomes available
+	utxos := r.Apply([]UTxO.UTxO{spentUtxo, otherUtxo})
+	if len(utxos) != 2 || !utxos[0].Input.EqualTo(otherUtxo.Input) {
+		t.Fatalf(
+			"expected the other UTxO and the change, got %v",
+			utxoIds(utxos),
+		)
+	}
+	change := utxos[1]
+	if hex.EncodeToString(change.Input.TransactionId) !=
+		hex.EncodeToString(tx.Id().Payload) {
+		t.Fatalf(
+			"expected the change from the reward TX, got %s",
+			change.GetKey(),
+		)
+	}
+	changeOutput := tx.TransactionBody.Outputs[change.Input.Index]
+	if changeOutput.GetAddress().String() != w.PaymentAddress ||
+		change.Output.Lovelace() != changeOutput.Lovelace() {
+		t.Fatalf("change UTxO doesn't match the TX output")
+	}
+	// The change isn't added again once the chain backend knows about it
+	utxos = r.Apply([]UTxO.UTxO{otherUtxo, change})
+	if len(utxos) != 2 {
+		t.Fatalf("expected the change only once, got %v", utxoIds(utxos))
+	}
+	// A TX spending the change removes it again
+	changeTx := buildReservedTx(t, w, s, change)
+	r.Reserve(changeTx, w.PaymentAddress)
+	utxos = r.Apply([]UTxO.UTxO{spentUtxo, otherUtxo})
+	for _, utxo := range utxos {
+		if utxo.Input.EqualTo(change.Input) ||
+			utxo.Input.EqualTo(spentUtxo.Input) {
+			t.Fatalf("reserved UTxO %s wasn't removed", utxo.GetKey())
+		}
+	}
+	if len(utxos) != 2 {
+		t.Fatalf(
+			"expected the other UTxO and the new change, got %v",
+			utxoIds(utxos),
+		)
+	}
+}
+
+func TestReservationsRelease(t *testing.T) {
+	w, s, _ := setupTest(t)
+	spentUtxo := testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)
+	tx := buildReservedTx(t, w, s, spentUtxo)
+	txId := hex.EncodeToString(tx.Id().Payload)
+	r := GetReservations()
+	r.Reserve(tx, w.PaymentAddress)
+	if utxos := r.Apply([]UTxO.UTxO{spentUtxo}); len(utxos) != 1 ||
+		utxos[0].Input.EqualTo(spentUtxo.Input) {
+		t.Fatalf("expected only the change, got %v", utxoIds(utxos))
+	}
+	// Seeing our own TX on chain releases the reservation, since the chain
+	// backend now reflects it
+	if err := HandleEvent(
+		depositEvent(t, txId, w.PaymentAddress, w.PaymentAddress, 1_000_000),
+	); err != nil {
+		t.Fatalf("failed to handle event: %s", err)
+	}
+	if _, ok := r.reservations[txId]; ok {
+		t.Fatalf("reservation wasn't released")
+	}
+	utxos := r.Apply([]UTxO.UTxO{spentUtxo})
+	if len(utxos) != 1 || !utxos[0].Input.EqualTo(spentUtxo.Input) {
+		t.Fatalf("expected the UTxOs unchanged, got %v", utxoIds(utxos))
+	}
+}
+
+func TestReservationsExpiry(t *testing.T) {
+	testDefs := []struct {
+		name    string
+		timeout time.Duration
+		// Slots to add to the current slot for the TTL, or 0 for no TTL
+		ttlOffset int64
+		expired   bool
+	}{
+		{
+			name:    "kept until the timeout",
+			timeout: time.Hour,
+		},
+		{
+			name:    "expired after the timeout",
+			timeout: time.Nanosecond,
+			expired: true,
+		},
+		{
+			name:      "kept until the TTL after the timeout",
+			timeout:   time.Nanosecond,
+			ttlOffset: 100,
+		},
+		{
+			name:      "expired after the TTL and the timeout",
+			timeout:   time.Nanosecond,
+			ttlOffset: -10,
+			expired:   true,
+		},
+		{
+			name:      "kept until the timeout after the TTL",
+			timeout:   time.Hour,
+			ttlOffset: -10,
+		},
+	}
+	cfg := config.GetConfig()
+	origTimeout := cfg.TxBuilder.ReservationTimeout
+	t.Cleanup(func() { cfg.TxBuilder.ReservationTimeout = origTimeout })
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			w, s, _ := setupTest(t)
+			spentUtxo := testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)
+			tx := buildReservedTx(t, w, s, spentUtxo)
+			tx.TransactionBody.Ttl = 0
+			if testDef.ttlOffset != 0 {
+				tx.TransactionBody.Ttl = int64(currentSlot()) + // #nosec G115
+					testDef.ttlOffset
+			}
+			cfg.TxBuilder.ReservationTimeout = testDef.timeout
+			r := GetReservations()
+			r.Reserve(tx, w.PaymentAddress)
+			time.Sleep(time.Millisecond)
+			utxos := r.Apply([]UTxO.UTxO{spentUtxo})
+			spentAvailable := len(utxos) == 1 &&
+				utxos[0].Input.EqualTo(spentUtxo.Input)
+			if spentAvailable != testDef.expired {
+				t.Fatalf(
+					"expected expired %t, got UTxOs %v",
+					testDef.expired,
+					utxoIds(utxos),
+				)
+			}
+			txId := hex.EncodeToString(tx.Id().Payload)
+			if _, ok := r.reservations[txId]; ok == testDef.expired {
+				t.Fatalf("expected reservation removed %t", testDef.expired)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/tracker"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txsubmit"
+)
+
+// Retrier schedules another attempt for rewards whose TX failed to build,
+// submit or confirm. Transient submit errors resubmit the same signed TX,
+// while other failures rebuild the reward TX from scratch. The schedule is
+// kept in the reward ledger, so that it survives a restart
+type Retrier struct {
+	mutex  sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// Singleton retrier instance
+var globalRetrier = &Retrier{
+	timers: make(map[string]*time.Timer),
+}
+
+// Start resumes the retries scheduled by a previous run and starts watching
+// for expired reward TXs
+func (r *Retrier) Start() error {
+	tracker.GetTracker().AddListener(r.handleTxStatus)
+	rewards, err := storage.GetStorage().ListRewards()
+	if err != nil {
+		return fmt.Errorf("failed to list rewards: %w", err)
+	}
+	for idx := range rewards {
+		reward := &rewards[idx]
+		if reward.NextAttempt.IsZero() {
+			continue
+		}
+		switch reward.Status {
+		case storage.RewardStatusPending:
+			r.scheduleRebuild(reward)
+		case storage.RewardStatusBuilt:
+			r.scheduleResubmit(reward.RewardTxId, reward.NextAttempt)
+		}
+	}
+	return nil
+}
+
+// retryRewards schedules the rewards to be paid out in a new TX and returns
+// the original error
+func retryRewards(items []batchItem, origErr error) error {
+	for _, item := range items {
+		if err := GetRetrier().rebuild(item.reward, origErr); err != nil {
+			slog.Error(
+				fmt.Sprintf("failed to update reward ledger: %s", err),
+			)
+		}
+	}
+	return origErr
+}
+
+// rebuild schedules a reward to be paid out in a new TX, or moves it to the
+// dead-letter state if it has used up its attempts
+func (r *Retrier) rebuild(reward *storage.Reward, origErr error) error {
+	cfg := config.GetConfig()
+	reward.Attempts++
+	reward.Error = origErr.Error()
+	if reward.Attempts >= cfg.Retry.MaxAttempts {
+		deadLetter(reward)
+		return storage.GetStorage().UpdateReward(reward)
+	}
+	reward.Status = storage.RewardStatusPending
+	reward.NextAttempt = time.Now().Add(retryBackoff(reward.Attempts))
+	if err := storage.GetStorage().UpdateReward(reward); err != nil {
+		return err
+	}
+	slog.Warn(
+		fmt.Sprintf(
+			"reward for TX %s failed (attempt %d of %d), rebuilding at %s: %s",
+			reward.TxHash,
+			reward.Attempts,
+			cfg.Retry.MaxAttempts,
+			reward.NextAttempt.Format(time.RFC3339),
+			origErr,
+		),
+	)
+	r.scheduleRebuild(reward)
+	return nil
+}
+
+// scheduleRebuild adds a reward to the next batch once its next attempt is
+// due
+func (r *Retrier) scheduleRebuild(reward *storage.Reward) {
+	txHash := reward.TxHash
+	r.schedule(
+		"rebuild:"+txHash,
+		reward.NextAttempt,
+		func() {
+			// Reload the reward in case it changed since being scheduled
+			reward, err := storage.GetStorage().GetReward(txHash)
+			if err != nil {
+				slog.Error(
+					fmt.Sprintf("failed to lookup reward ledger: %s", err),
+				)
+				return
+			}
+			if reward == nil || reward.Status != storage.RewardStatusPending {
+				return
+			}
+			reward.NextAttempt = time.Time{}
+			if err := storage.GetStorage().UpdateReward(reward); err != nil {
+				slog.Error(
+					fmt.Sprintf("failed to update reward ledger: %s", err),
+				)
+				return
+			}
+			GetBatcher().Add(reward, rewardPayout(reward))
+		},
+	)
+}
+
+// retrySubmit schedules a TX that failed to submit with a transient error to
+// be submitted again, or moves its rewards to the dead-letter state if
+// they've used up their attempts
+func (r *Retrier) retrySubmit(
+	txId string,
+	rewards []*storage.Reward,
+	origErr error,
+) error {
+	cfg := config.GetConfig()
+	// The rewards are retried together, since they're paid out by the
+	// same TX
+	var attempts int
+	for _, reward := range rewards {
+		reward.Attempts++
+		attempts = max(attempts, reward.Attempts)
+	}
+	var nextAttempt time.Time
+	if attempts < cfg.Retry.MaxAttempts {
+		nextAttempt = time.Now().Add(retryBackoff(attempts))
+	}
+	for _, reward := range rewards {
+		reward.Error = origErr.Error()
+		reward.NextAttempt = nextAttempt
+		if nextAttempt.IsZero() {
+			// The TX stays tracked, in case it made it to the node after all
+			deadLetter(reward)
+		}
+		if err := storage.GetStorage().UpdateReward(reward); err != nil {
+			return err
+		}
+	}
+	if nextAttempt.IsZero() {
+		return nil
+	}
+	slog.Warn(
+		fmt.Sprintf(
+			"failed to submit TX %s (attempt %d of %d), resubmitting at %s: %s",
+			txId,
+			attempts,
+			cfg.Retry.MaxAttempts,
+			nextAttempt.Format(time.RFC3339),
+			origErr,
+		),
+	)
+	r.scheduleResubmit(txId, nextAttempt)
+	return nil
+}
+
+// scheduleResubmit submits the signed TX again once its next attempt is due
+func (r *Retrier) scheduleResubmit(txId string, at time.Time) {
+	r.schedule(
+		"resubmit:"+txId,
+		at,
+		func() {
+			if err := r.resubmit(txId); err != nil {
+				slog.Error(
+					fmt.Sprintf("failed to resubmit TX %s: %s", txId, err),
+				)
+			}
+		},
+	)
+}
+
+// resubmit submits a TX that previously failed with a transient error
+func (r *Retrier) resubmit(txId string) error {
+	tx, err := tracker.GetTracker().Status(txId)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return errors.New("unknown transaction")
+	}
+	rewards, err := txRewards(tx, storage.RewardStatusBuilt)
+	if err != nil {
+		return err
+	}
+	if len(rewards) == 0 {
+		return nil
+	}
+	switch tx.Status {
+	case storage.TxStatusIncluded, storage.TxStatusConfirmed:
+		// The TX made it to the node after all
+		return markSubmitted(rewards)
+	case storage.TxStatusExpired, storage.TxStatusRejected:
+		// The rewards are rebuilt when the TX fails
+		return nil
+	}
+	txBytes, err := hex.DecodeString(tx.TxCbor)
+	if err != nil {
+		return fmt.Errorf("failed to decode TX: %w", err)
+	}
+	if submitErr := txsubmit.SubmitTx(txBytes); submitErr != nil {
+		if isTransientError(submitErr) {
+			return r.retrySubmit(txId, rewards, submitErr)
+		}
+		// An earlier attempt may have made it to the node after all, in which
+		// case the TX is rejected for spending its own inputs again. It's only
+		// safe to rebuild the rewards once the TX has expired, which the
+		// tracker tells us about
+		slog.Warn(
+			fmt.Sprintf(
+				"resubmitting TX %s failed, waiting for it to be confirmed or expire: %s",
+				txId,
+				submitErr,
+			),
+		)
+		for _, reward := range rewards {
+			reward.Error = submitErr.Error()
+			reward.NextAttempt = time.Time{}
+			if err := storage.GetStorage().UpdateReward(reward); err != nil {
+				return fmt.Errorf("failed to update reward ledger: %w", err)
+			}
+		}
+		return nil
+	}
+	slog.Info(
+		fmt.Sprintf(
+			"resubmitted transaction %s paying out %d rewards",
+			txId,
+			len(rewards),
+		),
+	)
+	return markSubmitted(rewards)
+}
+
+// handleTxStatus rebuilds the rewards paid out by a TX that expired before
+// being included in a block
+func (r *Retrier) handleTxStatus(tx storage.Transaction) {
+	if tx.Status != storage.TxStatusExpired {
+		return
+	}
+	GetReservations().Release(tx.TxId)
+	rewards, err := txRewards(&tx, storage.RewardStatusFailed)
+	if err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to lookup reward ledger: %s", err),
+		)
+		return
+	}
+	for _, reward := range rewards {
+		if err := r.rebuild(
+			reward,
+			fmt.Errorf("transaction %s: %w", tx.TxId, txsubmit.ErrTxExpired),
+		); err != nil {
+			slog.Error(
+				fmt.Sprintf("failed to update reward ledger: %s", err),
+			)
+		}
+	}
+}
+
+// rejectTx stops tracking a TX that can't be included in a block and frees
+// up the UTxOs it spent
+func rejectTx(txId string) {
+	if err := tracker.GetTracker().Reject(txId); err != nil {
+		slog.Error(
+			fmt.Sprintf("failed to update transaction tracker: %s", err),
+		)
+	}
+	GetReservations().Release(txId)
+}
+
+// schedule runs the function at the specified time, replacing anything
+// already scheduled with the same key
+func (r *Retrier) schedule(key string, at time.Time, retryFunc func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if timer, ok := r.timers[key]; ok {
+		timer.Stop()
+	}
+	r.timers[key] = time.AfterFunc(
+		time.Until(at),
+		func() {
+			r.mutex.Lock()
+			delete(r.timers, key)
+			r.mutex.Unlock()
+			retryFunc()
+		},
+	)
+}
+
+// GetRetrier returns the global retrier instance
+func GetRetrier() *Retrier {
+	return globalRetrier
+}
+
+// isTransientError returns whether a submit error might go away by itself,
+// in which case the same TX can be submitted again. Expired and rejected TXs,
+// such as those spending inputs that were already spent, need to be rebuilt
+func isTransientError(err error) bool {
+	var rejectedErr *txsubmit.TxRejectedError
+	return !errors.Is(err, txsubmit.ErrTxExpired) &&
+		!errors.As(err, &rejectedErr)
+}
+
+// retryBackoff returns the delay before the next attempt after the specified
+// number of failed attempts
+func retryBackoff(attempts int) time.Duration {
+	cfg := config.GetConfig()
+	ret := cfg.Retry.InitialBackoff
+	for i := 1; i < attempts && ret < cfg.Retry.MaxBackoff; i++ {
+		ret *= 2
+	}
+	return min(ret, cfg.Retry.MaxBackoff)
+}
+
+// deadLetter gives up on a reward after too many failed attempts
+func deadLetter(reward *storage.Reward) {
+	reward.Status = storage.RewardStatusDeadLetter
+	reward.NextAttempt = time.Time{}
+	slog.Error(
+		fmt.Sprintf(
+			"giving up on reward for TX %s after %d attempts: %s",
+			reward.TxHash,
+			reward.Attempts,
+			reward.Error,
+		),
+	)
+}
+
+// markSubmitted records that the rewards were successfully submitted
+func markSubmitted(rewards []*storage.Reward) error {
+	for _, reward := range rewards {
+		reward.Status = storage.RewardStatusSubmitted
+		reward.NextAttempt = time.Time{}
+		reward.Error = ""
+		if err := storage.GetStorage().UpdateReward(reward); err != nil {
+			return fmt.Errorf("failed to update reward ledger: %w", err)
+		}
+	}
+	return nil
+}
+
+// txRewards returns the rewards paid out by a TX that have the specified
+// status
+func txRewards(
+	tx *storage.Transaction,
+	status storage.RewardStatus,
+) ([]*storage.Reward, error) {
+	var ret []*storage.Reward
+	for _, txHash := range tx.RewardTxHashes {
+		reward, err := storage.GetStorage().GetReward(txHash)
+		if err != nil {
+			return nil, err
+		}
+		if reward == nil || reward.RewardTxId != tx.TxId ||
+			reward.Status != status {
+			continue
+		}
+		ret = append(ret, reward)
+	}
+	return ret, nil
+}
@@ -0,0 +1,457 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/tracker"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+)
+
+// Delay before the first retry in tests, long enough that nothing is retried
+// before the test checks the schedule
+const testInitialBackoff = time.Hour
+
+// setupRetrier replaces the global retrier with one that allows 3 attempts
+func setupRetrier(t *testing.T) *Retrier {
+	t.Helper()
+	cfg := config.GetConfig()
+	origRetry := cfg.Retry
+	cfg.Retry = config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: testInitialBackoff,
+		MaxBackoff:     4 * testInitialBackoff,
+	}
+	globalRetrier = &Retrier{
+		timers: make(map[string]*time.Timer),
+	}
+	r := globalRetrier
+	t.Cleanup(func() {
+		r.mutex.Lock()
+		for _, timer := range r.timers {
+			timer.Stop()
+		}
+		r.mutex.Unlock()
+		cfg.Retry = origRetry
+	})
+	return r
+}
+
+// scheduled returns the keys of the retries scheduled by the retrier
+func (r *Retrier) scheduled() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	ret := make([]string, 0, len(r.timers))
+	for key := range r.timers {
+		ret = append(ret, key)
+	}
+	return ret
+}
+
+// checkReward makes sure that a reward has the expected status and number of
+// attempts, and that its next attempt is due after the expected backoff, or
+// not scheduled if the backoff is 0
+func checkReward(
+	t *testing.T,
+	txHash string,
+	status storage.RewardStatus,
+	attempts int,
+	backoff time.Duration,
+) *storage.Reward {
+	t.Helper()
+	reward, err := storage.GetStorage().GetReward(txHash)
+	if err != nil {
+		t.Fatalf("failed to look up reward: %s", err)
+	}
+	if reward.Status != status || reward.Attempts != attempts {
+		t.Fatalf(
+			"expected reward %s after %d attempts, got: %+v",
+			status,
+			attempts,
+			reward,
+		)
+	}
+	if backoff == 0 {
+		if !reward.NextAttempt.IsZero() {
+			t.Fatalf("expected no next attempt, got %s", reward.NextAttempt)
+		}
+		return reward
+	}
+	if until := time.Until(reward.NextAttempt); until > backoff ||
+		until < backoff-time.Minute {
+		t.Fatalf("expected next attempt in %s, got %s", backoff, until)
+	}
+	return reward
+}
+
+// submitFailing submits a batch paying out the rewards to a submit API
+// responding with the specified status and returns the TX ID
+func submitFailing(
+	t *testing.T,
+	api *testSubmitApi,
+	status int,
+	utxos *[]UTxO.UTxO,
+	items []batchItem,
+) string {
+	t.Helper()
+	w, s := wallet.GetWallet(), signer.GetSigner()
+	api.setStatus(status)
+	if err := submitBatch(w, s, w.PaymentAddress, utxos, items); err == nil {
+		t.Fatalf("expected the batch to fail")
+	}
+	reward, err := storage.GetStorage().GetReward(items[0].reward.TxHash)
+	if err != nil {
+		t.Fatalf("failed to look up reward: %s", err)
+	}
+	return reward.RewardTxId
+}
+
+func TestRetryBackoff(t *testing.T) {
+	setupRetrier(t)
+	testDefs := []struct {
+		attempts int
+		backoff  time.Duration
+	}{
+		{attempts: 1, backoff: testInitialBackoff},
+		{attempts: 2, backoff: 2 * testInitialBackoff},
+		{attempts: 3, backoff: 4 * testInitialBackoff},
+		// Capped at the maximum backoff
+		{attempts: 4, backoff: 4 * testInitialBackoff},
+		{attempts: 100, backoff: 4 * testInitialBackoff},
+	}
+	for _, testDef := range testDefs {
+		if backoff := retryBackoff(testDef.attempts); backoff != testDef.backoff {
+			t.Fatalf(
+				"expected backoff %s after %d attempts, got %s",
+				testDef.backoff,
+				testDef.attempts,
+				backoff,
+			)
+		}
+	}
+}
+
+func TestRetrySubmitFailure(t *testing.T) {
+	testDefs := []struct {
+		name     string
+		status   int
+		attempts int
+		// Expected reward state after the failure
+		rewardStatus storage.RewardStatus
+		backoff      time.Duration
+		// Expected retry scheduled, if any
+		retry    string
+		txStatus storage.TxStatus
+	}{
+		{
+			name:         "transient error resubmits",
+			status:       http.StatusServiceUnavailable,
+			rewardStatus: storage.RewardStatusBuilt,
+			backoff:      testInitialBackoff,
+			retry:        "resubmit",
+			txStatus:     storage.TxStatusSubmitted,
+		},
+		{
+			name:         "transient error on the last attempt",
+			status:       http.StatusServiceUnavailable,
+			attempts:     2,
+			rewardStatus: storage.RewardStatusDeadLetter,
+			// The TX is still tracked, in case it made it to the node
+			txStatus: storage.TxStatusSubmitted,
+		},
+		{
+			name:         "rejected TX rebuilds",
+			status:       http.StatusBadRequest,
+			rewardStatus: storage.RewardStatusPending,
+			backoff:      testInitialBackoff,
+			retry:        "rebuild",
+			txStatus:     storage.TxStatusRejected,
+		},
+		{
+			name:         "rejected TX on the last attempt",
+			status:       http.StatusBadRequest,
+			attempts:     2,
+			rewardStatus: storage.RewardStatusDeadLetter,
+			txStatus:     storage.TxStatusRejected,
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			w, _, _ := setupTest(t)
+			api := startSubmitApi(t)
+			r := setupRetrier(t)
+			items := testRewards(t, 1)
+			items[0].reward.Attempts = testDef.attempts
+			utxos := []UTxO.UTxO{testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)}
+			txId := submitFailing(t, api, testDef.status, &utxos, items)
+			reward := checkReward(
+				t,
+				items[0].reward.TxHash,
+				testDef.rewardStatus,
+				testDef.attempts+1,
+				testDef.backoff,
+			)
+			if reward.Error == "" {
+				t.Fatalf("expected the submit error to be recorded")
+			}
+			var retries []string
+			switch testDef.retry {
+			case "resubmit":
+				retries = []string{"resubmit:" + txId}
+			case "rebuild":
+				retries = []string{"rebuild:" + reward.TxHash}
+			}
+			if scheduled := r.scheduled(); !slices.Equal(scheduled, retries) {
+				t.Fatalf("expected retries %v, got %v", retries, scheduled)
+			}
+			tx, err := tracker.GetTracker().Status(txId)
+			if err != nil || tx == nil {
+				t.Fatalf("failed to look up TX: %v", err)
+			}
+			if tx.Status != testDef.txStatus {
+				t.Fatalf("expected TX %s, got %s", testDef.txStatus, tx.Status)
+			}
+		})
+	}
+}
+
+func TestResubmit(t *testing.T) {
+	testDefs := []struct {
+		name   string
+		status int
+		// Whether the TX made it to the node after all
+		included bool
+		// Expected reward state after resubmitting
+		rewardStatus storage.RewardStatus
+		attempts     int
+		backoff      time.Duration
+		submitted    int
+	}{
+		{
+			name:         "accepted",
+			rewardStatus: storage.RewardStatusSubmitted,
+			attempts:     1,
+			submitted:    1,
+		},
+		{
+			name:         "transient error backs off",
+			status:       http.StatusServiceUnavailable,
+			rewardStatus: storage.RewardStatusBuilt,
+			attempts:     2,
+			backoff:      2 * testInitialBackoff,
+		},
+		{
+			// The first attempt may have spent the inputs, so the rewards
+			// wait for the TX to be confirmed or expire
+			name:         "rejected waits for the tracker",
+			status:       http.StatusBadRequest,
+			rewardStatus: storage.RewardStatusBuilt,
+			attempts:     1,
+		},
+		{
+			name:         "already included",
+			status:       http.StatusServiceUnavailable,
+			included:     true,
+			rewardStatus: storage.RewardStatusSubmitted,
+			attempts:     1,
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			w, _, _ := setupTest(t)
+			api := startSubmitApi(t)
+			r := setupRetrier(t)
+			items := testRewards(t, 1)
+			utxos := []UTxO.UTxO{testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)}
+			txId := submitFailing(
+				t,
+				api,
+				http.StatusServiceUnavailable,
+				&utxos,
+				items,
+			)
+			if testDef.included {
+				if err := tracker.GetTracker().HandleTransaction(
+					txId,
+					1000,
+					10,
+					"",
+				); err != nil {
+					t.Fatalf("failed to handle transaction: %s", err)
+				}
+			}
+			api.setStatus(testDef.status)
+			if err := r.resubmit(txId); err != nil {
+				t.Fatalf("failed to resubmit: %s", err)
+			}
+			checkReward(
+				t,
+				items[0].reward.TxHash,
+				testDef.rewardStatus,
+				testDef.attempts,
+				testDef.backoff,
+			)
+			if submitted := api.submitted(); len(submitted) != testDef.submitted {
+				t.Fatalf(
+					"expected %d TXs submitted, got %d",
+					testDef.submitted,
+					len(submitted),
+				)
+			}
+		})
+	}
+}
+
+func TestRetryExpired(t *testing.T) {
+	testDefs := []struct {
+		name     string
+		txStatus storage.TxStatus
+		// Expected reward state after the status change
+		rewardStatus storage.RewardStatus
+		attempts     int
+		backoff      time.Duration
+	}{
+		{
+			name:         "expired TX rebuilds",
+			txStatus:     storage.TxStatusExpired,
+			rewardStatus: storage.RewardStatusPending,
+			attempts:     2,
+			backoff:      2 * testInitialBackoff,
+		},
+		{
+			name:         "confirmed TX",
+			txStatus:     storage.TxStatusConfirmed,
+			rewardStatus: storage.RewardStatusFailed,
+			attempts:     1,
+		},
+	}
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			w, _, _ := setupTest(t)
+			api := startSubmitApi(t)
+			r := setupRetrier(t)
+			items := testRewards(t, 1)
+			input := testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)
+			utxos := []UTxO.UTxO{input}
+			txId := submitFailing(
+				t,
+				api,
+				http.StatusServiceUnavailable,
+				&utxos,
+				items,
+			)
+			// The tracker fails the rewards before notifying the retrier
+			reward := checkReward(
+				t,
+				items[0].reward.TxHash,
+				storage.RewardStatusBuilt,
+				1,
+				testInitialBackoff,
+			)
+			reward.Status = storage.RewardStatusFailed
+			reward.NextAttempt = time.Time{}
+			if err := storage.GetStorage().UpdateReward(reward); err != nil {
+				t.Fatalf("failed to update reward: %s", err)
+			}
+			tx, err := tracker.GetTracker().Status(txId)
+			if err != nil || tx == nil {
+				t.Fatalf("failed to look up TX: %v", err)
+			}
+			tx.Status = testDef.txStatus
+			r.handleTxStatus(*tx)
+			checkReward(
+				t,
+				reward.TxHash,
+				testDef.rewardStatus,
+				testDef.attempts,
+				testDef.backoff,
+			)
+			// The inputs of an expired TX can be spent again
+			released := slices.ContainsFunc(
+				GetReservations().Apply([]UTxO.UTxO{input}),
+				func(utxo UTxO.UTxO) bool {
+					return utxo.Input.EqualTo(input.Input)
+				},
+			)
+			if released != (testDef.txStatus == storage.TxStatusExpired) {
+				t.Fatalf("expected the inputs released only on expiry")
+			}
+		})
+	}
+}
+
+func TestRetrierStart(t *testing.T) {
+	w, _, _ := setupTest(t)
+	api := startSubmitApi(t)
+	setupRetrier(t)
+	items := testRewards(t, 3)
+	utxos := []UTxO.UTxO{testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)}
+	resubmitTxId := submitFailing(
+		t,
+		api,
+		http.StatusServiceUnavailable,
+		&utxos,
+		items[:1],
+	)
+	submitFailing(t, api, http.StatusBadRequest, &utxos, items[1:2])
+	// Rewards that used up their attempts aren't retried
+	items[2].reward.Attempts = 2
+	submitFailing(t, api, http.StatusBadRequest, &utxos, items[2:])
+	nextAttempts := make(map[string]time.Time)
+	for _, item := range items {
+		nextAttempts[item.reward.TxHash] = item.reward.NextAttempt
+	}
+	// Restart with the schedule stored in the reward ledger
+	if err := storage.GetStorage().Close(); err != nil {
+		t.Fatalf("failed to close storage: %s", err)
+	}
+	if err := storage.GetStorage().Load(); err != nil {
+		t.Fatalf("failed to load storage: %s", err)
+	}
+	r := setupRetrier(t)
+	if err := r.Start(); err != nil {
+		t.Fatalf("failed to start retrier: %s", err)
+	}
+	for txHash, nextAttempt := range nextAttempts {
+		reward, err := storage.GetStorage().GetReward(txHash)
+		if err != nil {
+			t.Fatalf("failed to look up reward: %s", err)
+		}
+		if !reward.NextAttempt.Equal(nextAttempt) {
+			t.Fatalf(
+				"expected next attempt %s after restart, got %s",
+				nextAttempt,
+				reward.NextAttempt,
+			)
+		}
+	}
+	retries := []string{
+		"rebuild:" + items[1].reward.TxHash,
+		"resubmit:" + resubmitTxId,
+	}
+	scheduled := r.scheduled()
+	slices.Sort(scheduled)
+	if !slices.Equal(scheduled, retries) {
+		t.Fatalf("expected retries %v after restart, got %v", retries, scheduled)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/Salvionied/apollo"
+	"github.com/Salvionied/apollo/serialization/Transaction"
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txsubmit"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+)
+
+// Sweep moves the funds at the receive addresses and customer deposit
+// addresses to the destination address, or to the address rewards are paid
+// from if none is provided. Those addresses have their own payment keys, which
+// are derived from the wallet mnemonic, so their funds are never spent by
+// reward TXs. One TX is submitted for each address with UTxOs, and the IDs of
+// the submitted TXs are returned
+func Sweep(destAddr string) ([]string, error) {
+	w := wallet.GetWallet()
+	if w == nil {
+		return nil, errors.New("cannot initialize wallet")
+	}
+	if destAddr == "" {
+		var err error
+		destAddr, err = rewardSourceAddress(w)
+		if err != nil {
+			return nil, err
+		}
+	} else if err := wallet.CheckAddressNetwork(destAddr); err != nil {
+		return nil, fmt.Errorf("invalid destination address: %w", err)
+	}
+	derivedAddrs := wallet.GetDerivedAddresses()
+	// Sweep in address index order, so that the output is predictable
+	addrs := make([]string, 0, len(derivedAddrs))
+	for addr := range derivedAddrs {
+		addrs = append(addrs, addr)
+	}
+	slices.SortFunc(addrs, func(a, b string) int {
+		return int(derivedAddrs[a]) - int(derivedAddrs[b])
+	})
+	var ret []string
+	var errs []error
+	for _, addr := range addrs {
+		if addr == destAddr {
+			continue
+		}
+		txId, err := sweepAddress(addr, derivedAddrs[addr], destAddr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to sweep %s: %w", addr, err))
+			continue
+		}
+		if txId != "" {
+			ret = append(ret, txId)
+		}
+	}
+	return ret, errors.Join(errs...)
+}
+
+// sweepAddress submits a TX moving the funds at the address with the payment
+// key at the specified index to the destination address. It returns an empty
+// TX ID if there's nothing to sweep
+func sweepAddress(addr string, index uint32, destAddr string) (string, error) {
+	utxos, err := getWalletUtxos(addr)
+	if err != nil {
+		return "", err
+	}
+	if len(utxos) == 0 {
+		return "", nil
+	}
+	key, err := wallet.PaymentKey(index)
+	if err != nil {
+		return "", err
+	}
+	s, err := signer.NewExtendedKeySigner(key)
+	if err != nil {
+		return "", err
+	}
+	tx, err := BuildSweepTx(s, utxos, destAddr)
+	if err != nil {
+		return "", err
+	}
+	txBytes, err := tx.Bytes()
+	if err != nil {
+		return "", err
+	}
+	txId := hex.EncodeToString(tx.Id().Payload)
+	if err := txsubmit.SubmitTx(txBytes); err != nil {
+		return "", err
+	}
+	// Don't spend the swept UTxOs again before the TX is on chain
+	GetReservations().Reserve(tx, addr)
+	slog.Info(
+		fmt.Sprintf(
+			"submitted TX %s sweeping %d UTxOs from %s to %s",
+			txId,
+			len(utxos),
+			addr,
+			destAddr,
+		),
+	)
+	return txId, nil
+}
+
+// BuildSweepTx builds a transaction spending all of the provided UTxOs, which
+// must belong to the signer payment key, to the destination address, and signs
+// it with the signer
+func BuildSweepTx(
+	s signer.Signer,
+	utxos []UTxO.UTxO,
+	destAddr string,
+) (*Transaction.Transaction, error) {
+	if len(utxos) == 0 {
+		return nil, errors.New("no UTxOs provided")
+	}
+	cc, err := getChainContext()
+	if err != nil {
+		return nil, err
+	}
+	apollob := apollo.New(cc)
+	apollob, err = apollob.
+		SetWalletFromBech32(destAddr).
+		SetWalletAsChangeAddress()
+	if err != nil {
+		return nil, err
+	}
+	// Spend every UTxO, with everything going to the destination as change
+	apollob = apollob.AddInput(utxos...)
+	if ttl := rewardTtl(0, false); ttl > 0 {
+		apollob = apollob.SetTtl(
+			int64(ttl), // #nosec G115
+		)
+	}
+	tx, err := apollob.Complete()
+	if err != nil {
+		// apollo doesn't provide an error type for this
+		if err.Error() == "transaction too large" {
+			return nil, ErrTxTooLarge
+		}
+		return nil, err
+	}
+	if err := signTx(tx.GetTx(), s); err != nil {
+		return nil, err
+	}
+	return tx.GetTx(), nil
+}
@@ -0,0 +1,293 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	serAddress "github.com/Salvionied/apollo/serialization/Address"
+	"github.com/Salvionied/apollo/serialization/NativeScript"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/bursa"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// Size of a vkey witness in a TX: a 2 item array holding a 32 byte key and
+// a 64 byte signature
+const vkeyWitnessSize = 1 + 2 + 32 + 2 + 64
+
+// Treasury is an M-of-N native script address that rewards are paid from
+// instead of the wallet address
+type Treasury struct {
+	Script NativeScript.NativeScript
+	// Payment key hashes of the cosigners
+	KeyHashes [][]byte
+	// Number of cosigner signatures required
+	Required int
+	Address  string
+}
+
+// NewTreasury returns the treasury requiring the specified number of
+// signatures from the cosigners with the provided hex-encoded payment key
+// hashes
+func NewTreasury(
+	keys []string,
+	required int,
+	network string,
+) (*Treasury, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no cosigner keys provided")
+	}
+	if required < 1 || required > len(keys) {
+		return nil, fmt.Errorf(
+			"required signatures must be between 1 and %d, got %d",
+			len(keys),
+			required,
+		)
+	}
+	ret := &Treasury{
+		Required: required,
+	}
+	scripts := make([]NativeScript.NativeScript, 0, len(keys))
+	for _, key := range keys {
+		keyHash, err := hex.DecodeString(key)
+		if err != nil || len(keyHash) != lcommon.AddressHashSize {
+			return nil, fmt.Errorf("invalid cosigner key hash: %s", key)
+		}
+		if ret.IsCosigner(keyHash) {
+			return nil, fmt.Errorf("duplicate cosigner key hash: %s", key)
+		}
+		ret.KeyHashes = append(ret.KeyHashes, keyHash)
+		scripts = append(scripts, NativeScript.NewScriptPubKey(keyHash))
+	}
+	ret.Script = NativeScript.NewScriptNofK(scripts, required)
+	ouroborosNetwork, ok := ouroboros.NetworkByName(network)
+	if !ok {
+		return nil, fmt.Errorf("unknown network: %s", network)
+	}
+	scriptHash, err := ret.Script.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash treasury script: %w", err)
+	}
+	addr, err := lcommon.NewAddressFromParts(
+		lcommon.AddressTypeScriptNone,
+		ouroborosNetwork.Id,
+		scriptHash[:],
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build treasury address: %w", err)
+	}
+	ret.Address = addr.String()
+	return ret, nil
+}
+
+// GetTreasury returns the configured treasury, or nil if rewards are paid
+// from the wallet address
+func GetTreasury() (*Treasury, error) {
+	cfg := config.GetConfig()
+	if len(cfg.Treasury.Keys) == 0 {
+		return nil, nil
+	}
+	ret, err := NewTreasury(
+		cfg.Treasury.Keys,
+		cfg.Treasury.Required,
+		cfg.Network,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid treasury config: %w", err)
+	}
+	return ret, nil
+}
+
+// Cbor returns the CBOR encoding of the treasury script
+func (t *Treasury) Cbor() ([]byte, error) {
+	return t.Script.MarshalCBOR()
+}
+
+// IsCosigner returns whether the payment key hash belongs to a cosigner
+func (t *Treasury) IsCosigner(keyHash []byte) bool {
+	return slices.ContainsFunc(t.KeyHashes, func(k []byte) bool {
+		return bytes.Equal(k, keyHash)
+	})
+}
+
+// Signers returns the payment key hashes of the cosigners that signed the TX
+func (t *Treasury) Signers(tx *PartialTx) [][]byte {
+	var ret [][]byte
+	for _, keyHash := range tx.SignerKeyHashes() {
+		if t.IsCosigner(keyHash) {
+			ret = append(ret, keyHash)
+		}
+	}
+	return ret
+}
+
+// TrimSignatures drops the cosigner signatures beyond the required number
+// from the TX, since its fee only pays for the required ones, and returns the
+// number of signatures dropped
+func (t *Treasury) TrimSignatures(tx *PartialTx) int {
+	count := len(tx.witnesses)
+	var cosigners int
+	tx.witnesses = slices.DeleteFunc(
+		tx.witnesses,
+		func(witness lcommon.VkeyWitness) bool {
+			keyHash := lcommon.Blake2b224Hash(witness.Vkey)
+			if !t.IsCosigner(keyHash.Bytes()) {
+				return false
+			}
+			cosigners++
+			return cosigners > t.Required
+		},
+	)
+	return count - len(tx.witnesses)
+}
+
+// rewardSourceAddress returns the address that rewards are paid from, which
+// is the treasury address if one is configured
+func rewardSourceAddress(w *bursa.Wallet) (string, error) {
+	treasury, err := GetTreasury()
+	if err != nil {
+		return "", err
+	}
+	if treasury != nil {
+		return treasury.Address, nil
+	}
+	return w.PaymentAddress, nil
+}
+
+// walletKeyHash returns the payment key hash of the wallet address
+func walletKeyHash(w *bursa.Wallet) ([]byte, error) {
+	addr, err := serAddress.DecodeAddress(w.PaymentAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode wallet address: %w", err)
+	}
+	return addr.PaymentPart, nil
+}
+
+// CheckSigner makes sure that the signer can spend the reward UTxOs. Without
+// a treasury it needs to hold the wallet payment key. With a treasury it
+// should be one of the cosigners, or every reward TX is left for the
+// cosigners to sign
+func CheckSigner(w *bursa.Wallet, s signer.Signer) error {
+	treasury, err := GetTreasury()
+	if err != nil {
+		return err
+	}
+	keyHash := signer.KeyHash(s)
+	if treasury != nil {
+		if !treasury.IsCosigner(keyHash) {
+			slog.Warn(
+				fmt.Sprintf(
+					"signer key %s is not a treasury cosigner, so all reward TXs will need to be signed by the cosigners",
+					hex.EncodeToString(keyHash),
+				),
+			)
+		}
+		return nil
+	}
+	walletHash, err := walletKeyHash(w)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(keyHash, walletHash) {
+		return fmt.Errorf(
+			"signer key %s does not match wallet address %s, configure a treasury to pay rewards from an address controlled by the signer",
+			hex.EncodeToString(keyHash),
+			w.PaymentAddress,
+		)
+	}
+	return nil
+}
+
+// needsSignatures returns whether a reward TX is a treasury TX that doesn't
+// have enough cosigner signatures yet
+func needsSignatures(txBytes []byte) (bool, error) {
+	treasury, err := GetTreasury()
+	if err != nil || treasury == nil {
+		return false, err
+	}
+	tx, err := DecodePartialTx(txBytes)
+	if err != nil {
+		return false, err
+	}
+	return len(treasury.Signers(tx)) < treasury.Required, nil
+}
+
+// pendingTxPath returns the path that a partially signed treasury TX is
+// exported to
+func pendingTxPath(txId string) string {
+	cfg := config.GetConfig()
+	return filepath.Join(cfg.Treasury.PendingDir, txId+".json")
+}
+
+// exportPendingTx writes a treasury TX that needs more signatures to the
+// pending directory, so that the cosigners can sign it
+func exportPendingTx(
+	treasury *Treasury,
+	txId string,
+	txBytes []byte,
+) error {
+	cfg := config.GetConfig()
+	tx, err := DecodePartialTx(txBytes)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.Treasury.PendingDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create pending TX directory: %w", err)
+	}
+	path := pendingTxPath(txId)
+	if err := WriteTxFile(path, txBytes); err != nil {
+		return err
+	}
+	slog.Warn(
+		fmt.Sprintf(
+			"treasury TX %s has %d of %d required signatures, exported to %s for the cosigners to sign",
+			txId,
+			len(treasury.Signers(tx)),
+			treasury.Required,
+			path,
+		),
+	)
+	return nil
+}
+
+// HandleTreasuryTxStatus removes the exported copy of a treasury TX once it
+// has reached a final state
+func HandleTreasuryTxStatus(tx storage.Transaction) {
+	if !tx.Resolved() {
+		return
+	}
+	path := pendingTxPath(tx.TxId)
+	if err := os.Remove(path); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn(
+				fmt.Sprintf("failed to remove %s: %s", path, err),
+			)
+		}
+		return
+	}
+	slog.Debug("removed pending treasury TX " + path)
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/chain"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+)
+
+// Slot of the latest block seen by the indexer
+var tipSlot atomic.Uint64
+
+// SetTipSlot records the slot of the latest block seen by the indexer
+func SetTipSlot(slot uint64) {
+	tipSlot.Store(slot)
+}
+
+// currentSlot returns the current slot. The indexer tip is used unless it's
+// behind the wall clock, such as while catching up after a restart
+func currentSlot() uint64 {
+	cfg := config.GetConfig()
+	ret := tipSlot.Load()
+	if clockSlot, err := chain.TimeToSlot(cfg.Network, time.Now()); err == nil {
+		ret = max(ret, clockSlot)
+	}
+	return ret
+}
+
+// rewardTtl returns the TTL for a new reward TX, or 0 if the TX shouldn't
+// expire. Treasury TXs use a separate TTL, which gives the cosigners time to
+// sign them. The TTL can't be later than the lock slot of the minting policy,
+// if any
+func rewardTtl(lockSlot uint64, treasury bool) uint64 {
+	cfg := config.GetConfig()
+	ttlSlots := cfg.TxBuilder.TtlSlots
+	if treasury {
+		ttlSlots = cfg.Treasury.TtlSlots
+	}
+	var ret uint64
+	if ttlSlots > 0 {
+		if slot := currentSlot(); slot > 0 {
+			ret = slot + ttlSlots
+		}
+	}
+	if lockSlot > 0 && (ret == 0 || ret > lockSlot) {
+		ret = lockSlot
+	}
+	return ret
+}
+
+// ttlTime returns the wall-clock time after which a TX with the specified TTL
+// can no longer be added to the chain
+func ttlTime(ttl uint64) (time.Time, error) {
+	cfg := config.GetConfig()
+	return chain.SlotToTime(cfg.Network, ttl)
+}
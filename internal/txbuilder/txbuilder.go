@@ -15,32 +15,29 @@
 package txbuilder
 
 import (
-	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
-	"slices"
 
 	"github.com/Salvionied/apollo"
-	"github.com/Salvionied/apollo/constants"
-	serAddress "github.com/Salvionied/apollo/serialization/Address"
-	"github.com/Salvionied/apollo/serialization/Asset"
-	"github.com/Salvionied/apollo/serialization/AssetName"
 	"github.com/Salvionied/apollo/serialization/Key"
-	"github.com/Salvionied/apollo/serialization/MultiAsset"
-	"github.com/Salvionied/apollo/serialization/Policy"
 	"github.com/Salvionied/apollo/serialization/Transaction"
-	"github.com/Salvionied/apollo/serialization/TransactionInput"
-	"github.com/Salvionied/apollo/serialization/TransactionOutput"
 	"github.com/Salvionied/apollo/serialization/UTxO"
-	"github.com/Salvionied/apollo/serialization/Value"
-	"github.com/Salvionied/apollo/txBuilding/Backend/BlockFrostChainContext"
-	"github.com/SundaeSwap-finance/kugo"
+	"github.com/Salvionied/apollo/serialization/VerificationKeyWitness"
 	"github.com/blinklabs-io/adder/event"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/backend"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
-	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/txsubmit"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/rules"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+	"github.com/blinklabs-io/bursa"
+)
+
+var (
+	ErrInsufficientAssets = errors.New("insufficient assets in wallet")
+	ErrTxTooLarge         = errors.New("transaction too large")
 )
 
 func HandleEvent(evt event.Event) error {
@@ -52,44 +49,62 @@ func HandleEvent(evt event.Event) error {
 	}
 	eventTx := evt.Payload.(event.TransactionEvent)
 	eventCtx := evt.Context.(event.TransactionContext)
+	// Release any UTxO reservation for our own TX, since it's now confirmed
+	GetReservations().Release(eventCtx.TransactionHash)
+	// Skip further processing if we've already handled this transaction
+	existingReward, err := storage.GetStorage().GetReward(
+		eventCtx.TransactionHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to lookup reward ledger: %w", err)
+	}
+	if existingReward != nil &&
+		existingReward.Status != storage.RewardStatusFailed {
+		slog.Info(
+			fmt.Sprintf(
+				"skipping further processing: TX %s already in reward ledger (%s)",
+				eventCtx.TransactionHash,
+				existingReward.Status,
+			),
+		)
+		return nil
+	}
 	// Determine source address from TX inputs
-	// NOTE: this assumes only 1 input
+	// NOTE: this uses the address of the first input that can be resolved
 	inputAddr := "(unknown)"
-	for _, txInput := range eventTx.Inputs {
-		utxo, err := getUtxoByRef(
-			txInput.Id().String(),
-			int(txInput.Index()),
-		)
-		if err != nil {
-			slog.Warn(
-				fmt.Sprintf(
-					"failed to lookup TX input ref: %s", err,
-				),
-			)
-			continue
-		}
-		if utxo == nil {
-			slog.Warn(
+	resolvedInputs, heldAssets := resolveInputs(eventTx)
+	if len(resolvedInputs) > 0 {
+		inputAddr = resolvedInputs[0].address
+	}
+	// Log amounts to our addresses. Deposits to a receive address are
+	// evaluated against that address instead of the wallet address, and
+	// deposits to a customer deposit address are attributed to the customer
+	// rather than the input address
+	depositAddr := w.PaymentAddress
+	var customerId string
+	for _, txOutput := range eventTx.Outputs {
+		txOutAddr := txOutput.Address().String()
+		if customerDepositAddr, ok := wallet.LookupDepositAddress(txOutAddr); ok {
+			if customerId == "" {
+				depositAddr = txOutAddr
+				customerId = customerDepositAddr.CustomerId
+			}
+			slog.Info(
 				fmt.Sprintf(
-					"could not lookup TX input ref %s#%d in backend (wrong network?)",
-					txInput.Id().String(),
-					txInput.Index(),
+					"received TX %s: customer %s -> %s (%d lovelace)",
+					eventCtx.TransactionHash,
+					customerDepositAddr.CustomerId,
+					txOutAddr,
+					txOutput.Amount(),
 				),
 			)
 			continue
 		}
-		if utxo.Output.IsPostAlonzo {
-			inputAddr = utxo.Output.PostAlonzo.Address.String()
-		} else {
-			inputAddr = utxo.Output.PreAlonzo.Address.String()
+		if customerId == "" && txOutAddr != w.PaymentAddress &&
+			wallet.IsWalletAddress(txOutAddr) {
+			depositAddr = txOutAddr
 		}
-		break
-	}
-	// Add up amounts to our address
-	var totalOutputAmount uint64
-	for _, txOutput := range eventTx.Outputs {
-		txOutAddr := txOutput.Address().String()
-		if txOutAddr == w.PaymentAddress ||
+		if wallet.IsWalletAddress(txOutAddr) ||
 			txOutAddr == cfg.Reward.RewardAddress {
 			slog.Info(
 				fmt.Sprintf(
@@ -100,251 +115,526 @@ func HandleEvent(evt event.Event) error {
 					txOutput.Amount(),
 				),
 			)
-			if txOutAddr == w.PaymentAddress {
-				totalOutputAmount += txOutput.Amount()
-			}
 		}
 	}
+	// Skip further processing for our own transactions, such as the change
+	// from reward transactions
+	sourceAddr, err := rewardSourceAddress(w)
+	if err != nil {
+		return err
+	}
+	if wallet.IsWalletAddress(inputAddr) || inputAddr == sourceAddr {
+		slog.Debug("skipping further processing: transaction sent from our wallet")
+		return nil
+	}
 	// Skip further processing if there's no reward address defined
-	if cfg.Reward.RewardAddress == "" {
+	if cfg.Reward.PayoutMode == PayoutModeFixed &&
+		cfg.Reward.RewardAddress == "" {
 		slog.Warn("skipping further processing: no reward address defined")
 		return nil
 	}
-	// Skip further processing if transaction doesn't come from the configured source address
-	if cfg.Reward.SourceAddress != "" && inputAddr != cfg.Reward.SourceAddress {
-		slog.Warn("skipping further processing: source address doesn't match")
+	// Evaluate reward rules
+	deposit := rules.NewDeposit(
+		eventTx,
+		eventCtx,
+		depositAddr,
+		inputAddr,
+		heldAssets,
+	)
+	deposit.CustomerId = customerId
+	result := rules.GetEngine().Evaluate(deposit)
+	if cfg.Reward.DryRun {
+		slog.Info(
+			fmt.Sprintf(
+				"dry run: rule evaluation for TX %s:\n%s",
+				eventCtx.TransactionHash,
+				result.Explain(),
+			),
+		)
 		return nil
 	}
-	// Skip further processing if transaction output amount is below the reward threshold
-	if totalOutputAmount < cfg.Reward.MinLovelace {
+	slog.Debug(
+		fmt.Sprintf(
+			"rule evaluation for TX %s:\n%s",
+			eventCtx.TransactionHash,
+			result.Explain(),
+		),
+	)
+	// Skip further processing if no rule matched
+	if result.Match == nil {
 		slog.Warn(
-			"skipping further processing: total output amount is below reward minimum",
+			"skipping further processing: no reward rule matched",
 		)
 		return nil
 	}
-	// Build reward transaction
-	tx, err := BuildRewardTx()
+	// Determine where to send the reward
+	destAddr, err := payoutAddress(deposit, resolvedInputs)
 	if err != nil {
-		return err
+		slog.Warn(
+			fmt.Sprintf("skipping further processing: %s", err),
+		)
+		return nil
 	}
-	// Submit TX
-	txBytes, err := tx.Bytes()
+	// Record the reward in the ledger before doing anything else, so that
+	// we never pay out twice for the same transaction
+	payout := NewPayout(destAddr, result.Match.Reward)
+	reward := &storage.Reward{
+		TxHash:        eventCtx.TransactionHash,
+		Rule:          result.Match.Name,
+		Destination:   destAddr,
+		CustomerId:    customerId,
+		DepositAmount: deposit.Lovelace,
+		RewardAmount:  payout.Lovelace,
+		RewardAssets:  payout.Assets,
+		MintedAssets:  payout.Mint,
+	}
+	claimed, err := storage.GetStorage().ClaimReward(reward)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to update reward ledger: %w", err)
 	}
-	if err := txsubmit.SubmitTx(txBytes); err != nil {
-		return err
+	if !claimed {
+		slog.Info(
+			fmt.Sprintf(
+				"skipping further processing: TX %s already in reward ledger",
+				eventCtx.TransactionHash,
+			),
+		)
+		return nil
 	}
-	slog.Info(
-		fmt.Sprintf(
-			"submitted transaction %x",
-			tx.Id().Payload,
-		),
+	// Pay out the reward, batching it with others if enabled
+	if cfg.Batch.Window > 0 {
+		GetBatcher().Add(reward, payout)
+		return nil
+	}
+	return GetBatcher().process(
+		[]batchItem{
+			{
+				reward: reward,
+				payout: payout,
+			},
+		},
 	)
+}
+
+// Payout describes a reward payment to a single address
+type Payout struct {
+	Address  string
+	Lovelace uint64
+	// Native assets to send, keyed by unit (policy ID + hex asset name)
+	Assets map[string]uint64
+	// Tokens to mint under the wallet minting policy and send, keyed by hex
+	// asset name
+	Mint map[string]uint64
+}
+
+// NewPayout returns a Payout for the specified reward
+func NewPayout(destAddr string, reward rules.RewardSpec) Payout {
+	ret := Payout{
+		Address:  destAddr,
+		Lovelace: reward.Lovelace,
+	}
+	if len(reward.Assets) > 0 {
+		ret.Assets = make(map[string]uint64, len(reward.Assets))
+		for _, asset := range reward.Assets {
+			ret.Assets[asset.Unit()] += asset.Quantity
+		}
+	}
+	if len(reward.Mint) > 0 {
+		ret.Mint = make(map[string]uint64, len(reward.Mint))
+		for _, asset := range reward.Mint {
+			ret.Mint[asset.AssetName] += asset.Quantity
+		}
+	}
+	return ret
+}
+
+// units returns the payout assets in the format expected by apollo
+func (p Payout) units() ([]apollo.Unit, error) {
+	ret := make([]apollo.Unit, 0, len(p.Assets))
+	for unit, quantity := range p.Assets {
+		if len(unit) < 56 {
+			return nil, fmt.Errorf("invalid asset unit: %s", unit)
+		}
+		tmpUnit, err := newApolloUnit(unit[:56], unit[56:], quantity)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, tmpUnit)
+	}
+	return ret, nil
+}
+
+// mintUnits returns the tokens to mint in the format expected by apollo
+func (p Payout) mintUnits(policyId string) ([]apollo.Unit, error) {
+	ret := make([]apollo.Unit, 0, len(p.Mint))
+	for assetName, quantity := range p.Mint {
+		tmpUnit, err := newApolloUnit(policyId, assetName, quantity)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, tmpUnit)
+	}
+	return ret, nil
+}
+
+func newApolloUnit(
+	policyId string,
+	assetNameHex string,
+	quantity uint64,
+) (apollo.Unit, error) {
+	// apollo expects the raw asset name
+	assetName, err := hex.DecodeString(assetNameHex)
+	if err != nil {
+		return apollo.Unit{}, fmt.Errorf(
+			"invalid asset name: %s",
+			assetNameHex,
+		)
+	}
+	return apollo.Unit{
+		PolicyId: policyId,
+		Name:     string(assetName),
+		Quantity: int(quantity), // #nosec G115
+	}, nil
+}
+
+// checkAssets makes sure that the provided UTxOs hold enough of each asset
+// in the payouts
+func checkAssets(utxos []UTxO.UTxO, payouts []Payout) error {
+	required := make(map[string]uint64)
+	for _, payout := range payouts {
+		for unit, quantity := range payout.Assets {
+			required[unit] += quantity
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+	available := make(map[string]uint64)
+	for _, utxo := range utxos {
+		addUtxoAssets(available, utxo)
+	}
+	for unit, quantity := range required {
+		if available[unit] < quantity {
+			return fmt.Errorf(
+				"%w: need %d of %s, have %d",
+				ErrInsufficientAssets,
+				quantity,
+				unit,
+				available[unit],
+			)
+		}
+	}
 	return nil
 }
 
-func BuildRewardTx() (*Transaction.Transaction, error) {
-	var err error
-	cfg := config.GetConfig()
+// BuildRewardTx builds and signs a transaction sending the payouts from the
+// wallet, or from the treasury if one is configured. The Lovelace amount for
+// each payout is raised to the minimum UTxO value for its output if necessary
+func BuildRewardTx(payouts ...Payout) (*Transaction.Transaction, error) {
 	w := wallet.GetWallet()
 	if w == nil {
 		return nil, errors.New("cannot initialize wallet")
 	}
-	cc := apollo.NewEmptyBackend()
-	apollob := apollo.New(&cc)
-	apollob, err = apollob.
-		SetWalletFromBech32(w.PaymentAddress).
-		SetWalletAsChangeAddress()
+	s := signer.GetSigner()
+	if s == nil {
+		return nil, errors.New("no signer configured")
+	}
+	sourceAddr, err := rewardSourceAddress(w)
 	if err != nil {
 		return nil, err
 	}
-
-	utxos, err := getUtxosByAddress(w.PaymentAddress)
+	utxos, err := getWalletUtxos(sourceAddr)
 	if err != nil {
 		return nil, err
 	}
-	apollob = apollob.AddLoadedUTxOs(utxos...)
+	return BuildRewardTxFromUtxos(w, s, utxos, payouts...)
+}
 
-	apollob = apollob.
-		PayToAddressBech32(
-			cfg.Reward.RewardAddress,
-			int(cfg.Reward.RewardAmount), // #nosec G115
-		)
-	tx, err := apollob.Complete()
+// BuildRewardTxFromUtxos builds a transaction sending the payouts using the
+// provided wallet or treasury UTxOs, and signs it with the signer. Treasury
+// TXs are only signed if the signer is one of the cosigners, and the other
+// cosigners need to sign them before they can be submitted. It doesn't make
+// any network requests other than fetching the protocol parameters if they
+// aren't cached, and reaching a remote signer
+func BuildRewardTxFromUtxos(
+	w *bursa.Wallet,
+	s signer.Signer,
+	utxos []UTxO.UTxO,
+	payouts ...Payout,
+) (*Transaction.Transaction, error) {
+	if len(payouts) == 0 {
+		return nil, errors.New("no payouts provided")
+	}
+	if err := checkAssets(utxos, payouts); err != nil {
+		return nil, err
+	}
+	cc, err := getChainContext()
 	if err != nil {
 		return nil, err
 	}
-	vKeyBytes, err := hex.DecodeString(w.PaymentVKey.CborHex)
+	pp, err := cc.GetProtocolParams()
 	if err != nil {
 		return nil, err
 	}
-	sKeyBytes, err := hex.DecodeString(w.PaymentExtendedSKey.CborHex)
+	treasury, err := GetTreasury()
 	if err != nil {
 		return nil, err
 	}
-	// Strip off leading 2 bytes as shortcut for CBOR decoding to unwrap bytes
-	vKeyBytes = vKeyBytes[2:]
-	sKeyBytes = sKeyBytes[2:]
-	// Strip out public key portion of extended private key
-	sKeyBytes = slices.Delete(sKeyBytes, 64, 96)
-	vkey := Key.VerificationKey{Payload: vKeyBytes}
-	skey := Key.SigningKey{Payload: sKeyBytes}
-	tx, err = tx.SignWithSkey(vkey, skey)
+	sourceAddr, err := rewardSourceAddress(w)
 	if err != nil {
 		return nil, err
 	}
-	return tx.GetTx(), nil
-}
-
-func getBlockfrostContext() (*BlockFrostChainContext.BlockFrostChainContext, error) {
-	cfg := config.GetConfig()
-	var ret BlockFrostChainContext.BlockFrostChainContext
-	var err error
-	switch cfg.Network {
-	case "preprod":
-		ret, err = BlockFrostChainContext.NewBlockfrostChainContext(
-			constants.BLOCKFROST_BASE_URL_PREPROD,
-			int(constants.PREPROD),
-			cfg.TxBuilder.BlockfrostApiKey,
-		)
+	apollob := apollo.New(cc)
+	apollob, err = apollob.
+		SetWalletFromBech32(sourceAddr).
+		SetWalletAsChangeAddress()
+	if err != nil {
+		return nil, err
+	}
+	apollob = apollob.AddLoadedUTxOs(utxos...)
+	// Like minting policies below, apollo doesn't account for the treasury
+	// script or the cosigner signatures when estimating the fee
+	var feePadding int64
+	if treasury != nil {
+		scriptCbor, err := treasury.Cbor()
 		if err != nil {
 			return nil, err
 		}
-	// TODO: add more networks
-	default:
-		return nil, fmt.Errorf("unsupported network: %s", cfg.Network)
+		feePadding += int64(len(scriptCbor)+treasury.Required*vkeyWitnessSize) *
+			pp.MinFeeCoefficient
+		apollob = apollob.SetFeePadding(feePadding)
 	}
-	return &ret, nil
-}
 
-func getKupoClient() (*kugo.Client, error) {
-	cfg := config.GetConfig()
-	if cfg.TxBuilder.KupoUrl == "" {
-		return nil, errors.New("no kupo url provided")
-	}
-	k := kugo.New(
-		kugo.WithEndpoint(cfg.TxBuilder.KupoUrl),
-	)
-	if k == nil {
-		return nil, fmt.Errorf("failed kupo client: %s", cfg.TxBuilder.KupoUrl)
+	// Mint tokens under the signer policy
+	var mintPolicy *MintPolicy
+	var policyId string
+	mintTotals := make(map[string]uint64)
+	for _, payout := range payouts {
+		for assetName, quantity := range payout.Mint {
+			mintTotals[assetName] += quantity
+		}
 	}
-	return k, nil
-}
-
-func getUtxosByAddress(addr string) ([]UTxO.UTxO, error) {
-	cfg := config.GetConfig()
-	if cfg.TxBuilder.BlockfrostApiKey != "" {
-		bfc, err := getBlockfrostContext()
+	if len(mintTotals) > 0 {
+		mintPolicy, err = NewMintPolicy(
+			signer.KeyHash(s),
+			config.GetConfig().Mint.LockSlot,
+		)
 		if err != nil {
 			return nil, err
 		}
-		serAddr, err := serAddress.DecodeAddress(addr)
+		policyId, err = mintPolicy.PolicyId()
 		if err != nil {
 			return nil, err
 		}
-		utxos, err := bfc.Utxos(serAddr)
+		mintUnits, err := Payout{Mint: mintTotals}.mintUnits(policyId)
 		if err != nil {
 			return nil, err
 		}
-		return utxos, nil
-	} else if cfg.TxBuilder.KupoUrl != "" {
-		k, err := getKupoClient()
+		for _, mintUnit := range mintUnits {
+			apollob = apollob.MintAssets(mintUnit)
+		}
+		// apollo doesn't account for native scripts when estimating the
+		// fee, since it has no way to attach them, so we pad the fee by the
+		// size of the script
+		scriptCbor, err := mintPolicy.Cbor()
 		if err != nil {
 			return nil, err
 		}
-		matches, err := k.Matches(
-			context.Background(),
-			kugo.Pattern(addr),
+		feePadding += int64(len(scriptCbor)) * pp.MinFeeCoefficient
+		apollob = apollob.SetFeePadding(feePadding)
+	}
+
+	// Set a TTL, so that a TX stuck in a mempool can't land after we've given
+	// up on it
+	var lockSlot uint64
+	if mintPolicy != nil {
+		lockSlot = mintPolicy.LockSlot
+	}
+	if ttl := rewardTtl(lockSlot, treasury != nil); ttl > 0 {
+		apollob = apollob.SetTtl(
+			int64(ttl), // #nosec G115
 		)
+	}
+
+	for _, payout := range payouts {
+		units, err := payout.units()
 		if err != nil {
 			return nil, err
 		}
-		var ret []UTxO.UTxO
-		for _, match := range matches {
-			tmpUtxo := kupoMatchToApolloUtxo(match)
-			ret = append(ret, tmpUtxo)
+		if len(payout.Mint) > 0 {
+			mintUnits, err := payout.mintUnits(policyId)
+			if err != nil {
+				return nil, err
+			}
+			units = append(units, mintUnits...)
 		}
-		return ret, nil
+		apollob = apollob.
+			PayToAddressBech32(
+				payout.Address,
+				int(payout.Lovelace), // #nosec G115
+				units...,
+			)
 	}
-	return nil, errors.New("no valid Blockfrost or Kupo/Ogmios config found")
-}
-
-func getUtxoByRef(txId string, idx int) (*UTxO.UTxO, error) {
-	cfg := config.GetConfig()
-	if cfg.TxBuilder.BlockfrostApiKey != "" {
-		bfc, err := getBlockfrostContext()
-		if err != nil {
-			return nil, err
+	tx, err := apollob.Complete()
+	if err != nil {
+		// apollo doesn't provide an error type for this
+		if err.Error() == "transaction too large" {
+			return nil, ErrTxTooLarge
 		}
-		utxo, err := bfc.GetUtxoFromRef(txId, idx)
-		if err != nil {
-			return nil, err
+		return nil, err
+	}
+	witnessSet := tx.GetTx().TransactionWitnessSet
+	if treasury != nil {
+		witnessSet.NativeScripts = append(
+			witnessSet.NativeScripts,
+			treasury.Script,
+		)
+	}
+	if mintPolicy != nil {
+		witnessSet.NativeScripts = append(
+			witnessSet.NativeScripts,
+			mintPolicy.Script,
+		)
+	}
+	tx.GetTx().TransactionWitnessSet = witnessSet
+	// Treasury TXs only need the signer signature if it's a cosigner, or for
+	// minting under the signer policy
+	signs := treasury == nil || mintPolicy != nil
+	var missingSigs int
+	if treasury != nil {
+		missingSigs = treasury.Required
+		if treasury.IsCosigner(signer.KeyHash(s)) {
+			signs = true
+			missingSigs--
 		}
-		return utxo, nil
-	} else if cfg.TxBuilder.KupoUrl != "" {
-		k, err := getKupoClient()
-		if err != nil {
+	}
+	if signs {
+		if err := signTx(tx.GetTx(), s); err != nil {
 			return nil, err
 		}
-		matches, err := k.Matches(
-			context.Background(),
-			kugo.Pattern(
-				fmt.Sprintf("%d@%s", idx, txId),
-			),
+	}
+	// Check the final size, since the native scripts and the signatures still
+	// to be added by the cosigners aren't accounted for by apollo
+	txBytes, err := tx.GetTx().Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(txBytes)+missingSigs*vkeyWitnessSize > pp.MaxTxSize {
+		return nil, ErrTxTooLarge
+	}
+	return tx.GetTx(), nil
+}
+
+// resolveInputs returns the outputs spent by the TX inputs, along with the
+// native assets they held. The inputs resolved by adder are used when
+// available, since they've already been spent and backends such as the local
+// UTxO set or Ogmios only know about unspent outputs. The chain backend is
+// only used as a fallback
+func resolveInputs(
+	eventTx event.TransactionEvent,
+) ([]resolvedInput, map[string]uint64) {
+	heldAssets := make(map[string]uint64)
+	var ret []resolvedInput
+	if len(eventTx.ResolvedInputs) > 0 {
+		for _, txOutput := range eventTx.ResolvedInputs {
+			rules.AddAssets(heldAssets, txOutput.Assets())
+			ret = append(
+				ret,
+				resolvedInput{
+					address:  txOutput.Address().String(),
+					lovelace: txOutput.Amount(),
+				},
+			)
+		}
+		return ret, heldAssets
+	}
+	for _, txInput := range eventTx.Inputs {
+		utxo, err := getUtxoByRef(
+			txInput.Id().String(),
+			int(txInput.Index()),
 		)
 		if err != nil {
-			return nil, err
+			slog.Warn(
+				fmt.Sprintf(
+					"failed to lookup TX input ref: %s", err,
+				),
+			)
+			continue
 		}
-		if len(matches) == 0 {
+		if utxo == nil {
 			slog.Warn(
 				fmt.Sprintf(
-					"could not lookup TX input ref %d@%s in kupo (wrong network?)",
-					idx,
-					txId,
+					"could not lookup TX input ref %s#%d in backend (wrong network?)",
+					txInput.Id().String(),
+					txInput.Index(),
 				),
 			)
-			return nil, nil
+			continue
+		}
+		addUtxoAssets(heldAssets, *utxo)
+		var utxoAddr string
+		if utxo.Output.IsPostAlonzo {
+			utxoAddr = utxo.Output.PostAlonzo.Address.String()
+		} else {
+			utxoAddr = utxo.Output.PreAlonzo.Address.String()
 		}
-		ret := kupoMatchToApolloUtxo(matches[0])
-		return &ret, nil
+		ret = append(
+			ret,
+			resolvedInput{
+				address:  utxoAddr,
+				lovelace: uint64(utxo.Output.Lovelace()), // #nosec G115
+			},
+		)
+	}
+	return ret, heldAssets
+}
+
+func getUtxosByAddress(addr string) ([]UTxO.UTxO, error) {
+	b := backend.GetBackend()
+	if b == nil {
+		return nil, errors.New("no chain backend configured")
 	}
-	return nil, errors.New("no valid Blockfrost or Kupo/Ogmios config found")
+	return b.UtxosByAddress(addr)
 }
 
-func kupoMatchToApolloUtxo(match kugo.Match) UTxO.UTxO {
-	serAddr, _ := serAddress.DecodeAddress(match.Address)
-	txIdBytes, _ := hex.DecodeString(match.TransactionID)
-	multiAssets := make(MultiAsset.MultiAsset[int64])
-	totalLovelace := uint64(0)
-	for policyId, assets := range match.Value {
-		for assetId, assetAmount := range assets {
-			if policyId == "ada" && assetId == "lovelace" {
-				totalLovelace = assetAmount.Uint64()
+func getUtxoByRef(txId string, idx int) (*UTxO.UTxO, error) {
+	b := backend.GetBackend()
+	if b == nil {
+		return nil, errors.New("no chain backend configured")
+	}
+	return b.UtxoByRef(txId, idx)
+}
+
+// addUtxoAssets adds the native assets in a UTxO to the provided map, keyed
+// by unit (policy ID + hex asset name)
+func addUtxoAssets(dest map[string]uint64, utxo UTxO.UTxO) {
+	for policyId, assets := range utxo.Output.GetValue().GetAssets() {
+		for assetName, amount := range assets {
+			if amount <= 0 {
 				continue
 			}
-			tmpPolicyId := Policy.PolicyId{Value: policyId}
-			tmpAssetName := AssetName.NewAssetNameFromString(assetId)
-			if _, ok := multiAssets[tmpPolicyId]; !ok {
-				multiAssets[tmpPolicyId] = Asset.Asset[int64]{}
-			}
-			multiAssets[tmpPolicyId][tmpAssetName] = assetAmount.Int64()
+			dest[policyId.Value+assetName.HexString()] += uint64(amount) // #nosec G115
 		}
 	}
-	val := Value.SimpleValue(
-		// all the lovelace wouldn't overflow this
-		int64(totalLovelace), // #nosec G115
-		multiAssets,
-	)
-	ret := UTxO.UTxO{
-		Input: TransactionInput.TransactionInput{
-			TransactionId: txIdBytes,
-			Index:         match.OutputIndex,
-		},
-		Output: TransactionOutput.SimpleTransactionOutput(
-			serAddr,
-			val,
-		),
+}
+
+// signTx adds a signature from the signer to the TX
+func signTx(tx *Transaction.Transaction, s signer.Signer) error {
+	// This is the encoding that the TX hash is calculated from
+	txBody, err := tx.TransactionBody.MarshalCBOR()
+	if err != nil {
+		return err
 	}
-	return ret
+	signature, err := s.Sign(txBody)
+	if err != nil {
+		return fmt.Errorf("failed to sign TX: %w", err)
+	}
+	tx.TransactionWitnessSet.VkeyWitnesses = append(
+		tx.TransactionWitnessSet.VkeyWitnesses,
+		VerificationKeyWitness.VerificationKeyWitness{
+			Vkey:      Key.VerificationKey{Payload: s.PublicKey()},
+			Signature: signature,
+		},
+	)
+	return nil
 }
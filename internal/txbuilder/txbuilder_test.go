@@ -0,0 +1,437 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txbuilder
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	serAddress "github.com/Salvionied/apollo/serialization/Address"
+	"github.com/Salvionied/apollo/serialization/Transaction"
+	"github.com/Salvionied/apollo/serialization/TransactionInput"
+	"github.com/Salvionied/apollo/serialization/TransactionOutput"
+	"github.com/Salvionied/apollo/serialization/UTxO"
+	"github.com/Salvionied/apollo/serialization/Value"
+	"github.com/blinklabs-io/adder/event"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/backend"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/rules"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/signer"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/wallet"
+	"github.com/blinklabs-io/bursa"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/blinklabs-io/gouroboros/ledger/shelley"
+	"golang.org/x/crypto/blake2b"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art"
+
+// setupTest configures the wallet and signer from the test mnemonic, opens an
+// empty database and replaces the chain backend with an in-memory one
+func setupTest(t *testing.T) (*bursa.Wallet, signer.Signer, *backend.Memory) {
+	t.Helper()
+	cfg := config.GetConfig()
+	cfg.Network = "preview"
+	cfg.Wallet.Mnemonic = testMnemonic
+	cfg.Storage.Directory = t.TempDir()
+	if err := storage.GetStorage().Load(); err != nil {
+		t.Fatalf("failed to load storage: %s", err)
+	}
+	t.Cleanup(func() {
+		storage.GetStorage().Close()
+	})
+	w, err := wallet.Setup()
+	if err != nil {
+		t.Fatalf("failed to set up wallet: %s", err)
+	}
+	s, err := signer.Setup()
+	if err != nil {
+		t.Fatalf("failed to set up signer: %s", err)
+	}
+	mem := backend.NewMemory()
+	backend.SetBackend(mem)
+	// Don't reuse state from other tests
+	globalProtocolParams = &ProtocolParams{}
+	globalReservations = &Reservations{
+		reservations: make(map[string]*reservation),
+	}
+	t.Cleanup(func() {
+		backend.SetBackend(nil)
+		globalProtocolParams = &ProtocolParams{}
+	})
+	return w, s, mem
+}
+
+// testAddress returns an enterprise address for a payment key hash made of
+// the provided byte
+func testAddress(t *testing.T, b byte) string {
+	t.Helper()
+	addr, err := lcommon.NewAddressFromParts(
+		lcommon.AddressTypeKeyNone,
+		0,
+		bytes.Repeat([]byte{b}, lcommon.AddressHashSize),
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to build address: %s", err)
+	}
+	return addr.String()
+}
+
+// testUtxo returns a UTxO holding only Lovelace at the address
+func testUtxo(t *testing.T, addr string, txId byte, lovelace int64) UTxO.UTxO {
+	t.Helper()
+	decodedAddr, err := serAddress.DecodeAddress(addr)
+	if err != nil {
+		t.Fatalf("failed to decode address: %s", err)
+	}
+	return UTxO.UTxO{
+		Input: TransactionInput.TransactionInput{
+			TransactionId: bytes.Repeat([]byte{txId}, 32),
+			Index:         0,
+		},
+		Output: TransactionOutput.SimpleTransactionOutput(
+			decodedAddr,
+			Value.PureLovelaceValue(lovelace),
+		),
+	}
+}
+
+// outputsTo returns the TX outputs paying to the address
+func outputsTo(
+	tx *Transaction.Transaction,
+	addr string,
+) []TransactionOutput.TransactionOutput {
+	var ret []TransactionOutput.TransactionOutput
+	for _, output := range tx.TransactionBody.Outputs {
+		if output.GetAddress().String() == addr {
+			ret = append(ret, output)
+		}
+	}
+	return ret
+}
+
+// decodeTx decodes the TX CBOR with gouroboros, which is stricter than apollo
+func decodeTx(t *testing.T, txBytes []byte) lcommon.Transaction {
+	t.Helper()
+	txType, err := ledger.DetermineTransactionType(txBytes)
+	if err != nil {
+		t.Fatalf("failed to determine TX type: %s", err)
+	}
+	tx, err := ledger.NewTransactionFromCbor(txType, txBytes)
+	if err != nil {
+		t.Fatalf("failed to decode TX: %s", err)
+	}
+	return tx
+}
+
+// checkSignature makes sure that the TX is signed by the signer, over the
+// same bytes that the TX ID is calculated from
+func checkSignature(t *testing.T, tx *Transaction.Transaction, s signer.Signer) {
+	t.Helper()
+	txBody, err := tx.TransactionBody.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("failed to encode TX body: %s", err)
+	}
+	bodyHash := blake2b.Sum256(txBody)
+	if !bytes.Equal(bodyHash[:], tx.Id().Payload) {
+		t.Fatalf("TX body hash doesn't match the TX ID")
+	}
+	for _, witness := range tx.TransactionWitnessSet.VkeyWitnesses {
+		if !bytes.Equal(witness.Vkey.Payload, s.PublicKey()) {
+			continue
+		}
+		if !ed25519.Verify(s.PublicKey(), bodyHash[:], witness.Signature) {
+			t.Fatalf("invalid signature from signer")
+		}
+		return
+	}
+	t.Fatalf("TX isn't signed by the signer")
+}
+
+func TestBuildRewardTx(t *testing.T) {
+	w, s, mem := setupTest(t)
+	destAddr := testAddress(t, 0x01)
+	walletUtxo := testUtxo(t, w.PaymentAddress, 0x10, 100_000_000)
+	mem.AddUtxos(
+		walletUtxo,
+		// UTxOs at other addresses are never spent
+		testUtxo(t, testAddress(t, 0x02), 0x11, 500_000_000),
+	)
+	tx, err := BuildRewardTx(NewPayout(destAddr, rules.RewardSpec{
+		Lovelace: 5_000_000,
+	}))
+	if err != nil {
+		t.Fatalf("failed to build reward TX: %s", err)
+	}
+	inputs := tx.TransactionBody.Inputs
+	if len(inputs) != 1 || !inputs[0].EqualTo(walletUtxo.Input) {
+		t.Fatalf("expected the wallet UTxO as the only input, got: %v", inputs)
+	}
+	payouts := outputsTo(tx, destAddr)
+	if len(payouts) != 1 || payouts[0].Lovelace() != 5_000_000 {
+		t.Fatalf("expected a single 5 ADA output to %s", destAddr)
+	}
+	change := outputsTo(tx, w.PaymentAddress)
+	if len(change) != 1 {
+		t.Fatalf("expected a single change output to the wallet")
+	}
+	total := change[0].Lovelace() + payouts[0].Lovelace() +
+		tx.TransactionBody.Fee
+	if total != 100_000_000 {
+		t.Fatalf("inputs and outputs don't balance: %d", total)
+	}
+	if tx.TransactionBody.Ttl == 0 {
+		t.Fatalf("expected a TTL")
+	}
+	checkSignature(t, tx, s)
+	// The wallet doesn't hold the asset
+	_, err = BuildRewardTx(
+		Payout{
+			Address:  destAddr,
+			Lovelace: 2_000_000,
+			Assets: map[string]uint64{
+				fmt.Sprintf("%056x", 1) + hex.EncodeToString([]byte("token")): 1,
+			},
+		},
+	)
+	if !errors.Is(err, ErrInsufficientAssets) {
+		t.Fatalf("expected insufficient assets error, got: %v", err)
+	}
+}
+
+// testSubmitApi is a submit API that accepts every TX, unless told to respond
+// with another status
+type testSubmitApi struct {
+	mutex  sync.Mutex
+	txs    [][]byte
+	status int
+}
+
+// startSubmitApi submits TXs to a new test submit API
+func startSubmitApi(t *testing.T) *testSubmitApi {
+	t.Helper()
+	api := &testSubmitApi{}
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var buf bytes.Buffer
+			_, _ = buf.ReadFrom(r.Body)
+			api.mutex.Lock()
+			status := api.status
+			if status == 0 {
+				status = http.StatusAccepted
+				api.txs = append(api.txs, buf.Bytes())
+			}
+			api.mutex.Unlock()
+			w.WriteHeader(status)
+		}),
+	)
+	t.Cleanup(server.Close)
+	cfg := config.GetConfig()
+	cfg.Submit.Url = server.URL
+	t.Cleanup(func() { cfg.Submit.Url = "" })
+	return api
+}
+
+// setStatus makes the submit API respond with the specified status instead
+// of accepting TXs, or accept them again with 0
+func (a *testSubmitApi) setStatus(status int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.status = status
+}
+
+// submitted returns the TXs accepted so far
+func (a *testSubmitApi) submitted() [][]byte {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return slices.Clone(a.txs)
+}
+
+// depositEvent returns a TX event for a deposit from the source address to
+// the deposit address
+func depositEvent(
+	t *testing.T,
+	txHash string,
+	sourceAddr string,
+	depositAddr string,
+	lovelace uint64,
+) event.Event {
+	t.Helper()
+	decodeAddress := func(addr string) lcommon.Address {
+		ret, err := lcommon.NewAddress(addr)
+		if err != nil {
+			t.Fatalf("failed to decode address: %s", err)
+		}
+		return ret
+	}
+	return event.New(
+		"chainsync.transaction",
+		time.Time{},
+		event.TransactionContext{
+			BlockNumber:     100,
+			SlotNumber:      1000,
+			TransactionHash: txHash,
+		},
+		event.TransactionEvent{
+			Outputs: []ledger.TransactionOutput{
+				&shelley.ShelleyTransactionOutput{
+					OutputAddress: decodeAddress(depositAddr),
+					OutputAmount:  lovelace,
+				},
+			},
+			ResolvedInputs: []ledger.TransactionOutput{
+				&shelley.ShelleyTransactionOutput{
+					OutputAddress: decodeAddress(sourceAddr),
+					OutputAmount:  lovelace + 1_000_000,
+				},
+			},
+		},
+	)
+}
+
+func TestHandleEvent(t *testing.T) {
+	w, s, mem := setupTest(t)
+	cfg := config.GetConfig()
+	rewardAddr := testAddress(t, 0x01)
+	senderAddr := testAddress(t, 0x02)
+	cfg.Reward.RewardAddress = rewardAddr
+	cfg.Reward.MinLovelace = 50_000_000
+	cfg.Reward.RewardAmount = 5_000_000
+	if err := rules.GetEngine().Load(); err != nil {
+		t.Fatalf("failed to load rules: %s", err)
+	}
+	api := startSubmitApi(t)
+	testDefs := []struct {
+		name           string
+		txHash         string
+		sourceAddr     string
+		lovelace       uint64
+		expectedReward storage.RewardStatus
+	}{
+		{
+			name:           "deposit above minimum is rewarded",
+			txHash:         fmt.Sprintf("%064x", 1),
+			sourceAddr:     senderAddr,
+			lovelace:       60_000_000,
+			expectedReward: storage.RewardStatusSubmitted,
+		},
+		{
+			name:       "deposit below minimum is ignored",
+			txHash:     fmt.Sprintf("%064x", 2),
+			sourceAddr: senderAddr,
+			lovelace:   10_000_000,
+		},
+		{
+			name:       "change from our own TX is ignored",
+			txHash:     fmt.Sprintf("%064x", 3),
+			sourceAddr: w.PaymentAddress,
+			lovelace:   60_000_000,
+		},
+		{
+			name:           "same deposit is only rewarded once",
+			txHash:         fmt.Sprintf("%064x", 1),
+			sourceAddr:     senderAddr,
+			lovelace:       60_000_000,
+			expectedReward: storage.RewardStatusSubmitted,
+		},
+	}
+	mem.AddUtxos(testUtxo(t, w.PaymentAddress, 0x10, 100_000_000))
+	var expectedSubmitted int
+	for _, testDef := range testDefs {
+		prevReward, err := storage.GetStorage().GetReward(testDef.txHash)
+		if err != nil {
+			t.Fatalf("%s: failed to look up reward: %s", testDef.name, err)
+		}
+		if err := HandleEvent(
+			depositEvent(
+				t,
+				testDef.txHash,
+				testDef.sourceAddr,
+				w.PaymentAddress,
+				testDef.lovelace,
+			),
+		); err != nil {
+			t.Fatalf("%s: failed to handle event: %s", testDef.name, err)
+		}
+		reward, err := storage.GetStorage().GetReward(testDef.txHash)
+		if err != nil {
+			t.Fatalf("%s: failed to look up reward: %s", testDef.name, err)
+		}
+		if testDef.expectedReward == "" {
+			if reward != nil {
+				t.Fatalf("%s: unexpected reward: %+v", testDef.name, reward)
+			}
+			continue
+		}
+		if reward == nil || reward.Status != testDef.expectedReward {
+			t.Fatalf(
+				"%s: expected reward with status %s, got: %+v",
+				testDef.name,
+				testDef.expectedReward,
+				reward,
+			)
+		}
+		if prevReward == nil {
+			expectedSubmitted++
+		}
+		submitted := api.submitted()
+		if len(submitted) != expectedSubmitted {
+			t.Fatalf(
+				"%s: expected %d submitted TXs, got %d",
+				testDef.name,
+				expectedSubmitted,
+				len(submitted),
+			)
+		}
+		// The submitted TX pays the reward to the reward address
+		tx := decodeTx(t, submitted[len(submitted)-1])
+		if tx.Hash().String() != reward.RewardTxId {
+			t.Fatalf("%s: submitted TX doesn't match the reward", testDef.name)
+		}
+		var rewardOutputs int
+		for _, output := range tx.Outputs() {
+			if output.Address().String() != rewardAddr {
+				continue
+			}
+			rewardOutputs++
+			if output.Amount() != 5_000_000 {
+				t.Fatalf(
+					"%s: expected a 5 ADA reward, got %d lovelace",
+					testDef.name,
+					output.Amount(),
+				)
+			}
+		}
+		if rewardOutputs != 1 {
+			t.Fatalf("%s: expected a single reward output", testDef.name)
+		}
+		if len(tx.Witnesses().Vkey()) != 1 ||
+			!bytes.Equal(tx.Witnesses().Vkey()[0].Vkey, s.PublicKey()) {
+			t.Fatalf("%s: expected a signature from the signer", testDef.name)
+		}
+	}
+}
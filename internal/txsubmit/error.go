@@ -0,0 +1,47 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txsubmit
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTxExpired is returned when a TX is past its TTL and can no longer be
+// added to the chain
+var ErrTxExpired = errors.New("transaction expired")
+
+// TxRejectedError is returned when a node explicitly rejects a submitted
+// transaction. Reason contains the decoded ledger rejection, when available
+type TxRejectedError struct {
+	TxHash     string
+	Reason     error
+	ReasonCbor []byte
+}
+
+func (e *TxRejectedError) Error() string {
+	if e.Reason != nil {
+		return fmt.Sprintf("transaction %s rejected: %s", e.TxHash, e.Reason)
+	}
+	return fmt.Sprintf(
+		"transaction %s rejected: CBOR reason hex: %x",
+		e.TxHash,
+		e.ReasonCbor,
+	)
+}
+
+func (e *TxRejectedError) Unwrap() error {
+	return e.Reason
+}
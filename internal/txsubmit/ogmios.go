@@ -0,0 +1,160 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txsubmit
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SundaeSwap-finance/ogmigo/v6"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+const (
+	// Maximum time to wait for Ogmios to evaluate or submit a TX
+	ogmiosSubmitTimeout = 2 * time.Minute
+)
+
+// OgmiosError is an error returned by Ogmios in response to a request
+type OgmiosError struct {
+	Code    int
+	Message string
+	Data    []byte
+}
+
+func (e *OgmiosError) Error() string {
+	if len(e.Data) > 0 {
+		return fmt.Sprintf(
+			"ogmios error %d: %s: %s",
+			e.Code,
+			e.Message,
+			e.Data,
+		)
+	}
+	return fmt.Sprintf("ogmios error %d: %s", e.Code, e.Message)
+}
+
+// EvaluateTx evaluates the scripts in a TX via Ogmios evaluateTransaction and
+// returns the execution units for each redeemer
+func EvaluateTx(txBytes []byte) ([]ogmigo.ExUnits, error) {
+	cfg := config.GetConfig()
+	if cfg.Submit.OgmiosUrl == "" {
+		return nil, errors.New("no ogmios url configured")
+	}
+	tx, err := parseTx(txBytes)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateTxOgmios(cfg.Submit.OgmiosUrl, tx.Hash().String(), txBytes)
+}
+
+func submitTxOgmios(txBytes []byte) error {
+	cfg := config.GetConfig()
+	tx, err := parseTx(txBytes)
+	if err != nil {
+		return err
+	}
+	txHash := tx.Hash().String()
+	// Evaluate any scripts first, so that a failing script is reported
+	// instead of a generic ledger rejection
+	if hasRedeemers(tx) {
+		if _, err := evaluateTxOgmios(cfg.Submit.OgmiosUrl, txHash, txBytes); err != nil {
+			return err
+		}
+	}
+	client := ogmigo.New(
+		ogmigo.WithEndpoint(cfg.Submit.OgmiosUrl),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), ogmiosSubmitTimeout)
+	defer cancel()
+	resp, err := client.SubmitTx(ctx, hex.EncodeToString(txBytes))
+	if err != nil {
+		return fmt.Errorf("failed to submit TX: %w", err)
+	}
+	if resp.Error != nil {
+		return &TxRejectedError{
+			TxHash: txHash,
+			Reason: &OgmiosError{
+				Code:    resp.Error.Code,
+				Message: resp.Error.Message,
+				Data:    resp.Error.Data,
+			},
+		}
+	}
+	return nil
+}
+
+func evaluateTxOgmios(
+	ogmiosUrl string,
+	txHash string,
+	txBytes []byte,
+) ([]ogmigo.ExUnits, error) {
+	client := ogmigo.New(
+		ogmigo.WithEndpoint(ogmiosUrl),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), ogmiosSubmitTimeout)
+	defer cancel()
+	resp, err := client.EvaluateTx(ctx, hex.EncodeToString(txBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate TX: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, &TxRejectedError{
+			TxHash: txHash,
+			Reason: &OgmiosError{
+				Code:    resp.Error.Code,
+				Message: resp.Error.Message,
+				Data:    resp.Error.Data,
+			},
+		}
+	}
+	return resp.ExUnits, nil
+}
+
+func parseTx(txBytes []byte) (lcommon.Transaction, error) {
+	txType, err := ledger.DetermineTransactionType(txBytes)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"could not parse transaction to determine type: %w",
+			err,
+		)
+	}
+	tx, err := ledger.NewTransactionFromCbor(txType, txBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transaction CBOR: %w", err)
+	}
+	return tx, nil
+}
+
+// hasRedeemers returns whether the TX has any Plutus script redeemers
+func hasRedeemers(tx lcommon.Transaction) bool {
+	wits := tx.Witnesses()
+	if wits == nil {
+		return false
+	}
+	redeemers := wits.Redeemers()
+	if redeemers == nil {
+		return false
+	}
+	for range redeemers.Iter() {
+		return true
+	}
+	return false
+}
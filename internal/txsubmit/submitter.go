@@ -0,0 +1,450 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txsubmit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/txsubmission"
+)
+
+const (
+	// How long to keep an idle connection open waiting for more transactions.
+	// This must be lower than the protocol's blocking TxIds timeout
+	submitterIdleTimeout = 30 * time.Second
+	// Maximum time to wait for the peer to complete the handshake
+	submitterConnectTimeout = 30 * time.Second
+)
+
+var ErrSubmitterClosed = errors.New("submitter closed")
+
+// Submitter maintains a long-lived node-to-node connection to a single peer
+// and serves queued transactions to it via the TxSubmission mini-protocol.
+// It is safe for concurrent use
+type Submitter struct {
+	address string
+	network ouroboros.Network
+	mutex   sync.Mutex
+	conn    *submitterConn
+	// Closed once the connection attempt in progress, if any, is done
+	connectingChan chan struct{}
+	queue          []*pendingTx
+	wakeChan       chan struct{}
+	closed         bool
+}
+
+// submitterConn holds the state for a single connection to the remote peer
+type submitterConn struct {
+	oConn *ouroboros.Connection
+	// TXs announced to the peer, in order, that have not yet been acknowledged
+	unacked []*pendingTx
+	closing bool
+}
+
+type pendingTx struct {
+	hash       [32]byte
+	eraId      uint16
+	txBytes    []byte
+	ttl        uint64
+	sent       bool
+	resultChan chan error
+}
+
+func (p *pendingTx) resolve(err error) {
+	select {
+	case p.resultChan <- err:
+	default:
+	}
+}
+
+// NewSubmitter returns a Submitter for the specified peer address. The
+// connection is established on demand
+func NewSubmitter(address string, network ouroboros.Network) *Submitter {
+	return &Submitter{
+		address:  address,
+		network:  network,
+		wakeChan: make(chan struct{}, 1),
+	}
+}
+
+// Submit queues a TX for submission and waits until the remote peer has
+// requested the TX body or the context is cancelled
+func (s *Submitter) Submit(ctx context.Context, txBytes []byte) error {
+	// Determine transaction type (era)
+	txType, err := ledger.DetermineTransactionType(txBytes)
+	if err != nil {
+		return fmt.Errorf(
+			"could not parse transaction to determine type: %w",
+			err,
+		)
+	}
+	tx, err := ledger.NewTransactionFromCbor(txType, txBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse transaction CBOR: %w", err)
+	}
+	ptx := &pendingTx{
+		hash:       [32]byte(tx.Hash()),
+		eraId:      uint16(txType), // #nosec G115
+		txBytes:    txBytes,
+		ttl:        tx.TTL(),
+		resultChan: make(chan error, 1),
+	}
+	if err := s.enqueue(ctx, ptx); err != nil {
+		return err
+	}
+	s.wake()
+	select {
+	case err := <-ptx.resultChan:
+		return err
+	case <-ctx.Done():
+		// Remove TX from queue if it hasn't been announced to the peer yet
+		s.mutex.Lock()
+		s.queue = slices.DeleteFunc(
+			s.queue,
+			func(p *pendingTx) bool { return p == ptx },
+		)
+		s.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Close shuts down the connection and fails any outstanding TXs
+func (s *Submitter) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	if s.conn != nil {
+		s.teardown(s.conn, ErrSubmitterClosed)
+	}
+	s.failQueue(ErrSubmitterClosed)
+	// Release any protocol callback waiting for a TX
+	s.wake()
+	return nil
+}
+
+func (s *Submitter) wake() {
+	select {
+	case s.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// enqueue queues the TX once there's a connection to the peer, connecting
+// first if needed. If connecting fails, only this TX is failed, and TXs
+// queued by other callers waiting on the same connection attempt try again
+func (s *Submitter) enqueue(ctx context.Context, ptx *pendingTx) error {
+	for {
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			return ErrSubmitterClosed
+		}
+		if s.conn != nil {
+			s.queue = append(s.queue, ptx)
+			s.mutex.Unlock()
+			return nil
+		}
+		connectingChan := s.connectingChan
+		s.mutex.Unlock()
+		if connectingChan != nil {
+			// Wait for the connection attempt in progress
+			select {
+			case <-connectingChan:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+}
+
+// connect establishes a new connection to the peer, unless there already is
+// one or another connection attempt is in progress. The caller must not hold
+// the mutex, which isn't held while dialing the peer
+func (s *Submitter) connect() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return ErrSubmitterClosed
+	}
+	if s.conn != nil || s.connectingChan != nil {
+		s.mutex.Unlock()
+		return nil
+	}
+	connectingChan := make(chan struct{})
+	s.connectingChan = connectingChan
+	s.mutex.Unlock()
+	sConn, err := s.dial()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.connectingChan = nil
+	close(connectingChan)
+	if err != nil {
+		return err
+	}
+	if s.closed {
+		s.teardown(sConn, ErrSubmitterClosed)
+		return ErrSubmitterClosed
+	}
+	if sConn.closing {
+		return fmt.Errorf("connection to %s lost", s.address)
+	}
+	s.conn = sConn
+	// Start txSubmission loop
+	sConn.oConn.TxSubmission().Client.Init()
+	return nil
+}
+
+// dial opens a new connection to the peer and performs the handshake
+func (s *Submitter) dial() (*submitterConn, error) {
+	conn, err := createClientConnection("tcp", s.address)
+	if err != nil {
+		return nil, err
+	}
+	// Don't wait forever on a peer that doesn't complete the handshake
+	if err := conn.SetDeadline(
+		time.Now().Add(submitterConnectTimeout),
+	); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set connection deadline: %w", err)
+	}
+	sConn := &submitterConn{}
+	// Buffered, since errors during the handshake are sent before anything
+	// reads from it
+	errorChan := make(chan error, 1)
+	oConn, err := ouroboros.New(
+		ouroboros.WithConnection(conn),
+		ouroboros.WithNetwork(s.network),
+		ouroboros.WithErrorChan(errorChan),
+		ouroboros.WithNodeToNode(true),
+		ouroboros.WithKeepAlive(true),
+		ouroboros.WithTxSubmissionConfig(
+			txsubmission.NewConfig(
+				txsubmission.WithRequestTxIdsFunc(
+					func(
+						ctx txsubmission.CallbackContext,
+						blocking bool,
+						ack uint16,
+						req uint16,
+					) ([]txsubmission.TxIdAndSize, error) {
+						return s.handleRequestTxIds(sConn, blocking, ack, req)
+					},
+				),
+				txsubmission.WithRequestTxsFunc(
+					func(
+						ctx txsubmission.CallbackContext,
+						txIds []txsubmission.TxId,
+					) ([]txsubmission.TxBody, error) {
+						return s.handleRequestTxs(sConn, txIds)
+					},
+				),
+			),
+		),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.address, err)
+	}
+	sConn.oConn = oConn
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		oConn.Close()
+		return nil, fmt.Errorf("failed to clear connection deadline: %w", err)
+	}
+	// Capture async errors
+	go func() {
+		err, ok := <-errorChan
+		if !ok {
+			return
+		}
+		s.mutex.Lock()
+		if sConn.closing {
+			// Errors are expected after we've started shutting down
+			s.mutex.Unlock()
+			return
+		}
+		slog.Warn(
+			fmt.Sprintf("submit connection to %s failed: %s", s.address, err),
+		)
+		s.teardown(sConn, err)
+		// Reconnect if there are still queued TXs
+		reconnect := s.conn == nil && len(s.queue) > 0 && !s.closed
+		s.mutex.Unlock()
+		if !reconnect {
+			return
+		}
+		if err := s.connect(); err != nil {
+			// There's no caller left to retry, so fail the queued TXs
+			s.mutex.Lock()
+			s.failQueue(err)
+			s.mutex.Unlock()
+			return
+		}
+		s.wake()
+	}()
+	return sConn, nil
+}
+
+// teardown closes the specified connection and fails any TXs that were
+// announced on it but never requested. The caller must hold the mutex
+func (s *Submitter) teardown(sConn *submitterConn, err error) {
+	if s.conn == sConn {
+		s.conn = nil
+	}
+	for _, ptx := range sConn.unacked {
+		if !ptx.sent {
+			ptx.resolve(
+				fmt.Errorf("connection to %s lost: %w", s.address, err),
+			)
+		}
+	}
+	sConn.unacked = nil
+	if sConn.closing {
+		return
+	}
+	sConn.closing = true
+	// Close the connection asynchronously, since we may be called from a
+	// protocol callback
+	go sConn.oConn.Close()
+}
+
+// failQueue fails all queued TXs. The caller must hold the mutex
+func (s *Submitter) failQueue(err error) {
+	for _, ptx := range s.queue {
+		ptx.resolve(err)
+	}
+	s.queue = nil
+}
+
+func (s *Submitter) handleRequestTxIds(
+	sConn *submitterConn,
+	blocking bool,
+	ack uint16,
+	req uint16,
+) ([]txsubmission.TxIdAndSize, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// Remove acknowledged TXs from the front of the window
+	ackCount := min(int(ack), len(sConn.unacked))
+	for _, ptx := range sConn.unacked[:ackCount] {
+		if !ptx.sent {
+			// The peer acknowledged the TX without requesting the body, which
+			// means that it already has it
+			ptx.resolve(nil)
+		}
+	}
+	sConn.unacked = sConn.unacked[ackCount:]
+	s.dropExpired()
+	// Wait for a TX to become available when the peer asks us to block
+	for blocking && len(s.queue) == 0 {
+		s.mutex.Unlock()
+		var idle bool
+		select {
+		case <-s.wakeChan:
+		case <-time.After(submitterIdleTimeout):
+			idle = true
+		}
+		s.mutex.Lock()
+		if sConn.closing {
+			return nil, txsubmission.ErrStopServerProcess
+		}
+		s.dropExpired()
+		if idle && len(s.queue) == 0 {
+			// Shut down idle connection. A new one will be created on the
+			// next submit
+			s.teardown(sConn, errors.New("idle timeout"))
+			return nil, txsubmission.ErrStopServerProcess
+		}
+	}
+	// Announce queued TXs up to the requested count
+	count := min(int(req), len(s.queue))
+	ret := make([]txsubmission.TxIdAndSize, 0, count)
+	for _, ptx := range s.queue[:count] {
+		ret = append(
+			ret,
+			txsubmission.TxIdAndSize{
+				TxId: txsubmission.TxId{
+					EraId: ptx.eraId,
+					TxId:  ptx.hash,
+				},
+				Size: uint32(len(ptx.txBytes)), // #nosec G115
+			},
+		)
+	}
+	sConn.unacked = append(sConn.unacked, s.queue[:count]...)
+	s.queue = s.queue[count:]
+	return ret, nil
+}
+
+// dropExpired fails queued TXs that expired while waiting to be announced,
+// since the peer would reject them anyway. The caller must hold the mutex
+func (s *Submitter) dropExpired() {
+	s.queue = slices.DeleteFunc(
+		s.queue,
+		func(p *pendingTx) bool {
+			if err := checkTtl(s.network.Name, p.ttl); err != nil {
+				p.resolve(err)
+				return true
+			}
+			return false
+		},
+	)
+}
+
+func (s *Submitter) handleRequestTxs(
+	sConn *submitterConn,
+	txIds []txsubmission.TxId,
+) ([]txsubmission.TxBody, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ret := make([]txsubmission.TxBody, 0, len(txIds))
+	for _, txId := range txIds {
+		idx := slices.IndexFunc(
+			sConn.unacked,
+			func(p *pendingTx) bool { return p.hash == txId.TxId },
+		)
+		if idx < 0 {
+			slog.Warn(
+				fmt.Sprintf(
+					"peer %s requested unknown TX %x",
+					s.address,
+					txId.TxId,
+				),
+			)
+			continue
+		}
+		ptx := sConn.unacked[idx]
+		ret = append(
+			ret,
+			txsubmission.TxBody{
+				EraId:  ptx.eraId,
+				TxBody: ptx.txBytes,
+			},
+		)
+		ptx.sent = true
+		ptx.resolve(nil)
+	}
+	return ret, nil
+}
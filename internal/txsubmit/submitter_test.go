@@ -0,0 +1,477 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txsubmit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/txsubmission"
+)
+
+// mockPeer is a node-to-node peer that requests TXs from the submitter via
+// TxSubmission as directed by the test
+type mockPeer struct {
+	listener   net.Listener
+	serverChan chan *txsubmission.Server
+	mutex      sync.Mutex
+	conns      []net.Conn
+}
+
+// startMockPeer listens for node-to-node connections on a local address
+func startMockPeer(t *testing.T) *mockPeer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	p := &mockPeer{
+		listener:   listener,
+		serverChan: make(chan *txsubmission.Server, 10),
+	}
+	t.Cleanup(p.close)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			p.mutex.Lock()
+			p.conns = append(p.conns, conn)
+			p.mutex.Unlock()
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *mockPeer) serve(conn net.Conn) {
+	// The handshake fails once the connection is closed, so this doesn't
+	// outlive the test
+	_, _ = ouroboros.New(
+		ouroboros.WithConnection(conn),
+		ouroboros.WithNetwork(ouroboros.NetworkPreview),
+		ouroboros.WithServer(true),
+		ouroboros.WithNodeToNode(true),
+		ouroboros.WithTxSubmissionConfig(
+			txsubmission.NewConfig(
+				txsubmission.WithInitFunc(
+					func(ctx txsubmission.CallbackContext) error {
+						p.serverChan <- ctx.Server
+						return nil
+					},
+				),
+			),
+		),
+	)
+}
+
+// server waits for the submitter to connect and start TxSubmission
+func (p *mockPeer) server(t *testing.T) *txsubmission.Server {
+	t.Helper()
+	select {
+	case server := <-p.serverChan:
+		return server
+	case <-time.After(5 * time.Second):
+		t.Fatalf("submitter didn't start TxSubmission")
+	}
+	return nil
+}
+
+// dropConns closes all connections from the submitter
+func (p *mockPeer) dropConns() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *mockPeer) close() {
+	p.listener.Close()
+	p.dropConns()
+}
+
+// submitResult is the outcome of an asynchronous Submit call
+type submitResult struct {
+	txHash string
+	err    error
+}
+
+// submitAsync submits the TX in the background and sends the result on
+// resultChan
+func submitAsync(
+	t *testing.T,
+	ctx context.Context,
+	s *Submitter,
+	txBytes []byte,
+	resultChan chan<- submitResult,
+) string {
+	t.Helper()
+	tx, err := ledger.NewConwayTransactionFromCbor(txBytes)
+	if err != nil {
+		t.Fatalf("failed to decode TX: %s", err)
+	}
+	txHash := tx.Hash().String()
+	go func() {
+		resultChan <- submitResult{
+			txHash: txHash,
+			err:    s.Submit(ctx, txBytes),
+		}
+	}()
+	return txHash
+}
+
+// waitQueued waits until the specified number of TXs is queued and not yet
+// announced to the peer
+func waitQueued(t *testing.T, s *Submitter, count int) {
+	t.Helper()
+	for range 500 {
+		s.mutex.Lock()
+		queued := len(s.queue)
+		s.mutex.Unlock()
+		if queued == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d queued TXs", count)
+}
+
+// waitResults collects the specified number of submit results by TX hash
+func waitResults(
+	t *testing.T,
+	resultChan <-chan submitResult,
+	count int,
+) map[string]error {
+	t.Helper()
+	ret := make(map[string]error, count)
+	for range count {
+		select {
+		case result := <-resultChan:
+			ret[result.txHash] = result.err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d results, got %d", count, len(ret))
+		}
+	}
+	return ret
+}
+
+func txIdHashes(txIds []txsubmission.TxIdAndSize) []string {
+	ret := make([]string, 0, len(txIds))
+	for _, txId := range txIds {
+		ret = append(ret, ledger.NewBlake2b256(txId.TxId.TxId[:]).String())
+	}
+	return ret
+}
+
+// unusedAddress returns a local address that nothing is listening on
+func unusedAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestSubmitterConnectFailure(t *testing.T) {
+	s := NewSubmitter(unusedAddress(t), ouroboros.NetworkPreview)
+	t.Cleanup(func() { s.Close() })
+	if err := s.Submit(context.Background(), testTx(t)); err == nil {
+		t.Fatalf("expected a connection error")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.queue) != 0 || s.conn != nil || s.connectingChan != nil {
+		t.Fatalf("expected the failed TX to be removed and no connection")
+	}
+}
+
+func TestSubmitterCloseWhileConnecting(t *testing.T) {
+	// The peer accepts connections but never completes the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	acceptedChan := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			acceptedChan <- conn
+		}
+	}()
+	s := NewSubmitter(listener.Addr().String(), ouroboros.NetworkPreview)
+	txBytes := testTx(t)
+	var wg sync.WaitGroup
+	errChan := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errChan <- s.Submit(context.Background(), txBytes)
+		}()
+	}
+	var peerConn net.Conn
+	select {
+	case peerConn = <-acceptedChan:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("submitter didn't connect")
+	}
+	// The mutex isn't held while waiting for the handshake
+	closedChan := make(chan struct{})
+	go func() {
+		s.Close()
+		close(closedChan)
+	}()
+	select {
+	case <-closedChan:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("close blocked on the connection attempt")
+	}
+	// Fail the handshake, so that the connection attempt returns
+	peerConn.Close()
+	wg.Wait()
+	close(errChan)
+	for err := range errChan {
+		if err == nil {
+			t.Fatalf("expected TX submission to fail")
+		}
+	}
+	// Both TXs waited for the same connection attempt
+	select {
+	case conn := <-acceptedChan:
+		conn.Close()
+		t.Fatalf("expected a single connection attempt")
+	default:
+	}
+}
+
+func TestSubmitterWindow(t *testing.T) {
+	peer := startMockPeer(t)
+	s := NewSubmitter(peer.listener.Addr().String(), ouroboros.NetworkPreview)
+	t.Cleanup(func() { s.Close() })
+	resultChan := make(chan submitResult, 3)
+	for idx := range 3 {
+		submitAsync(
+			t,
+			context.Background(),
+			s,
+			testTxWithInput(t, uint64(idx), 0),
+			resultChan,
+		)
+	}
+	server := peer.server(t)
+	waitQueued(t, s, 3)
+	// Only the requested number of TXs is announced
+	txIds, err := server.RequestTxIds(true, 2)
+	if err != nil {
+		t.Fatalf("failed to request TX IDs: %s", err)
+	}
+	if len(txIds) != 2 {
+		t.Fatalf("expected 2 TX IDs, got %d", len(txIds))
+	}
+	waitQueued(t, s, 1)
+	s.mutex.Lock()
+	unacked := len(s.conn.unacked)
+	s.mutex.Unlock()
+	if unacked != 2 {
+		t.Fatalf("expected 2 unacknowledged TXs, got %d", unacked)
+	}
+	announced := txIdHashes(txIds)
+	// Only request the first TX body. The second TX is acknowledged without
+	// being requested, which means that the peer already has it
+	txBodies, err := server.RequestTxs([]txsubmission.TxId{txIds[0].TxId})
+	if err != nil {
+		t.Fatalf("failed to request TXs: %s", err)
+	}
+	if len(txBodies) != 1 {
+		t.Fatalf("expected 1 TX body, got %d", len(txBodies))
+	}
+	results := waitResults(t, resultChan, 1)
+	if err, ok := results[announced[0]]; !ok || err != nil {
+		t.Fatalf("expected TX %s to succeed, got: %v", announced[0], results)
+	}
+	// This acknowledges both announced TXs and announces the last one
+	txIds, err = server.RequestTxIds(false, 2)
+	if err != nil {
+		t.Fatalf("failed to request TX IDs: %s", err)
+	}
+	if len(txIds) != 1 {
+		t.Fatalf("expected 1 TX ID, got %d", len(txIds))
+	}
+	results = waitResults(t, resultChan, 1)
+	if err, ok := results[announced[1]]; !ok || err != nil {
+		t.Fatalf("expected TX %s to succeed, got: %v", announced[1], results)
+	}
+	s.mutex.Lock()
+	unacked = len(s.conn.unacked)
+	queued := len(s.queue)
+	s.mutex.Unlock()
+	if unacked != 1 || queued != 0 {
+		t.Fatalf(
+			"expected 1 unacknowledged and 0 queued TXs, got %d and %d",
+			unacked,
+			queued,
+		)
+	}
+	txBodies, err = server.RequestTxs([]txsubmission.TxId{txIds[0].TxId})
+	if err != nil {
+		t.Fatalf("failed to request TXs: %s", err)
+	}
+	if len(txBodies) != 1 {
+		t.Fatalf("expected 1 TX body, got %d", len(txBodies))
+	}
+	results = waitResults(t, resultChan, 1)
+	lastHash := txIdHashes(txIds)[0]
+	if err, ok := results[lastHash]; !ok || err != nil {
+		t.Fatalf("expected TX %s to succeed, got: %v", lastHash, results)
+	}
+}
+
+func TestSubmitterBlockingRequestWakes(t *testing.T) {
+	peer := startMockPeer(t)
+	s := NewSubmitter(peer.listener.Addr().String(), ouroboros.NetworkPreview)
+	t.Cleanup(func() { s.Close() })
+	resultChan := make(chan submitResult, 2)
+	submitAsync(
+		t,
+		context.Background(),
+		s,
+		testTxWithInput(t, 0, 0),
+		resultChan,
+	)
+	server := peer.server(t)
+	txIds, err := server.RequestTxIds(true, 1)
+	if err != nil {
+		t.Fatalf("failed to request TX IDs: %s", err)
+	}
+	if _, err := server.RequestTxs(
+		[]txsubmission.TxId{txIds[0].TxId},
+	); err != nil {
+		t.Fatalf("failed to request TXs: %s", err)
+	}
+	waitResults(t, resultChan, 1)
+	// With nothing queued, the blocking request waits for the next TX
+	type txIdsResult struct {
+		txIds []txsubmission.TxIdAndSize
+		err   error
+	}
+	txIdsChan := make(chan txIdsResult, 1)
+	go func() {
+		txIds, err := server.RequestTxIds(true, 1)
+		txIdsChan <- txIdsResult{txIds: txIds, err: err}
+	}()
+	select {
+	case <-txIdsChan:
+		t.Fatalf("blocking request returned without a queued TX")
+	case <-time.After(200 * time.Millisecond):
+	}
+	txHash := submitAsync(
+		t,
+		context.Background(),
+		s,
+		testTxWithInput(t, 1, 0),
+		resultChan,
+	)
+	var result txIdsResult
+	select {
+	case result = <-txIdsChan:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("blocking request didn't wake up for the queued TX")
+	}
+	if result.err != nil {
+		t.Fatalf("failed to request TX IDs: %s", result.err)
+	}
+	hashes := txIdHashes(result.txIds)
+	if len(hashes) != 1 || hashes[0] != txHash {
+		t.Fatalf("expected TX %s to be announced, got %v", txHash, hashes)
+	}
+}
+
+func TestSubmitterResults(t *testing.T) {
+	peer := startMockPeer(t)
+	s := NewSubmitter(peer.listener.Addr().String(), ouroboros.NetworkPreview)
+	t.Cleanup(func() { s.Close() })
+	resultChan := make(chan submitResult, 4)
+	requestedHash := submitAsync(
+		t,
+		context.Background(),
+		s,
+		testTxWithInput(t, 0, 0),
+		resultChan,
+	)
+	// Slot 1 is long gone on preview
+	expiredHash := submitAsync(
+		t,
+		context.Background(),
+		s,
+		testTxWithInput(t, 1, 1),
+		resultChan,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelledHash := submitAsync(
+		t,
+		ctx,
+		s,
+		testTxWithInput(t, 2, 0),
+		resultChan,
+	)
+	server := peer.server(t)
+	waitQueued(t, s, 3)
+	cancel()
+	results := waitResults(t, resultChan, 1)
+	if err := results[cancelledHash]; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled TX to fail, got: %v", results)
+	}
+	// The expired TX is dropped instead of being announced
+	txIds, err := server.RequestTxIds(true, 10)
+	if err != nil {
+		t.Fatalf("failed to request TX IDs: %s", err)
+	}
+	hashes := txIdHashes(txIds)
+	if len(hashes) != 1 || hashes[0] != requestedHash {
+		t.Fatalf(
+			"expected TX %s to be announced, got %v",
+			requestedHash,
+			hashes,
+		)
+	}
+	results = waitResults(t, resultChan, 1)
+	if err := results[expiredHash]; !errors.Is(err, ErrTxExpired) {
+		t.Fatalf("expected expired TX to fail, got: %v", results)
+	}
+	// The announced TX fails when the connection is lost before the peer
+	// requests it
+	peer.dropConns()
+	results = waitResults(t, resultChan, 1)
+	err = results[requestedHash]
+	if err == nil || !strings.Contains(err.Error(), "connection to") {
+		t.Fatalf("expected announced TX to fail, got: %v", results)
+	}
+}
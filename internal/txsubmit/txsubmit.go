@@ -17,59 +17,199 @@ package txsubmit
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/chain"
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
 	ouroboros "github.com/blinklabs-io/gouroboros"
 	"github.com/blinklabs-io/gouroboros/ledger"
-	"github.com/blinklabs-io/gouroboros/protocol/txsubmission"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxsubmission"
+)
+
+const (
+	// Maximum time to wait for a peer to request a submitted TX
+	ntnSubmitTimeout = 2 * time.Minute
 )
 
 var (
-	ntnTxBytes  []byte
-	ntnTxHash   [32]byte
-	ntnTxType   uint
-	ntnSentTx   bool
-	ntnDoneChan chan any
+	// Maximum time to wait for the local node to complete the handshake, and
+	// then to accept or reject a TX
+	ntcSubmitTimeout = 2 * time.Minute
+
+	submitters      = map[string]*Submitter{}
+	submittersMutex sync.Mutex
 )
 
 func SubmitTx(txBytes []byte) error {
 	cfg := config.GetConfig()
-	if cfg.Submit.Address != "" {
-		return submitTxNtN(txBytes)
+	tx, err := parseTx(txBytes)
+	if err != nil {
+		return err
+	}
+	if err := checkTtl(cfg.Network, tx.TTL()); err != nil {
+		return fmt.Errorf("transaction %s: %w", tx.Hash().String(), err)
+	}
+	if cfg.Submit.Address != "" || len(cfg.Submit.Peers) > 0 {
+		return submitTxNtN(txBytes, tx.Hash().String())
 	} else if cfg.Submit.SocketPath != "" {
 		return submitTxNtC(txBytes)
 	} else if cfg.Submit.Url != "" {
 		return submitTxApi(txBytes)
+	} else if cfg.Submit.OgmiosUrl != "" {
+		return submitTxOgmios(txBytes)
 	} else {
-		// Populate address info from indexer network
-		network, ok := ouroboros.NetworkByName(cfg.Network)
-		if !ok {
-			return fmt.Errorf("unknown network: %s", cfg.Network)
+		// Broadcast to the bootstrap peers for the indexer network
+		return submitTxNtN(txBytes, tx.Hash().String())
+	}
+}
+
+// submitTxNtN broadcasts a TX to the node-to-node peers in parallel. It
+// returns once a quorum of peers has requested the TX body, and the
+// remaining peers keep going in the background
+func submitTxNtN(txBytes []byte, txHash string) error {
+	cfg := config.GetConfig()
+	peers, err := ntnPeers()
+	if err != nil {
+		return err
+	}
+	quorum := min(max(cfg.Submit.Quorum, 1), len(peers))
+	type peerResult struct {
+		peer string
+		err  error
+	}
+	resultChan := make(chan peerResult, len(peers))
+	ctx, cancel := context.WithTimeout(context.Background(), ntnSubmitTimeout)
+	for _, peer := range peers {
+		go func() {
+			submitter, err := getSubmitter(peer)
+			if err == nil {
+				err = submitter.Submit(ctx, txBytes)
+			}
+			if err != nil {
+				slog.Warn(
+					fmt.Sprintf(
+						"failed to submit TX %s to peer %s: %s",
+						txHash,
+						peer,
+						err,
+					),
+				)
+			} else {
+				slog.Info(
+					fmt.Sprintf("peer %s requested TX %s", peer, txHash),
+				)
+			}
+			resultChan <- peerResult{peer: peer, err: err}
+		}()
+	}
+	// Wait until we reach the quorum or can no longer reach it
+	var received, successes int
+	var errs []error
+	for successes < quorum && len(errs) <= len(peers)-quorum {
+		result := <-resultChan
+		received++
+		if result.err != nil {
+			errs = append(
+				errs,
+				fmt.Errorf("peer %s: %w", result.peer, result.err),
+			)
+			continue
 		}
-		if len(network.BootstrapPeers) == 0 {
-			return fmt.Errorf("no upstream configured for %s", cfg.Network)
+		successes++
+	}
+	go func() {
+		for ; received < len(peers); received++ {
+			<-resultChan
+		}
+		cancel()
+	}()
+	if successes < quorum {
+		return fmt.Errorf(
+			"TX %s requested by %d of %d peers, needed %d: %w",
+			txHash,
+			successes,
+			len(peers),
+			quorum,
+			errors.Join(errs...),
+		)
+	}
+	return nil
+}
+
+// ntnPeers returns the peers to broadcast TXs to via node-to-node. These are
+// the configured peers, or all bootstrap peers for the network if none are
+// configured
+func ntnPeers() ([]string, error) {
+	cfg := config.GetConfig()
+	var ret []string
+	if cfg.Submit.Address != "" {
+		ret = append(ret, cfg.Submit.Address)
+	}
+	for _, peer := range cfg.Submit.Peers {
+		if !slices.Contains(ret, peer) {
+			ret = append(ret, peer)
 		}
-		peer := network.BootstrapPeers[0]
-		cfg.Submit.Address = fmt.Sprintf("%s:%d", peer.Address, peer.Port)
-		return submitTxNtN(txBytes)
 	}
+	if len(ret) > 0 {
+		return ret, nil
+	}
+	network, ok := ouroboros.NetworkByName(cfg.Network)
+	if !ok {
+		return nil, fmt.Errorf("unknown network: %s", cfg.Network)
+	}
+	if len(network.BootstrapPeers) == 0 {
+		return nil, fmt.Errorf("no upstream configured for %s", cfg.Network)
+	}
+	for _, peer := range network.BootstrapPeers {
+		ret = append(ret, fmt.Sprintf("%s:%d", peer.Address, peer.Port))
+	}
+	return ret, nil
+}
+
+// getSubmitter returns the shared Submitter for the specified peer address,
+// creating it if necessary
+func getSubmitter(address string) (*Submitter, error) {
+	cfg := config.GetConfig()
+	submittersMutex.Lock()
+	defer submittersMutex.Unlock()
+	if submitter, ok := submitters[address]; ok {
+		return submitter, nil
+	}
+	network, ok := ouroboros.NetworkByName(cfg.Network)
+	if !ok {
+		return nil, fmt.Errorf("cannot get network: %s", cfg.Network)
+	}
+	submitter := NewSubmitter(address, network)
+	submitters[address] = submitter
+	return submitter, nil
 }
 
-func submitTxNtN(txBytes []byte) error {
+func submitTxNtC(txBytes []byte) error {
 	cfg := config.GetConfig()
+	conn, err := createClientConnection("unix", cfg.Submit.SocketPath)
+	if err != nil {
+		return err
+	}
+	return submitTxNtCConn(conn, txBytes)
+}
 
-	// Record TX bytes in global for use in handler functions
-	ntnTxBytes = txBytes[:]
-	ntnSentTx = false
+// submitTxNtCConn submits a TX via LocalTxSubmission over an existing
+// connection. The connection is closed before returning
+func submitTxNtCConn(conn net.Conn, txBytes []byte) error {
+	cfg := config.GetConfig()
 
 	// Determine transaction type (era)
 	txType, err := ledger.DetermineTransactionType(txBytes)
 	if err != nil {
+		conn.Close()
 		return fmt.Errorf(
 			"could not parse transaction to determine type: %w",
 			err,
@@ -77,66 +217,80 @@ func submitTxNtN(txBytes []byte) error {
 	}
 	tx, err := ledger.NewTransactionFromCbor(txType, txBytes)
 	if err != nil {
+		conn.Close()
 		return fmt.Errorf("failed to parse transaction CBOR: %w", err)
 	}
-	ntnTxHash = [32]byte(tx.Hash())
-	ntnTxType = txType
 
-	// Create connection
-	conn, err := createClientConnection(cfg.Submit.Address)
-	if err != nil {
-		return err
-	}
-	errorChan := make(chan error)
-	// Capture errors
-	go func() {
-		err, ok := <-errorChan
-		if ok {
-			panic(fmt.Errorf("async: %w", err))
-		}
-	}()
 	network, ok := ouroboros.NetworkByName(cfg.Network)
 	if !ok {
+		conn.Close()
 		return fmt.Errorf("cannot get network: %s", cfg.Network)
 	}
+	// Don't wait forever on a node that stops responding during the handshake
+	if err := conn.SetDeadline(time.Now().Add(ntcSubmitTimeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set connection deadline: %w", err)
+	}
+	errorChan := make(chan error, 1)
 	oConn, err := ouroboros.New(
 		ouroboros.WithConnection(conn),
 		ouroboros.WithNetwork(network),
 		ouroboros.WithErrorChan(errorChan),
-		ouroboros.WithNodeToNode(true),
-		ouroboros.WithKeepAlive(true),
-		ouroboros.WithTxSubmissionConfig(
-			txsubmission.NewConfig(
-				txsubmission.WithRequestTxIdsFunc(handleRequestTxIds),
-				txsubmission.WithRequestTxsFunc(handleRequestTxs),
-			),
+		ouroboros.WithNodeToNode(false),
+		ouroboros.WithLocalTxSubmissionConfig(
+			localtxsubmission.NewConfig(),
 		),
 	)
 	if err != nil {
-		return err
+		conn.Close()
+		return fmt.Errorf("failed to connect to node: %w", err)
 	}
-
-	// Start txSubmission loop
-	ntnDoneChan = make(chan any)
-	oConn.TxSubmission().Client.Init()
-	<-ntnDoneChan
-	// Sleep 2s to allow time for TX to enter remote mempool before closing connection
-	time.Sleep(2 * time.Second)
-
-	if err := oConn.Close(); err != nil {
-		return fmt.Errorf("failed to close connection: %w", err)
+	defer oConn.Close()
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("failed to clear connection deadline: %w", err)
 	}
 
-	return nil
-}
-
-func submitTxNtC(txBytes []byte) error {
-	// TODO
-	return nil
+	// Submit TX and wait for the node to accept or reject it
+	resultChan := make(chan error, 1)
+	go func() {
+		resultChan <- oConn.LocalTxSubmission().Client.SubmitTx(
+			uint16(txType), // #nosec G115
+			txBytes,
+		)
+	}()
+	select {
+	case err := <-resultChan:
+		if err == nil {
+			return nil
+		}
+		var rejectErr localtxsubmission.TransactionRejectedError
+		if errors.As(err, &rejectErr) {
+			return &TxRejectedError{
+				TxHash:     tx.Hash().String(),
+				Reason:     rejectErr.Reason,
+				ReasonCbor: rejectErr.ReasonCbor,
+			}
+		}
+		return fmt.Errorf("failed to submit TX: %w", err)
+	case err, ok := <-errorChan:
+		if !ok {
+			return errors.New("connection closed during TX submission")
+		}
+		return fmt.Errorf("connection failed during TX submission: %w", err)
+	case <-time.After(ntcSubmitTimeout):
+		return fmt.Errorf(
+			"timed out after %s waiting for the node to accept the TX",
+			ntcSubmitTimeout,
+		)
+	}
 }
 
 func submitTxApi(txBytes []byte) error {
 	cfg := config.GetConfig()
+	tx, err := parseTx(txBytes)
+	if err != nil {
+		return err
+	}
 	ctx := context.Background()
 	reqBody := bytes.NewBuffer(txBytes)
 	req, err := http.NewRequestWithContext(
@@ -173,17 +327,41 @@ func submitTxApi(txBytes []byte) error {
 
 	if resp.StatusCode == http.StatusAccepted {
 		return nil
+	} else if resp.StatusCode == http.StatusBadRequest {
+		// The submit API returns the ledger rejection reason as the body
+		return &TxRejectedError{
+			TxHash: tx.Hash().String(),
+			Reason: errors.New(string(respBody)),
+		}
 	} else {
 		return fmt.Errorf("failed to submit TX to API: %s: %d: %s", cfg.Submit.Url, resp.StatusCode, respBody)
 	}
 }
 
-func createClientConnection(nodeAddress string) (net.Conn, error) {
+// checkTtl returns ErrTxExpired if the current slot is past the TTL. A TTL of
+// 0 means that the TX doesn't expire
+func checkTtl(network string, ttl uint64) error {
+	if ttl == 0 {
+		return nil
+	}
+	slot, err := chain.TimeToSlot(network, time.Now())
+	if err != nil {
+		// We can't tell the current slot for unsupported networks
+		return nil
+	}
+	if slot >= ttl {
+		return ErrTxExpired
+	}
+	return nil
+}
+
+func createClientConnection(
+	dialProto string,
+	nodeAddress string,
+) (net.Conn, error) {
 	var err error
 	var conn net.Conn
-	var dialProto string
 	var dialAddress string
-	dialProto = "tcp"
 	dialAddress = nodeAddress
 	conn, err = net.Dial(dialProto, dialAddress)
 	if err != nil {
@@ -191,41 +369,3 @@ func createClientConnection(nodeAddress string) (net.Conn, error) {
 	}
 	return conn, nil
 }
-
-func handleRequestTxIds(
-	ctx txsubmission.CallbackContext,
-	blocking bool,
-	ack uint16,
-	req uint16,
-) ([]txsubmission.TxIdAndSize, error) {
-	if ntnSentTx {
-		// Terrible synchronization hack for shutdown
-		close(ntnDoneChan)
-		time.Sleep(5 * time.Second)
-		return nil, nil
-	}
-	ret := []txsubmission.TxIdAndSize{
-		{
-			TxId: txsubmission.TxId{
-				EraId: uint16(ntnTxType), // #nosec G115
-				TxId:  ntnTxHash,
-			},
-			Size: uint32(len(ntnTxBytes)), // #nosec G115
-		},
-	}
-	return ret, nil
-}
-
-func handleRequestTxs(
-	ctx txsubmission.CallbackContext,
-	txIds []txsubmission.TxId,
-) ([]txsubmission.TxBody, error) {
-	ret := []txsubmission.TxBody{
-		{
-			EraId:  uint16(ntnTxType), // #nosec G115
-			TxBody: ntnTxBytes,
-		},
-	}
-	ntnSentTx = true
-	return ret, nil
-}
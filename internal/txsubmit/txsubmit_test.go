@@ -0,0 +1,356 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package txsubmit
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	"github.com/blinklabs-io/gouroboros/cbor"
+	"github.com/blinklabs-io/gouroboros/ledger"
+	"github.com/blinklabs-io/gouroboros/protocol/localtxsubmission"
+	"github.com/blinklabs-io/gouroboros/protocol/txsubmission"
+)
+
+// testSocketPair returns both ends of a connected Unix socket pair
+func testSocketPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("failed to create socket pair: %s", err)
+	}
+	conns := make([]net.Conn, 0, len(fds))
+	for _, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		conn, err := net.FileConn(f)
+		// FileConn duplicates the file descriptor
+		f.Close()
+		if err != nil {
+			t.Fatalf("failed to create connection: %s", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conns = append(conns, conn)
+	}
+	return conns[0], conns[1]
+}
+
+// testTx returns a minimal CBOR-encoded Conway TX
+func testTx(t *testing.T) []byte {
+	t.Helper()
+	return testTxWithInput(t, 0, 0)
+}
+
+// testTxWithInput returns a minimal CBOR-encoded Conway TX spending the
+// specified input index, so that each index gives a different TX hash. A TTL
+// of 0 leaves it unset
+func testTxWithInput(t *testing.T, inputIdx uint64, ttl uint64) []byte {
+	t.Helper()
+	body := map[uint]any{
+		0: []any{[]any{bytes.Repeat([]byte{0x01}, 32), inputIdx}},
+		1: []any{},
+		2: uint64(170_000),
+	}
+	if ttl > 0 {
+		body[3] = ttl
+	}
+	txBytes, err := cbor.Encode(
+		[]any{
+			body,
+			map[uint]any{},
+			true,
+			nil,
+		},
+	)
+	if err != nil {
+		t.Fatalf("failed to encode TX: %s", err)
+	}
+	return txBytes
+}
+
+// startMockNode serves LocalTxSubmission on the connection, answering each
+// submitted TX with the result of submitTxFunc. It returns once the handshake
+// with the client completes
+func startMockNode(
+	t *testing.T,
+	conn net.Conn,
+	submitTxFunc localtxsubmission.SubmitTxFunc,
+) *ouroboros.Connection {
+	t.Helper()
+	network, ok := ouroboros.NetworkByName(config.GetConfig().Network)
+	if !ok {
+		t.Fatalf("unknown network: %s", config.GetConfig().Network)
+	}
+	oConn, err := ouroboros.New(
+		ouroboros.WithConnection(conn),
+		ouroboros.WithNetwork(network),
+		ouroboros.WithServer(true),
+		ouroboros.WithNodeToNode(false),
+		ouroboros.WithLocalTxSubmissionConfig(
+			localtxsubmission.NewConfig(
+				localtxsubmission.WithSubmitTxFunc(submitTxFunc),
+			),
+		),
+	)
+	if err != nil {
+		t.Fatalf("failed to start mock node: %s", err)
+	}
+	return oConn
+}
+
+// setTestNetwork switches the configured network for the duration of the test
+func setTestNetwork(t *testing.T, network string) {
+	t.Helper()
+	cfg := config.GetConfig()
+	origNetwork := cfg.Network
+	cfg.Network = network
+	t.Cleanup(func() { cfg.Network = origNetwork })
+}
+
+func TestSubmitTxNtCConn(t *testing.T) {
+	setTestNetwork(t, "preview")
+	txBytes := testTx(t)
+	tx, err := ledger.NewConwayTransactionFromCbor(txBytes)
+	if err != nil {
+		t.Fatalf("failed to decode TX: %s", err)
+	}
+	testDefs := []struct {
+		name         string
+		submitTxFunc localtxsubmission.SubmitTxFunc
+		// Don't start a mock node, so the handshake never completes
+		noNode      bool
+		expectedErr string
+		rejected    bool
+	}{
+		{
+			name: "accepted",
+			submitTxFunc: func(
+				localtxsubmission.CallbackContext,
+				localtxsubmission.MsgSubmitTxTransaction,
+			) error {
+				return nil
+			},
+		},
+		{
+			name: "rejected",
+			submitTxFunc: func(
+				localtxsubmission.CallbackContext,
+				localtxsubmission.MsgSubmitTxTransaction,
+			) error {
+				return errors.New("bad inputs")
+			},
+			rejected: true,
+		},
+		{
+			name: "no response",
+			submitTxFunc: func(
+				localtxsubmission.CallbackContext,
+				localtxsubmission.MsgSubmitTxTransaction,
+			) error {
+				time.Sleep(2 * time.Second)
+				return nil
+			},
+			expectedErr: "timed out",
+		},
+		{
+			name:        "no handshake",
+			noNode:      true,
+			expectedErr: "failed to connect to node",
+		},
+	}
+	origTimeout := ntcSubmitTimeout
+	ntcSubmitTimeout = 500 * time.Millisecond
+	t.Cleanup(func() { ntcSubmitTimeout = origTimeout })
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			clientConn, nodeConn := testSocketPair(t)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- submitTxNtCConn(clientConn, txBytes)
+			}()
+			if !testDef.noNode {
+				oConn := startMockNode(t, nodeConn, testDef.submitTxFunc)
+				defer oConn.Close()
+			}
+			var err error
+			select {
+			case err = <-errChan:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("TX submission didn't return")
+			}
+			var rejectErr *TxRejectedError
+			switch {
+			case testDef.rejected:
+				if !errors.As(err, &rejectErr) {
+					t.Fatalf("expected a rejection, got: %v", err)
+				}
+				if rejectErr.TxHash != tx.Hash().String() {
+					t.Fatalf(
+						"got TX hash %s, expected %s",
+						rejectErr.TxHash,
+						tx.Hash().String(),
+					)
+				}
+				if rejectErr.Reason == nil {
+					t.Fatalf("rejection has no reason")
+				}
+				var reason string
+				_, err := cbor.Decode(rejectErr.ReasonCbor, &reason)
+				if err != nil {
+					t.Fatalf("failed to decode rejection reason: %s", err)
+				}
+				if reason != "bad inputs" {
+					t.Fatalf(
+						"got rejection reason %q, expected %q",
+						reason,
+						"bad inputs",
+					)
+				}
+			case testDef.expectedErr != "":
+				if err == nil ||
+					!strings.Contains(err.Error(), testDef.expectedErr) {
+					t.Fatalf(
+						"expected an error containing %q, got: %v",
+						testDef.expectedErr,
+						err,
+					)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestSubmitTxNtNQuorum(t *testing.T) {
+	testDefs := []struct {
+		name string
+		// Peers that request the TX, never request it, or refuse connections
+		requesting  int
+		silent      int
+		unreachable int
+		quorum      int
+		expectedErr string
+	}{
+		{
+			// The silent peer keeps going in the background
+			name:       "quorum reached",
+			requesting: 2,
+			silent:     1,
+			quorum:     2,
+		},
+		{
+			name:        "quorum not reached",
+			requesting:  1,
+			unreachable: 2,
+			quorum:      2,
+			expectedErr: "of 3 peers, needed 2",
+		},
+		{
+			name:       "quorum larger than the peers",
+			requesting: 2,
+			quorum:     5,
+		},
+		{
+			name:        "no peers reachable",
+			unreachable: 2,
+			quorum:      1,
+			expectedErr: "requested by 0 of 2 peers, needed 1",
+		},
+	}
+	setTestNetwork(t, "preview")
+	cfg := config.GetConfig()
+	origSubmit := cfg.Submit
+	t.Cleanup(func() { cfg.Submit = origSubmit })
+	for _, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			// Don't reuse submitters from other tests
+			submittersMutex.Lock()
+			submitters = map[string]*Submitter{}
+			submittersMutex.Unlock()
+			t.Cleanup(func() {
+				submittersMutex.Lock()
+				defer submittersMutex.Unlock()
+				for _, submitter := range submitters {
+					submitter.Close()
+				}
+				submitters = map[string]*Submitter{}
+			})
+			var peers []string
+			var requesting []*mockPeer
+			for range testDef.requesting {
+				peer := startMockPeer(t)
+				requesting = append(requesting, peer)
+				peers = append(peers, peer.listener.Addr().String())
+			}
+			for range testDef.silent {
+				peer := startMockPeer(t)
+				peers = append(peers, peer.listener.Addr().String())
+			}
+			for range testDef.unreachable {
+				peers = append(peers, unusedAddress(t))
+			}
+			cfg.Submit.Address = ""
+			cfg.Submit.Peers = peers
+			cfg.Submit.Quorum = testDef.quorum
+			txBytes := testTx(t)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- submitTxNtN(txBytes, "test")
+			}()
+			for _, peer := range requesting {
+				server := peer.server(t)
+				txIds, err := server.RequestTxIds(true, 10)
+				if err != nil {
+					t.Fatalf("failed to request TX IDs: %s", err)
+				}
+				if len(txIds) != 1 {
+					t.Fatalf("expected 1 TX ID, got %d", len(txIds))
+				}
+				if _, err := server.RequestTxs(
+					[]txsubmission.TxId{txIds[0].TxId},
+				); err != nil {
+					t.Fatalf("failed to request TXs: %s", err)
+				}
+			}
+			var err error
+			select {
+			case err = <-errChan:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("submit didn't return")
+			}
+			switch {
+			case testDef.expectedErr != "":
+				if err == nil ||
+					!strings.Contains(err.Error(), testDef.expectedErr) {
+					t.Fatalf(
+						"expected an error containing %q, got: %v",
+						testDef.expectedErr,
+						err,
+					)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
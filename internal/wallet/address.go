@@ -0,0 +1,200 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/bursa"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/fivebinaries/go-cardano-serialization/bip32"
+)
+
+// Indexes at or above this are hardened, which CIP-1852 only uses for the
+// purpose, coin type and account
+const hardenedIndex = 0x80000000
+
+var (
+	// Account key derived from the mnemonic, used to derive more addresses.
+	// It's loaded on first use when signing with a separate signer
+	globalAccountKey      bip32.XPrv
+	globalAccountKeyMutex sync.Mutex
+	// Additional addresses watched for deposits
+	globalReceiveAddresses []string
+)
+
+// DeriveAddress returns the base address with the payment key at the
+// specified address index of the wallet account, and the wallet stake key
+func DeriveAddress(index uint32) (string, error) {
+	if index >= hardenedIndex {
+		return "", fmt.Errorf("address index %d out of range", index)
+	}
+	accountKey, err := getAccountKey()
+	if err != nil {
+		return "", err
+	}
+	addr, err := deriveAddress(accountKey, index)
+	if err != nil {
+		return "", err
+	}
+	return addr.String(), nil
+}
+
+// getAccountKey returns the wallet account key, loading the mnemonic if it
+// hasn't been loaded yet
+func getAccountKey() (bip32.XPrv, error) {
+	globalAccountKeyMutex.Lock()
+	defer globalAccountKeyMutex.Unlock()
+	if globalAccountKey != nil {
+		return globalAccountKey, nil
+	}
+	mnemonic, err := loadMnemonic()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mnemonic: %w", err)
+	}
+	accountKey, err := accountKeyFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	globalAccountKey = accountKey
+	return globalAccountKey, nil
+}
+
+// GetReceiveAddresses returns the additional addresses watched for deposits
+func GetReceiveAddresses() []string {
+	return globalReceiveAddresses
+}
+
+// GetDerivedAddresses returns the receive addresses and customer deposit
+// addresses, which have their own payment keys, mapped to their address index
+func GetDerivedAddresses() map[string]uint32 {
+	cfg := config.GetConfig()
+	ret := make(map[string]uint32)
+	for i, addr := range globalReceiveAddresses {
+		ret[addr] = cfg.Wallet.AddressIndex + 1 + uint32(i) // #nosec G115
+	}
+	globalDepositAddressesMutex.RLock()
+	defer globalDepositAddressesMutex.RUnlock()
+	for addr, depositAddr := range globalDepositAddresses {
+		ret[addr] = depositAddr.Index
+	}
+	return ret
+}
+
+// PaymentKey returns the payment signing key at the specified address index
+// of the wallet account, loading the mnemonic if needed
+func PaymentKey(index uint32) (bip32.XPrv, error) {
+	if index >= hardenedIndex {
+		return nil, fmt.Errorf("address index %d out of range", index)
+	}
+	accountKey, err := getAccountKey()
+	if err != nil {
+		return nil, err
+	}
+	return bursa.GetPaymentKey(accountKey, index), nil
+}
+
+// IsWalletAddress returns whether the address is the wallet address, one of
+// its receive addresses or a customer deposit address
+func IsWalletAddress(addr string) bool {
+	if globalWallet == nil {
+		return false
+	}
+	if addr == globalWallet.PaymentAddress ||
+		slices.Contains(globalReceiveAddresses, addr) {
+		return true
+	}
+	_, ok := LookupDepositAddress(addr)
+	return ok
+}
+
+// deriveAddress builds the base address for the payment key at the specified
+// index and the configured stake key
+func deriveAddress(
+	accountKey bip32.XPrv,
+	index uint32,
+) (*lcommon.Address, error) {
+	cfg := config.GetConfig()
+	network, ok := ouroboros.NetworkByName(cfg.Network)
+	if !ok {
+		return nil, fmt.Errorf("unknown network: %s", cfg.Network)
+	}
+	paymentKeyHash := bursa.GetPaymentKey(accountKey, index).
+		Public().
+		PublicKey().
+		Hash()
+	stakeKeyHash := bursa.GetStakeKey(accountKey, cfg.Wallet.StakeIndex).
+		Public().
+		PublicKey().
+		Hash()
+	addr, err := lcommon.NewAddressFromParts(
+		lcommon.AddressTypeKeyKey,
+		network.Id,
+		paymentKeyHash[:],
+		stakeKeyHash[:],
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build address: %w", err)
+	}
+	return &addr, nil
+}
+
+// deriveReceiveAddresses returns the configured number of addresses following
+// the wallet address
+func deriveReceiveAddresses(accountKey bip32.XPrv) ([]string, error) {
+	cfg := config.GetConfig()
+	ret := make([]string, 0, cfg.Wallet.ReceiveAddressCount)
+	for i := range cfg.Wallet.ReceiveAddressCount {
+		addr, err := deriveAddress(accountKey, cfg.Wallet.AddressIndex+1+i)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, addr.String())
+	}
+	return ret, nil
+}
+
+// checkDerivationIndexes makes sure that the configured indexes are valid
+// CIP-1852 path components
+func checkDerivationIndexes() error {
+	cfg := config.GetConfig()
+	if cfg.Wallet.AccountIndex >= hardenedIndex {
+		return fmt.Errorf(
+			"account index %d out of range",
+			cfg.Wallet.AccountIndex,
+		)
+	}
+	if cfg.Wallet.StakeIndex >= hardenedIndex {
+		return fmt.Errorf(
+			"stake index %d out of range",
+			cfg.Wallet.StakeIndex,
+		)
+	}
+	// The receive addresses follow the wallet address
+	lastIndex := uint64(cfg.Wallet.AddressIndex) +
+		uint64(cfg.Wallet.ReceiveAddressCount)
+	if lastIndex >= hardenedIndex {
+		return fmt.Errorf(
+			"address index %d with %d receive addresses out of range",
+			cfg.Wallet.AddressIndex,
+			cfg.Wallet.ReceiveAddressCount,
+		)
+	}
+	return nil
+}
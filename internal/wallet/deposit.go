@@ -0,0 +1,166 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/storage"
+	ouroboros "github.com/blinklabs-io/gouroboros"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+)
+
+// ErrInvalidDepositRequest is returned by AssignDepositAddress for a missing
+// customer ID, an invalid payout address, or a payout address other than the
+// one the customer already has
+var ErrInvalidDepositRequest = errors.New("invalid deposit address request")
+
+var (
+	// Deposit addresses handed out to customers, keyed by address
+	globalDepositAddresses      = make(map[string]storage.DepositAddress)
+	globalDepositAddressesMutex sync.RWMutex
+	// Functions to call when a new deposit address is handed out
+	globalDepositAddressListeners []func(storage.DepositAddress)
+)
+
+// AddDepositAddressListener registers a function to call whenever a new
+// deposit address is handed out to a customer
+func AddDepositAddressListener(listener func(storage.DepositAddress)) {
+	globalDepositAddressesMutex.Lock()
+	defer globalDepositAddressesMutex.Unlock()
+	globalDepositAddressListeners = append(
+		globalDepositAddressListeners,
+		listener,
+	)
+}
+
+// LoadDepositAddresses loads the deposit addresses handed out to customers,
+// so that deposits to them can be attributed. Any previously loaded addresses
+// are replaced
+func LoadDepositAddresses() error {
+	depositAddrs, err := storage.GetStorage().ListDepositAddresses()
+	if err != nil {
+		return fmt.Errorf("failed to list deposit addresses: %w", err)
+	}
+	loaded := make(map[string]storage.DepositAddress, len(depositAddrs))
+	for _, depositAddr := range depositAddrs {
+		loaded[depositAddr.Address] = depositAddr
+	}
+	globalDepositAddressesMutex.Lock()
+	defer globalDepositAddressesMutex.Unlock()
+	globalDepositAddresses = loaded
+	if len(depositAddrs) > 0 {
+		slog.Info(
+			fmt.Sprintf("loaded %d customer deposit addresses", len(depositAddrs)),
+		)
+	}
+	return nil
+}
+
+// AssignDepositAddress returns the deposit address for the customer, deriving
+// a new one from the next unused address index if needed. Customer deposit
+// addresses follow the wallet address and its receive addresses. The second
+// return value is whether a new address was created
+func AssignDepositAddress(
+	customerId string,
+	payoutAddress string,
+) (*storage.DepositAddress, bool, error) {
+	cfg := config.GetConfig()
+	if customerId == "" {
+		return nil, false, fmt.Errorf(
+			"%w: no customer ID provided",
+			ErrInvalidDepositRequest,
+		)
+	}
+	if payoutAddress != "" {
+		if err := CheckAddressNetwork(payoutAddress); err != nil {
+			return nil, false, fmt.Errorf(
+				"%w: invalid payout address: %w",
+				ErrInvalidDepositRequest,
+				err,
+			)
+		}
+	}
+	firstIndex := cfg.Wallet.AddressIndex + cfg.Wallet.ReceiveAddressCount + 1
+	depositAddr, created, err := storage.GetStorage().AssignDepositAddress(
+		customerId,
+		payoutAddress,
+		firstIndex,
+		DeriveAddress,
+	)
+	if errors.Is(err, storage.ErrPayoutAddressMismatch) {
+		return nil, false, fmt.Errorf("%w: %w", ErrInvalidDepositRequest, err)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	globalDepositAddressesMutex.Lock()
+	globalDepositAddresses[depositAddr.Address] = *depositAddr
+	listeners := slices.Clone(globalDepositAddressListeners)
+	globalDepositAddressesMutex.Unlock()
+	if created {
+		for _, listener := range listeners {
+			listener(*depositAddr)
+		}
+	}
+	return depositAddr, created, nil
+}
+
+// LookupDepositAddress returns the customer deposit address entry for the
+// address, if it was handed out to a customer
+func LookupDepositAddress(addr string) (storage.DepositAddress, bool) {
+	globalDepositAddressesMutex.RLock()
+	defer globalDepositAddressesMutex.RUnlock()
+	ret, ok := globalDepositAddresses[addr]
+	return ret, ok
+}
+
+// GetDepositAddresses returns the addresses handed out to customers
+func GetDepositAddresses() []string {
+	globalDepositAddressesMutex.RLock()
+	defer globalDepositAddressesMutex.RUnlock()
+	ret := make([]string, 0, len(globalDepositAddresses))
+	for addr := range globalDepositAddresses {
+		ret = append(ret, addr)
+	}
+	return ret
+}
+
+// CheckAddressNetwork makes sure that an address belongs to the configured
+// network
+func CheckAddressNetwork(addr string) error {
+	cfg := config.GetConfig()
+	network, ok := ouroboros.NetworkByName(cfg.Network)
+	if !ok {
+		return fmt.Errorf("unknown network: %s", cfg.Network)
+	}
+	tmpAddr, err := lcommon.NewAddress(addr)
+	if err != nil {
+		return fmt.Errorf("failed to decode address %s: %w", addr, err)
+	}
+	if tmpAddr.NetworkId() != uint(network.Id) {
+		return fmt.Errorf(
+			"address %s does not belong to network %s",
+			addr,
+			network.Name,
+		)
+	}
+	return nil
+}
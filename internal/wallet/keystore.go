@@ -0,0 +1,280 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keystoreVersion = 1
+	keystoreKdf     = "scrypt"
+	keystoreCipher  = "xchacha20-poly1305"
+
+	// scrypt parameters for new keystores, which take about 100ms and 32MiB
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptSaltLen = 32
+
+	// Limits on the scrypt parameters of keystores being read, so that a
+	// tampered keystore can't make us use more than 1GiB of memory or spin
+	// for minutes before the passphrase is even checked
+	maxScryptN      = 1 << 20
+	maxScryptR      = 16
+	maxScryptP      = 16
+	maxScryptMemory = 1 << 30
+)
+
+var ErrKeystoreExists = errors.New("keystore already exists")
+
+// keystore is the on-disk format of an encrypted mnemonic
+type keystore struct {
+	Version   int               `json:"version"`
+	Kdf       string            `json:"kdf"`
+	KdfParams keystoreKdfParams `json:"kdfParams"`
+	Cipher    string            `json:"cipher"`
+	// Hex-encoded AEAD nonce
+	Nonce string `json:"nonce"`
+	// Hex-encoded encrypted mnemonic, including the authentication tag
+	Ciphertext string `json:"ciphertext"`
+}
+
+type keystoreKdfParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+	// Hex-encoded salt
+	Salt string `json:"salt"`
+}
+
+// ReadKeystore decrypts the mnemonic in the keystore file with the passphrase
+func ReadKeystore(path string, passphrase []byte) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var ks keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return "", fmt.Errorf("failed to parse keystore: %w", err)
+	}
+	if ks.Version != keystoreVersion || ks.Kdf != keystoreKdf ||
+		ks.Cipher != keystoreCipher {
+		return "", fmt.Errorf(
+			"unsupported keystore: version %d, kdf %s, cipher %s",
+			ks.Version,
+			ks.Kdf,
+			ks.Cipher,
+		)
+	}
+	if err := checkKdfParams(ks.KdfParams); err != nil {
+		return "", err
+	}
+	salt, err := hex.DecodeString(ks.KdfParams.Salt)
+	if err != nil {
+		return "", fmt.Errorf("invalid keystore salt: %w", err)
+	}
+	nonce, err := hex.DecodeString(ks.Nonce)
+	if err != nil {
+		return "", fmt.Errorf("invalid keystore nonce: %w", err)
+	}
+	ciphertext, err := hex.DecodeString(ks.Ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid keystore ciphertext: %w", err)
+	}
+	key, err := scrypt.Key(
+		passphrase,
+		salt,
+		ks.KdfParams.N,
+		ks.KdfParams.R,
+		ks.KdfParams.P,
+		chacha20poly1305.KeySize,
+	)
+	if err != nil {
+		return "", fmt.Errorf("invalid keystore kdf params: %w", err)
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return "", errors.New("invalid keystore nonce size")
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt keystore: wrong passphrase?")
+	}
+	return string(plaintext), nil
+}
+
+// checkKdfParams makes sure that the scrypt parameters are within our limits
+func checkKdfParams(params keystoreKdfParams) error {
+	// scrypt uses 128 * N * R bytes of memory
+	if params.N < 2 || params.N > maxScryptN ||
+		params.R < 1 || params.R > maxScryptR ||
+		params.P < 1 || params.P > maxScryptP ||
+		128*params.N*params.R > maxScryptMemory {
+		return fmt.Errorf(
+			"unsupported keystore kdf params: n %d, r %d, p %d",
+			params.N,
+			params.R,
+			params.P,
+		)
+	}
+	return nil
+}
+
+// WriteKeystore encrypts the mnemonic with the passphrase and writes it to a
+// new keystore file. The file is written atomically with 0600 permissions,
+// and ErrKeystoreExists is returned if it already exists
+func WriteKeystore(path string, mnemonic string, passphrase []byte) error {
+	salt := make([]byte, scryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, err := scrypt.Key(
+		passphrase,
+		salt,
+		scryptN,
+		scryptR,
+		scryptP,
+		chacha20poly1305.KeySize,
+	)
+	if err != nil {
+		return err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ks := keystore{
+		Version: keystoreVersion,
+		Kdf:     keystoreKdf,
+		KdfParams: keystoreKdfParams{
+			N:    scryptN,
+			R:    scryptR,
+			P:    scryptP,
+			Salt: hex.EncodeToString(salt),
+		},
+		Cipher: keystoreCipher,
+		Nonce:  hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(
+			aead.Seal(nil, nonce, []byte(mnemonic), nil),
+		),
+	}
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileExclusive(path, data)
+}
+
+// writeFileExclusive atomically creates a file with 0600 permissions. The
+// data is written to a temp file in the same directory, which is then linked
+// into place, so that the file either doesn't exist or is complete, and an
+// existing file is never overwritten
+func writeFileExclusive(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%w: %s", ErrKeystoreExists, path)
+	}
+	dir := filepath.Dir(path)
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+	// CreateTemp already uses 0600, but we make sure regardless of umask
+	if err := tmpFile.Chmod(0o600); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	// Unlike a rename, a link fails if the destination already exists
+	if err := os.Link(tmpPath, path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%w: %s", ErrKeystoreExists, path)
+		}
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes a directory entry change to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir) // #nosec G304
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Not all platforms support syncing a directory
+	_ = d.Sync()
+	return nil
+}
+
+// removeSecurely overwrites a file with random data before removing it. This
+// is best effort, since filesystems with journaling, copy-on-write or wear
+// leveling can keep copies of the original data
+func removeSecurely(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0) // #nosec G304
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	junk := make([]byte, info.Size())
+	if _, err := rand.Read(junk); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteAt(junk, 0); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,240 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art"
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	passphrase := []byte("correct horse")
+	if err := WriteKeystore(path, testMnemonic, passphrase); err != nil {
+		t.Fatalf("failed to write keystore: %s", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat keystore: %s", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 permissions, got %o", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read keystore: %s", err)
+	}
+	if strings.Contains(string(data), "abandon") {
+		t.Fatalf("keystore contains the plaintext mnemonic")
+	}
+	mnemonic, err := ReadKeystore(path, passphrase)
+	if err != nil {
+		t.Fatalf("failed to read keystore: %s", err)
+	}
+	if mnemonic != testMnemonic {
+		t.Fatalf("got mnemonic %q, expected %q", mnemonic, testMnemonic)
+	}
+	if _, err := ReadKeystore(path, []byte("wrong")); err == nil {
+		t.Fatalf("expected an error with the wrong passphrase")
+	}
+	// An existing keystore is never overwritten
+	err = WriteKeystore(path, "other mnemonic", passphrase)
+	if !errors.Is(err, ErrKeystoreExists) {
+		t.Fatalf("expected keystore exists error, got: %v", err)
+	}
+	if mnemonic, _ := ReadKeystore(path, passphrase); mnemonic != testMnemonic {
+		t.Fatalf("keystore was overwritten")
+	}
+}
+
+func TestReadKeystoreKdfParams(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keystore.json")
+	passphrase := []byte("correct horse")
+	if err := WriteKeystore(path, testMnemonic, passphrase); err != nil {
+		t.Fatalf("failed to write keystore: %s", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read keystore: %s", err)
+	}
+	testDefs := []struct {
+		name        string
+		n           int
+		r           int
+		p           int
+		expectedErr string
+	}{
+		{
+			name:        "N too large",
+			n:           1 << 21,
+			r:           1,
+			p:           1,
+			expectedErr: "unsupported keystore kdf params",
+		},
+		{
+			name:        "R too large",
+			n:           1 << 10,
+			r:           1 << 20,
+			p:           1,
+			expectedErr: "unsupported keystore kdf params",
+		},
+		{
+			name:        "P too large",
+			n:           1 << 10,
+			r:           8,
+			p:           1 << 20,
+			expectedErr: "unsupported keystore kdf params",
+		},
+		{
+			name:        "too much memory",
+			n:           1 << 20,
+			r:           16,
+			p:           1,
+			expectedErr: "unsupported keystore kdf params",
+		},
+		{
+			name:        "zero N",
+			n:           0,
+			r:           8,
+			p:           1,
+			expectedErr: "unsupported keystore kdf params",
+		},
+		{
+			name:        "N not a power of 2",
+			n:           1000,
+			r:           8,
+			p:           1,
+			expectedErr: "invalid keystore kdf params",
+		},
+		{
+			// Different params derive a different key
+			name:        "within limits",
+			n:           1 << 10,
+			r:           8,
+			p:           1,
+			expectedErr: "wrong passphrase",
+		},
+	}
+	for idx, testDef := range testDefs {
+		t.Run(testDef.name, func(t *testing.T) {
+			var ks keystore
+			if err := json.Unmarshal(data, &ks); err != nil {
+				t.Fatalf("failed to parse keystore: %s", err)
+			}
+			ks.KdfParams.N = testDef.n
+			ks.KdfParams.R = testDef.r
+			ks.KdfParams.P = testDef.p
+			tamperedData, err := json.Marshal(ks)
+			if err != nil {
+				t.Fatalf("failed to encode keystore: %s", err)
+			}
+			tamperedPath := filepath.Join(dir, "tampered-"+string(rune('a'+idx)))
+			if err := os.WriteFile(tamperedPath, tamperedData, 0o600); err != nil {
+				t.Fatalf("failed to write keystore: %s", err)
+			}
+			_, err = ReadKeystore(tamperedPath, passphrase)
+			if err == nil || !strings.Contains(err.Error(), testDef.expectedErr) {
+				t.Fatalf(
+					"expected error containing %q, got: %v",
+					testDef.expectedErr,
+					err,
+				)
+			}
+		})
+	}
+}
+
+func TestLoadKeystore(t *testing.T) {
+	// The legacy seed.txt is looked for in the working directory
+	t.Chdir(t.TempDir())
+	cfg := config.GetConfig()
+	cfg.Wallet.KeystoreFile = "keystore.json"
+	cfg.Wallet.Passphrase = "correct horse"
+	t.Cleanup(func() {
+		cfg.Wallet.KeystoreFile = "keystore.json"
+		cfg.Wallet.Passphrase = ""
+	})
+	if err := os.WriteFile(
+		legacySeedFile,
+		[]byte(testMnemonic+"\n"),
+		0o600,
+	); err != nil {
+		t.Fatalf("failed to write seed file: %s", err)
+	}
+	// The legacy seed file is imported into a new keystore and removed
+	mnemonic, err := loadKeystore()
+	if err != nil {
+		t.Fatalf("failed to load keystore: %s", err)
+	}
+	if mnemonic != testMnemonic {
+		t.Fatalf("got mnemonic %q, expected %q", mnemonic, testMnemonic)
+	}
+	if _, err := os.Stat(legacySeedFile); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %s to be removed, got: %v", legacySeedFile, err)
+	}
+	// The keystore is used from now on, and a seed file showing up again is
+	// ignored
+	if err := os.WriteFile(
+		legacySeedFile,
+		[]byte("other mnemonic\n"),
+		0o600,
+	); err != nil {
+		t.Fatalf("failed to write seed file: %s", err)
+	}
+	mnemonic, err = loadKeystore()
+	if err != nil {
+		t.Fatalf("failed to load keystore: %s", err)
+	}
+	if mnemonic != testMnemonic {
+		t.Fatalf("got mnemonic %q, expected %q", mnemonic, testMnemonic)
+	}
+	if _, err := os.Stat(legacySeedFile); err != nil {
+		t.Fatalf("expected %s to be left alone: %s", legacySeedFile, err)
+	}
+	// The keystore can't be read with another passphrase
+	cfg.Wallet.Passphrase = "wrong"
+	if _, err := loadKeystore(); err == nil {
+		t.Fatalf("expected an error with the wrong passphrase")
+	}
+	// A new mnemonic is generated without a keystore or seed file
+	cfg.Wallet.Passphrase = "correct horse"
+	cfg.Wallet.KeystoreFile = "new-keystore.json"
+	if err := os.Remove(legacySeedFile); err != nil {
+		t.Fatalf("failed to remove seed file: %s", err)
+	}
+	generated, err := loadKeystore()
+	if err != nil {
+		t.Fatalf("failed to create keystore: %s", err)
+	}
+	if len(strings.Fields(generated)) != 24 || generated == testMnemonic {
+		t.Fatalf("expected a new 24 word mnemonic, got %q", generated)
+	}
+	mnemonic, err = ReadKeystore(cfg.Wallet.KeystoreFile, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("failed to read new keystore: %s", err)
+	}
+	if mnemonic != generated {
+		t.Fatalf("new keystore doesn't hold the generated mnemonic")
+	}
+}
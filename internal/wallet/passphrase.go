@@ -0,0 +1,79 @@
+// Copyright 2025 Blink Labs Software
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
+	"golang.org/x/term"
+)
+
+// getPassphrase returns the keystore passphrase from the config, a file or an
+// interactive prompt, in that order. When confirm is true, the prompt asks for
+// the passphrase twice, which is used when creating a new keystore
+func getPassphrase(confirm bool) ([]byte, error) {
+	cfg := config.GetConfig()
+	if cfg.Wallet.Passphrase != "" {
+		return []byte(cfg.Wallet.Passphrase), nil
+	}
+	if cfg.Wallet.PassphraseFile != "" {
+		data, err := os.ReadFile(cfg.Wallet.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		ret := bytes.TrimRight(data, "\r\n")
+		if len(ret) == 0 {
+			return nil, errors.New("passphrase file is empty")
+		}
+		return ret, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) { // #nosec G115
+		return nil, errors.New(
+			"no keystore passphrase provided: set WALLET_PASSPHRASE or WALLET_PASSPHRASE_FILE, or run interactively",
+		)
+	}
+	passphrase, err := promptPassphrase("Wallet keystore passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+	if confirm {
+		confirmation, err := promptPassphrase("Confirm passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(passphrase, confirmation) {
+			return nil, errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing it
+func promptPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	ret, err := term.ReadPassword(int(os.Stdin.Fd())) // #nosec G115
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return ret, nil
+}
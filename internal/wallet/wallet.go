@@ -16,11 +16,21 @@ package wallet
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/blinklabs-io/buidler-fest-2024-workshop/internal/config"
 	"github.com/blinklabs-io/bursa"
+	lcommon "github.com/blinklabs-io/gouroboros/ledger/common"
+	"github.com/fivebinaries/go-cardano-serialization/bip32"
+)
+
+const (
+	// Plaintext mnemonic file written by earlier versions, which is imported
+	// into the keystore
+	legacySeedFile = "seed.txt"
 )
 
 var globalWallet *bursa.Wallet
@@ -32,50 +42,197 @@ func Setup() (*bursa.Wallet, error) {
 	}
 	// Setup wallet
 	cfg := config.GetConfig()
-	mnemonic := cfg.Wallet.Mnemonic
-	if mnemonic == "" {
-		// Read seed.txt if it exists
-		if data, err := os.ReadFile("seed.txt"); err == nil {
-			slog.Info("read mnemonic from seed.txt")
-			mnemonic = string(data)
-		} else if errors.Is(err, os.ErrNotExist) {
-			mnemonic, err = bursa.NewMnemonic()
-			if err != nil {
-				return nil, err
-			}
-			// Write seed.txt
-			// WARNING: this will clobber existing files
-			f, err := os.Create("seed.txt")
-			if err != nil {
-				return nil, err
-			}
-			l, err := f.WriteString(mnemonic)
-			slog.Debug("wrote bytes to seed.txt", "bytes", l) // #nosec G706
-			if err != nil {
-				f.Close()
-				return nil, err
-			}
-			err = f.Close()
-			if err != nil {
-				return nil, err
-			}
-			slog.Info("wrote generated mnemonic to seed.txt")
-		} else {
-			return nil, err
-		}
+	mnemonic, err := loadMnemonic()
+	if err != nil {
+		return nil, err
 	}
 	wallet, err := bursa.NewWallet(
 		mnemonic,
 		cfg.Network,
-		"", 0, 0, 0, 0,
+		cfg.Wallet.Bip39Passphrase,
+		cfg.Wallet.AccountIndex,
+		cfg.Wallet.AddressIndex,
+		cfg.Wallet.StakeIndex,
+		cfg.Wallet.AddressIndex,
 	)
 	if err != nil {
 		return nil, err
 	}
+	accountKey, err := accountKeyFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	// bursa derives both the payment and stake parts of the address from the
+	// address index, so we build it ourselves to use the configured stake key
+	addr, err := deriveAddress(accountKey, cfg.Wallet.AddressIndex)
+	if err != nil {
+		return nil, err
+	}
+	wallet.PaymentAddress = addr.String()
+	wallet.StakeAddress = addr.StakeAddress().String()
+	receiveAddresses, err := deriveReceiveAddresses(accountKey)
+	if err != nil {
+		return nil, err
+	}
+	globalAccountKeyMutex.Lock()
+	globalAccountKey = accountKey
+	globalAccountKeyMutex.Unlock()
+	globalReceiveAddresses = receiveAddresses
+	globalWallet = wallet
+	return globalWallet, nil
+}
+
+// SetupWatchOnly sets up a wallet for an address whose payment key is held by
+// a separate signer. The mnemonic is only loaded if receive addresses are
+// configured, since they're derived from it
+func SetupWatchOnly(paymentAddress string) (*bursa.Wallet, error) {
+	// Return existing wallet instance if available
+	if globalWallet != nil {
+		return globalWallet, nil
+	}
+	cfg := config.GetConfig()
+	if err := CheckAddressNetwork(paymentAddress); err != nil {
+		return nil, err
+	}
+	addr, err := lcommon.NewAddress(paymentAddress)
+	if err != nil {
+		return nil, err
+	}
+	wallet := &bursa.Wallet{
+		PaymentAddress: paymentAddress,
+	}
+	if stakeAddr := addr.StakeAddress(); stakeAddr != nil {
+		wallet.StakeAddress = stakeAddr.String()
+	}
+	if cfg.Wallet.ReceiveAddressCount > 0 {
+		accountKey, err := getAccountKey()
+		if err != nil {
+			return nil, err
+		}
+		receiveAddresses, err := deriveReceiveAddresses(accountKey)
+		if err != nil {
+			return nil, err
+		}
+		globalReceiveAddresses = receiveAddresses
+	}
 	globalWallet = wallet
 	return globalWallet, nil
 }
 
+// loadMnemonic returns the configured mnemonic, or the one from the keystore
+func loadMnemonic() (string, error) {
+	cfg := config.GetConfig()
+	if err := checkDerivationIndexes(); err != nil {
+		return "", err
+	}
+	if cfg.Wallet.Mnemonic != "" {
+		return cfg.Wallet.Mnemonic, nil
+	}
+	return loadKeystore()
+}
+
+// accountKeyFromMnemonic derives the configured account key from the mnemonic
+func accountKeyFromMnemonic(mnemonic string) (bip32.XPrv, error) {
+	cfg := config.GetConfig()
+	rootKey, err := bursa.GetRootKeyFromMnemonic(
+		mnemonic,
+		cfg.Wallet.Bip39Passphrase,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return bursa.GetAccountKey(rootKey, cfg.Wallet.AccountIndex), nil
+}
+
+// loadKeystore returns the mnemonic from the encrypted keystore. If there's no
+// keystore yet, one is created from the legacy seed.txt, which is then
+// removed, or from a newly generated mnemonic
+func loadKeystore() (string, error) {
+	cfg := config.GetConfig()
+	keystorePath := cfg.Wallet.KeystoreFile
+	if _, err := os.Stat(keystorePath); err == nil {
+		passphrase, err := getPassphrase(false)
+		if err != nil {
+			return "", err
+		}
+		mnemonic, err := ReadKeystore(keystorePath, passphrase)
+		if err != nil {
+			return "", err
+		}
+		slog.Info("read mnemonic from keystore " + keystorePath)
+		if _, err := os.Stat(legacySeedFile); err == nil {
+			slog.Warn(
+				fmt.Sprintf(
+					"ignoring %s, since keystore %s already exists. Remove it once you've made sure it's no longer needed",
+					legacySeedFile,
+					keystorePath,
+				),
+			)
+		}
+		return mnemonic, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	// Import the legacy seed.txt, if it exists
+	var mnemonic string
+	data, err := os.ReadFile(legacySeedFile)
+	if err == nil {
+		slog.Info(
+			fmt.Sprintf(
+				"importing mnemonic from %s into keystore %s",
+				legacySeedFile,
+				keystorePath,
+			),
+		)
+		mnemonic = strings.TrimSpace(string(data))
+	} else if errors.Is(err, os.ErrNotExist) {
+		mnemonic, err = bursa.NewMnemonic()
+		if err != nil {
+			return "", err
+		}
+	} else {
+		return "", err
+	}
+	passphrase, err := getPassphrase(true)
+	if err != nil {
+		return "", err
+	}
+	if err := WriteKeystore(keystorePath, mnemonic, passphrase); err != nil {
+		return "", fmt.Errorf("failed to write keystore: %w", err)
+	}
+	// Make sure the keystore can be read back before removing the original
+	if data != nil {
+		check, err := ReadKeystore(keystorePath, passphrase)
+		if err != nil {
+			return "", fmt.Errorf(
+				"failed to verify keystore, leaving %s in place: %w",
+				legacySeedFile,
+				err,
+			)
+		}
+		if check != mnemonic {
+			return "", fmt.Errorf(
+				"keystore contents don't match, leaving %s in place",
+				legacySeedFile,
+			)
+		}
+		if err := removeSecurely(legacySeedFile); err != nil {
+			return "", fmt.Errorf(
+				"failed to remove %s after import: %w",
+				legacySeedFile,
+				err,
+			)
+		}
+		slog.Info(
+			fmt.Sprintf("removed %s after importing it", legacySeedFile),
+		)
+	}
+	if data == nil {
+		slog.Info("wrote generated mnemonic to keystore " + keystorePath)
+	}
+	return mnemonic, nil
+}
+
 func GetWallet() *bursa.Wallet {
 	return globalWallet
 }